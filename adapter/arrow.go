@@ -0,0 +1,34 @@
+package adapter
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/marcboeker/go-duckdb"
+)
+
+// QueryArrow runs a query on the session's connection with the Arrow interface of DuckDB,
+// which returns the result as record batches without converting it value by value.
+// The returned reader must be released.
+func QueryArrow(ctx *sql.Context, query string) (array.RecordReader, error) {
+	conn, err := GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rdr array.RecordReader
+	err = conn.Raw(func(driverConn any) error {
+		dc, ok := driverConn.(driver.Conn)
+		if !ok {
+			return errors.New("not a DuckDB connection")
+		}
+		a, err := duckdb.NewArrowFromConn(dc)
+		if err != nil {
+			return err
+		}
+		rdr, err = a.QueryContext(ctx, query)
+		return err
+	})
+	return rdr, err
+}
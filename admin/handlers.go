@@ -0,0 +1,256 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apecloud/myduckserver/transpiler"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/binlogreplication"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+)
+
+type serverInfo struct {
+	DataDir       string  `json:"datadir"`
+	Catalog       string  `json:"catalog"`
+	DuckDBVersion string  `json:"duckdb_version"`
+	StartedAt     string  `json:"started_at"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+func (s *Server) handleServerInfo(w http.ResponseWriter, r *http.Request) {
+	var version string
+	if err := s.provider.Storage().QueryRowContext(r.Context(), "SELECT version()").Scan(&version); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, serverInfo{
+		DataDir:       s.provider.DataDir(),
+		Catalog:       s.provider.CatalogName(),
+		DuckDBVersion: version,
+		StartedAt:     s.started.UTC().Format(time.RFC3339),
+		UptimeSeconds: time.Since(s.started).Seconds(),
+	})
+}
+
+// replicaStatus mirrors the columns of SHOW REPLICA STATUS.
+type replicaStatus struct {
+	SourceHost            string     `json:"source_host"`
+	SourceUser            string     `json:"source_user"`
+	SourcePort            uint       `json:"source_port"`
+	ConnectRetry          uint32     `json:"connect_retry"`
+	SourceRetryCount      uint64     `json:"source_retry_count"`
+	ReplicaIORunning      string     `json:"replica_io_running"`
+	ReplicaSQLRunning     string     `json:"replica_sql_running"`
+	LastSQLErrNumber      uint       `json:"last_sql_errno"`
+	LastSQLError          string     `json:"last_sql_error"`
+	LastSQLErrorTimestamp *time.Time `json:"last_sql_error_timestamp"`
+	LastIOErrNumber       uint       `json:"last_io_errno"`
+	LastIOError           string     `json:"last_io_error"`
+	LastIOErrorTimestamp  *time.Time `json:"last_io_error_timestamp"`
+	SourceServerID        string     `json:"source_server_id"`
+	SourceServerUUID      string     `json:"source_uuid"`
+	RetrievedGtidSet      string     `json:"retrieved_gtid_set"`
+	ExecutedGtidSet       string     `json:"executed_gtid_set"`
+	AutoPosition          bool       `json:"auto_position"`
+	ReplicateDoTables     []string   `json:"replicate_do_table"`
+	ReplicateIgnoreTables []string   `json:"replicate_ignore_table"`
+}
+
+func (s *Server) handleReplicaStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.replica.GetReplicaStatus(s.newContext(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if status == nil {
+		// Not configured as a replica; SHOW REPLICA STATUS returns an empty set in this case.
+		writeJSON(w, http.StatusOK, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, replicaStatus{
+		SourceHost:            status.SourceHost,
+		SourceUser:            status.SourceUser,
+		SourcePort:            status.SourcePort,
+		ConnectRetry:          status.ConnectRetry,
+		SourceRetryCount:      status.SourceRetryCount,
+		ReplicaIORunning:      status.ReplicaIoRunning,
+		ReplicaSQLRunning:     status.ReplicaSqlRunning,
+		LastSQLErrNumber:      status.LastSqlErrNumber,
+		LastSQLError:          status.LastSqlError,
+		LastSQLErrorTimestamp: status.LastSqlErrorTimestamp,
+		LastIOErrNumber:       status.LastIoErrNumber,
+		LastIOError:           status.LastIoError,
+		LastIOErrorTimestamp:  status.LastIoErrorTimestamp,
+		SourceServerID:        status.SourceServerId,
+		SourceServerUUID:      status.SourceServerUuid,
+		RetrievedGtidSet:      status.RetrievedGtidSet,
+		ExecutedGtidSet:       status.ExecutedGtidSet,
+		AutoPosition:          status.AutoPosition,
+		ReplicateDoTables:     status.ReplicateDoTables,
+		ReplicateIgnoreTables: status.ReplicateIgnoreTables,
+	})
+}
+
+func (s *Server) handleReplicaStart(w http.ResponseWriter, r *http.Request) {
+	if err := s.replica.StartReplica(s.newContext(r)); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReplicaStop(w http.ResponseWriter, r *http.Request) {
+	if err := s.replica.StopReplica(s.newContext(r)); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReplicaReset is the equivalent of RESET REPLICA, or RESET REPLICA ALL with ?all=true.
+func (s *Server) handleReplicaReset(w http.ResponseWriter, r *http.Request) {
+	var all bool
+	if v := r.URL.Query().Get("all"); v != "" {
+		var err error
+		if all, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid value for all: %q", v))
+			return
+		}
+	}
+	if err := s.replica.ResetReplica(s.newContext(r), all); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sourceOptions are the options of CHANGE REPLICATION SOURCE TO. Absent fields are left unchanged.
+type sourceOptions struct {
+	Host         *string `json:"host"`
+	Port         *int    `json:"port"`
+	User         *string `json:"user"`
+	Password     *string `json:"password"`
+	ConnectRetry *int    `json:"connect_retry"`
+	RetryCount   *int    `json:"retry_count"`
+}
+
+func (o *sourceOptions) toReplicationOptions() []binlogreplication.ReplicationOption {
+	var options []binlogreplication.ReplicationOption
+	addString := func(name string, v *string) {
+		if v != nil {
+			options = append(options, *binlogreplication.NewReplicationOption(name, binlogreplication.StringReplicationOptionValue{Value: *v}))
+		}
+	}
+	addInt := func(name string, v *int) {
+		if v != nil {
+			options = append(options, *binlogreplication.NewReplicationOption(name, binlogreplication.IntegerReplicationOptionValue{Value: *v}))
+		}
+	}
+	addString("SOURCE_HOST", o.Host)
+	addInt("SOURCE_PORT", o.Port)
+	addString("SOURCE_USER", o.User)
+	addString("SOURCE_PASSWORD", o.Password)
+	addInt("SOURCE_CONNECT_RETRY", o.ConnectRetry)
+	addInt("SOURCE_RETRY_COUNT", o.RetryCount)
+	return options
+}
+
+func (s *Server) handleReplicaSource(w http.ResponseWriter, r *http.Request) {
+	var opts sourceOptions
+	if err := decodeBody(r, &opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.replica.SetReplicationSourceOptions(s.newContext(r), opts.toReplicationOptions()); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// filterOptions are the options of CHANGE REPLICATION FILTER. Tables must be qualified as "db.table".
+type filterOptions struct {
+	DoTables     *[]string `json:"do_tables"`
+	IgnoreTables *[]string `json:"ignore_tables"`
+}
+
+func (o *filterOptions) toReplicationOptions() ([]binlogreplication.ReplicationOption, error) {
+	var options []binlogreplication.ReplicationOption
+	add := func(name string, tables *[]string) error {
+		if tables == nil {
+			return nil
+		}
+		urts := make([]sql.UnresolvedTable, 0, len(*tables))
+		for _, t := range *tables {
+			db, table, ok := strings.Cut(t, ".")
+			if !ok || db == "" || table == "" {
+				return fmt.Errorf("table %q must be qualified with a database name", t)
+			}
+			urts = append(urts, plan.NewUnresolvedTable(table, db))
+		}
+		options = append(options, *binlogreplication.NewReplicationOption(name, binlogreplication.TableNamesReplicationOptionValue{Value: urts}))
+		return nil
+	}
+	if err := add("REPLICATE_DO_TABLE", o.DoTables); err != nil {
+		return nil, err
+	}
+	if err := add("REPLICATE_IGNORE_TABLE", o.IgnoreTables); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (s *Server) handleReplicaFilters(w http.ResponseWriter, r *http.Request) {
+	var opts filterOptions
+	if err := decodeBody(r, &opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	options, err := opts.toReplicationOptions()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.replica.SetReplicationFilterOptions(s.newContext(r), options); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTranslationCache returns the size and the hit and miss counts of the translation cache.
+func (s *Server) handleTranslationCache(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, transpiler.GetCacheStats())
+}
+
+// handleTranslationCacheFlush empties the translation cache, e.g., after upgrading sqlglot.
+func (s *Server) handleTranslationCacheFlush(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]int{"flushed": transpiler.FlushCache()})
+}
+
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admin implements the HTTP admin API, which exposes replication management
+// and server information as JSON to operators and orchestration tools.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/binlogreplication"
+	"github.com/sirupsen/logrus"
+)
+
+// Server serves the admin API. Every request must carry the configured token as a bearer token.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+	token    string
+
+	provider *catalog.DatabaseProvider
+	pool     *backend.ConnectionPool
+	replica  binlogreplication.BinlogReplicaController
+	started  time.Time
+}
+
+// NewServer listens on |addr| for admin requests authenticated with |token|.
+func NewServer(
+	addr, token string,
+	provider *catalog.DatabaseProvider,
+	pool *backend.ConnectionPool,
+	replica binlogreplication.BinlogReplicaController,
+) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("the admin API requires a token")
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: l,
+		token:    token,
+		provider: provider,
+		pool:     pool,
+		replica:  replica,
+		started:  time.Now(),
+	}
+	s.srv = &http.Server{Handler: s.authenticate(s.routes())}
+	return s, nil
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/server", s.handleServerInfo)
+	mux.HandleFunc("GET /v1/replica/status", s.handleReplicaStatus)
+	mux.HandleFunc("POST /v1/replica/start", s.handleReplicaStart)
+	mux.HandleFunc("POST /v1/replica/stop", s.handleReplicaStop)
+	mux.HandleFunc("POST /v1/replica/reset", s.handleReplicaReset)
+	mux.HandleFunc("PUT /v1/replica/source", s.handleReplicaSource)
+	mux.HandleFunc("PUT /v1/replica/filters", s.handleReplicaFilters)
+	mux.HandleFunc("GET /v1/translation-cache", s.handleTranslationCache)
+	mux.HandleFunc("POST /v1/translation-cache/flush", s.handleTranslationCacheFlush)
+	return mux
+}
+
+// Start serves the admin API until the server is closed.
+func (s *Server) Start() {
+	logrus.Infof("Admin API listening on %s", s.listener.Addr())
+	if err := s.srv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorln("Admin API stopped:", err)
+	}
+}
+
+// Close stops the server, waiting for in-flight requests until |ctx| is done.
+func (s *Server) Close(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="myduckserver"`)
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newContext creates a dedicated session for the request, in the same way as the replica controller does.
+func (s *Server) newContext(r *http.Request) *sql.Context {
+	session := backend.NewSession(memory.NewSession(sql.NewBaseSession(), s.provider), s.provider, s.pool)
+	ctx := sql.NewContext(r.Context(), sql.WithSession(session))
+	ctx.SetCurrentDatabase("mysql")
+	return ctx
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnln("Failed to write admin API response:", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
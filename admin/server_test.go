@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthentication(t *testing.T) {
+	s := &Server{token: "secret"}
+	handler := s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic c2VjcmV0", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/server", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("Authorization %q: expected %d, got %d", tt.header, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestNewServerRequiresToken(t *testing.T) {
+	if _, err := NewServer("127.0.0.1:0", "", nil, nil, nil); err == nil {
+		t.Error("expected an error without a token")
+	}
+}
+
+func TestFilterOptions(t *testing.T) {
+	doTables := []string{"db1.t1", "db2.t2"}
+	opts := filterOptions{DoTables: &doTables}
+	options, err := opts.toReplicationOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].Name != "REPLICATE_DO_TABLE" {
+		t.Fatalf("unexpected options: %v", options)
+	}
+
+	unqualified := []string{"t1"}
+	opts = filterOptions{IgnoreTables: &unqualified}
+	if _, err := opts.toReplicationOptions(); err == nil {
+		t.Error("expected an error for an unqualified table")
+	}
+}
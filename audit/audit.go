@@ -0,0 +1,175 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records logins, statements and account changes. The records are appended to
+// rotating JSON-lines files, which are the authoritative trail, and the recent ones are also
+// kept in the performance_schema.audit_log table so that they can be queried with SQL.
+package audit
+
+import (
+	stdsql "database/sql"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Classes of records.
+const (
+	ClassConnection = "connection"
+	ClassStatement  = "statement"
+	ClassAccount    = "account"
+)
+
+// Events of the connection and statement classes. The event of an account record
+// is the kind of statement that changed the accounts, e.g., "GRANT".
+const (
+	EventLogin       = "login"
+	EventLoginFailed = "login_failed"
+	EventQuery       = "query"
+	EventExecute     = "execute" // a prepared statement executed with bound parameters
+)
+
+// Record is an entry of the audit log.
+type Record struct {
+	EventID      uint64    `json:"event_id"`
+	Time         time.Time `json:"time"`
+	Class        string    `json:"class"`
+	Event        string    `json:"event"`
+	ConnectionID uint32    `json:"connection_id,omitempty"`
+	Protocol     string    `json:"protocol,omitempty"`
+	User         string    `json:"user,omitempty"`
+	Host         string    `json:"host,omitempty"` // the address of the client
+	Schema       string    `json:"schema,omitempty"`
+	Statement    string    `json:"statement,omitempty"`
+	DurationUs   int64     `json:"duration_us,omitempty"`
+	Rows         int64     `json:"rows,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Options configure a Logger.
+type Options struct {
+	// Dir is the directory of the log files. It is created if missing.
+	Dir string
+	// MaxFileSize is the size at which the current file is rotated. Zero disables the rotation.
+	MaxFileSize int64
+	// MaxFiles is the number of rotated files to keep. Zero keeps all of them.
+	MaxFiles int
+	// TableRows is the number of recent records kept in performance_schema.audit_log. Zero leaves the table empty.
+	TableRows int
+}
+
+// Logger writes audit records.
+type Logger struct {
+	mu     sync.Mutex
+	nextID uint64
+	file   *rotatingFile
+	table  *tableWriter
+}
+
+// Open opens the audit log described by |opts|. The table is written through |db|.
+func Open(opts Options, db *stdsql.DB) (*Logger, error) {
+	file, err := openRotatingFile(opts.Dir, opts.MaxFileSize, opts.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{file: file}
+	if opts.TableRows > 0 {
+		// Continue the event IDs of the table, so that a record in a file can be found in the table.
+		lastID, err := lastEventID(db)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		l.nextID = lastID
+		l.table = newTableWriter(db, opts.TableRows)
+	}
+	return l, nil
+}
+
+// Log assigns an event ID to the record and writes it.
+func (l *Logger) Log(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Statement = Redact(r.Statement)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	r.EventID = l.nextID
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if l.table != nil {
+		l.table.add(r)
+	}
+	return nil
+}
+
+// Close flushes the pending records to the table and closes the current file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.table != nil {
+		l.table.close()
+		l.table = nil
+	}
+	return l.file.Close()
+}
+
+var current atomic.Pointer[Logger]
+
+// SetLogger installs the logger used by Log. A nil logger disables the audit log.
+func SetLogger(l *Logger) {
+	current.Store(l)
+}
+
+// Close uninstalls the logger used by Log and closes it.
+func Close() error {
+	if l := current.Swap(nil); l != nil {
+		return l.Close()
+	}
+	return nil
+}
+
+// Enabled reports whether records are written, so that callers can skip collecting them otherwise.
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Log writes the record with the installed logger, if any.
+func Log(r Record) {
+	l := current.Load()
+	if l == nil {
+		return
+	}
+	if err := l.Log(r); err != nil {
+		logrus.WithError(err).Errorf("Failed to write the audit log")
+	}
+}
+
+// ErrorString returns the message of |err|, or an empty string if it is nil.
+func ErrorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
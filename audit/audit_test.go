@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bufio"
+	stdsql "database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apecloud/myduckserver/catalog"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		stmt     string
+		expected string
+	}{
+		{
+			"CREATE USER 'app'@'%' IDENTIFIED BY 'secret'",
+			"CREATE USER 'app'@'%' IDENTIFIED BY '<redacted>'",
+		},
+		{
+			"alter user app identified with caching_sha2_password by 'it''s'",
+			"alter user app identified with caching_sha2_password by '<redacted>'",
+		},
+		{
+			"SET PASSWORD FOR 'app'@'%' = 'secret'",
+			"SET PASSWORD FOR 'app'@'%' = '<redacted>'",
+		},
+		{
+			"SELECT PASSWORD('secret')",
+			"SELECT PASSWORD('<redacted>')",
+		},
+		{
+			"CREATE PERSISTENT SECRET s3 (TYPE S3, KEY_ID 'id', SECRET 'key')",
+			"CREATE PERSISTENT SECRET s3 (TYPE S3, KEY_ID '<redacted>', SECRET '<redacted>')",
+		},
+		{
+			"SELECT 'secret' FROM t WHERE password = 'x'",
+			"SELECT 'secret' FROM t WHERE password = 'x'",
+		},
+	}
+	for _, tt := range tests {
+		if actual := Redact(tt.stmt); actual != tt.expected {
+			t.Errorf("Redact(%q): expected %q, got %q", tt.stmt, tt.expected, actual)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	rf, err := openRotatingFile(dir, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := rf.Write([]byte("12345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, rotatedFilePattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("expected 2 rotated files, got %v", rotated)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, currentFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "12345678\n" {
+		t.Errorf("unexpected current file: %q", data)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	db, err := stdsql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	table := catalog.InternalTables.AuditLog
+	if _, err := db.Exec("CREATE SCHEMA " + table.Schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE " + table.QualifiedName() + " (" + table.DDL + ")"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir, TableRows: 2}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{"SELECT 1", "SELECT 2", "CREATE USER u IDENTIFIED BY 'pw'"} {
+		if err := l.Log(Record{Class: ClassStatement, Event: EventQuery, User: "root", Statement: stmt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, currentFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records in the file, got %d", len(records))
+	}
+	for i, r := range records {
+		if r.EventID != uint64(i+1) || r.Time.IsZero() {
+			t.Errorf("unexpected record %d: %+v", i, r)
+		}
+	}
+	if strings.Contains(records[2].Statement, "pw") {
+		t.Errorf("the password was not redacted: %q", records[2].Statement)
+	}
+
+	// Only the last TableRows records are kept in the table.
+	rows, err := db.Query("SELECT EVENT_ID FROM " + table.QualifiedName() + " ORDER BY EVENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Errorf("unexpected event IDs in the table: %v", ids)
+	}
+
+	// A reopened logger continues the event IDs of the table.
+	l, err = Open(Options{Dir: dir, TableRows: 2}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.nextID != 3 {
+		t.Errorf("expected the event IDs to continue from 3, got %d", l.nextID)
+	}
+}
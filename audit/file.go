@@ -0,0 +1,112 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	currentFileName = "audit.jsonl"
+	// Rotated files are named after the time of the rotation, so that they sort chronologically.
+	rotatedFilePattern = "audit-*.jsonl"
+	rotatedTimeLayout  = "20060102T150405.000000000"
+)
+
+// rotatingFile appends to audit.jsonl and renames it to audit-<time>.jsonl once it reaches maxSize.
+type rotatingFile struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(dir string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	rf := &rotatingFile{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(filepath.Join(rf.dir, currentFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+// Write appends a record. A record is never split across files.
+func (rf *rotatingFile) Write(p []byte) error {
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rotated := "audit-" + time.Now().UTC().Format(rotatedTimeLayout) + ".jsonl"
+	if err := os.Rename(filepath.Join(rf.dir, currentFileName), filepath.Join(rf.dir, rotated)); err != nil {
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	return rf.prune()
+}
+
+// prune removes the oldest rotated files beyond maxFiles.
+func (rf *rotatingFile) prune() error {
+	if rf.maxFiles <= 0 {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(rf.dir, rotatedFilePattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for len(files) > rf.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+func (rf *rotatingFile) Close() error {
+	return rf.f.Close()
+}
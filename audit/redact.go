@@ -0,0 +1,47 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import "regexp"
+
+const redacted = "'<redacted>'"
+
+// stringLiteral matches a single- or double-quoted SQL string, with backslash or doubled-quote escapes.
+const stringLiteral = `(?:'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*")`
+
+var (
+	// passwordPatterns match the passwords of account statements, with the literal as the second group:
+	// IDENTIFIED [WITH plugin] BY|AS 'secret', SET PASSWORD [FOR user] = 'secret' and PASSWORD('secret').
+	passwordPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(\bIDENTIFIED\s+(?:WITH\s+\S+\s+)?(?:BY|AS)\s+)` + stringLiteral),
+		regexp.MustCompile(`(?i)(\bSET\s+PASSWORD\b[^=]*=\s*)` + stringLiteral),
+		regexp.MustCompile(`(?i)(\bPASSWORD\s*\(\s*)` + stringLiteral),
+	}
+
+	// The options of DuckDB's CREATE SECRET hold credentials, such as S3 keys, in any string literal.
+	createSecret = regexp.MustCompile(`(?i)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:PERSISTENT\s+|TEMPORARY\s+|TEMP\s+)?SECRET\b`)
+	anyLiteral   = regexp.MustCompile(stringLiteral)
+)
+
+// Redact replaces the passwords and other credentials in a statement, so that they are not written to the audit log.
+func Redact(stmt string) string {
+	if createSecret.MatchString(stmt) {
+		return anyLiteral.ReplaceAllLiteralString(stmt, redacted)
+	}
+	for _, re := range passwordPatterns {
+		stmt = re.ReplaceAllString(stmt, "${1}"+redacted)
+	}
+	return stmt
+}
@@ -0,0 +1,137 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	stdsql "database/sql"
+
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	tableQueueSize = 8192
+	tableBatchSize = 1024
+)
+
+// tableWriter inserts the records into performance_schema.audit_log in the background,
+// so that statements do not wait for DuckDB. Records are dropped from the table, but not
+// from the files, if it falls behind.
+type tableWriter struct {
+	db      *stdsql.DB
+	maxRows uint64
+	records chan Record
+	done    chan struct{}
+	dropped int
+}
+
+func newTableWriter(db *stdsql.DB, maxRows int) *tableWriter {
+	w := &tableWriter{
+		db:      db,
+		maxRows: uint64(maxRows),
+		records: make(chan Record, tableQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// add queues a record. It must be called with the Logger's lock held, so that records are queued in order.
+func (w *tableWriter) add(r Record) {
+	select {
+	case w.records <- r:
+	default:
+		w.dropped++
+		if w.dropped == 1 {
+			logrus.Warnln("The audit log table is falling behind; records are only written to the files")
+		}
+	}
+}
+
+func (w *tableWriter) run() {
+	defer close(w.done)
+	for r := range w.records {
+		batch := []Record{r}
+	drain:
+		for len(batch) < tableBatchSize {
+			select {
+			case r, ok := <-w.records:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, r)
+			default:
+				break drain
+			}
+		}
+		if err := w.insert(batch); err != nil {
+			logrus.WithError(err).Warnf("Failed to write %d records to the audit log table", len(batch))
+		}
+	}
+}
+
+// insert writes a batch and deletes the records that exceed maxRows.
+func (w *tableWriter) insert(batch []Record) error {
+	ctx := context.Background()
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, catalog.InternalTables.AuditLog.UpsertStmt())
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range batch {
+		if _, err := stmt.ExecContext(ctx,
+			r.EventID, r.Time.UTC(), r.Class, r.Event, r.ConnectionID, nullString(r.Protocol),
+			nullString(r.User), nullString(r.Host), nullString(r.Schema), nullString(r.Statement),
+			r.DurationUs, r.Rows, nullString(r.Error),
+		); err != nil {
+			return err
+		}
+	}
+
+	if last := batch[len(batch)-1].EventID; last > w.maxRows {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM "+catalog.InternalTables.AuditLog.QualifiedName()+" WHERE EVENT_ID <= ?",
+			last-w.maxRows,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (w *tableWriter) close() {
+	close(w.records)
+	<-w.done
+}
+
+func lastEventID(db *stdsql.DB) (uint64, error) {
+	var id uint64
+	err := db.QueryRow("SELECT COALESCE(MAX(EVENT_ID), 0) FROM " + catalog.InternalTables.AuditLog.QualifiedName()).Scan(&id)
+	return id, err
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
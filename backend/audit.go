@@ -0,0 +1,128 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"time"
+
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/metrics"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+)
+
+// auditLogin records the outcome of a login on the MySQL port.
+func auditLogin(c *mysql.Conn, err error) {
+	event := audit.EventLogin
+	if err != nil {
+		event = audit.EventLoginFailed
+	}
+	audit.Log(audit.Record{
+		Class:        audit.ClassConnection,
+		Event:        event,
+		ConnectionID: c.ConnectionID,
+		Protocol:     metrics.ProtocolMySQL,
+		User:         c.User,
+		Host:         remoteAddr(c),
+		Error:        audit.ErrorString(err),
+	})
+}
+
+// auditQuery records a statement received on the MySQL port, either as a query or as the execution of a prepared statement.
+func (h *MyHandler) auditQuery(c *mysql.Conn, event, query string, start time.Time, rows int64, err error) {
+	audit.Log(audit.Record{
+		Time:         start,
+		Class:        audit.ClassStatement,
+		Event:        event,
+		ConnectionID: c.ConnectionID,
+		Protocol:     metrics.ProtocolMySQL,
+		User:         c.User,
+		Host:         remoteAddr(c),
+		Schema:       h.pool.Database(c.ConnectionID),
+		Statement:    query,
+		DurationUs:   time.Since(start).Microseconds(),
+		Rows:         rows,
+		Error:        audit.ErrorString(err),
+	})
+}
+
+// countRows counts the rows returned or affected by the results passed to |callback|.
+func countRows(callback mysql.ResultSpoolFn, rows *int64) mysql.ResultSpoolFn {
+	return func(res *sqltypes.Result, more bool) error {
+		*rows += resultRows(res)
+		return callback(res, more)
+	}
+}
+
+// resultRows returns the number of rows returned by |res|, or affected by it if it has no result set.
+// go-mysql-server also sets RowsAffected to the number of rows returned.
+func resultRows(res *sqltypes.Result) int64 {
+	switch {
+	case res == nil:
+		return 0
+	case len(res.Fields) > 0 || len(res.Rows) > 0:
+		return int64(len(res.Rows))
+	default:
+		return int64(res.RowsAffected)
+	}
+}
+
+func remoteAddr(c *mysql.Conn) string {
+	if addr := c.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+// accountChange returns the kind of the statement if |n| changes the accounts or their privileges.
+func accountChange(n sql.Node) string {
+	switch n.(type) {
+	case *plan.CreateUser:
+		return "CREATE USER"
+	case *plan.AlterUser:
+		return "ALTER USER"
+	case *plan.DropUser:
+		return "DROP USER"
+	case *plan.RenameUser:
+		return "RENAME USER"
+	case *plan.CreateRole:
+		return "CREATE ROLE"
+	case *plan.DropRole:
+		return "DROP ROLE"
+	case *plan.Grant, *plan.GrantRole, *plan.GrantProxy:
+		return "GRANT"
+	case *plan.Revoke, *plan.RevokeAll, *plan.RevokeRole, *plan.RevokeProxy:
+		return "REVOKE"
+	}
+	return ""
+}
+
+// auditAccountChange records a statement that changed the accounts or their privileges.
+func auditAccountChange(ctx *sql.Context, kind string, err error) {
+	client := ctx.Session.Client()
+	audit.Log(audit.Record{
+		Class:        audit.ClassAccount,
+		Event:        kind,
+		ConnectionID: ctx.Session.ID(),
+		Protocol:     metrics.ProtocolMySQL, // account statements are only parsed on the MySQL port
+		User:         client.User,
+		Host:         client.Address,
+		Schema:       ctx.GetCurrentDatabase(),
+		Statement:    ctx.Query(),
+		Error:        audit.ErrorString(err),
+	})
+}
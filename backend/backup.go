@@ -0,0 +1,82 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apecloud/myduckserver/backup"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/sirupsen/logrus"
+)
+
+// backupRegex matches BACKUP TO '<dir>', which is not a MySQL statement and is handled
+// before the query reaches the engine.
+var backupRegex = regexp.MustCompile(`(?is)^\s*BACKUP\s+TO\s+('(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*")\s*;?\s*$`)
+
+// parseBackup returns the target directory of a BACKUP TO statement.
+func parseBackup(query string) (string, bool) {
+	m := backupRegex.FindStringSubmatch(query)
+	if m == nil {
+		return "", false
+	}
+	quote := m[1][:1]
+	s := m[1][1 : len(m[1])-1]
+	s = strings.ReplaceAll(s, quote+quote, quote)
+	s = strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`).Replace(s)
+	return s, true
+}
+
+// comBackup takes a backup of the database into the directory |target| on the server.
+// Like CLONE, it requires the CLONE_ADMIN privilege (or SUPER).
+func (h *MyHandler) comBackup(ctx context.Context, c *mysql.Conn, target string, callback mysql.ResultSpoolFn) error {
+	sqlCtx, err := h.NewContext(ctx, c, "")
+	if err != nil {
+		return err
+	}
+	if db := h.engine.Analyzer.Catalog.MySQLDb; db.Enabled() {
+		if !db.UserHasPrivileges(sqlCtx, sql.NewDynamicPrivilegedOperation(plan.DynamicPrivilege_CloneAdmin)) {
+			client := sqlCtx.Session.Client()
+			return sql.ErrPrivilegeCheckFailed.New(fmt.Sprintf("'%s'@'%s'", client.User, client.Address))
+		}
+	}
+
+	manifest, err := backup.Run(ctx, h.pool.DB, h.pool.catalog, target)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	gtidSet := strings.TrimPrefix(manifest.BinlogPosition, "MySQL56/")
+	logrus.WithFields(logrus.Fields{
+		"path":              target,
+		"executed_gtid_set": gtidSet,
+	}).Infoln("Backup completed")
+
+	return callback(&sqltypes.Result{
+		Fields: []*query.Field{
+			{Name: "Path", Type: query.Type_VARCHAR, Charset: mysql.CharacterSetUtf8},
+			{Name: "Executed_Gtid_Set", Type: query.Type_VARCHAR, Charset: mysql.CharacterSetUtf8},
+		},
+		Rows: [][]sqltypes.Value{{
+			sqltypes.MakeTrusted(query.Type_VARCHAR, []byte(target)),
+			sqltypes.MakeTrusted(query.Type_VARCHAR, []byte(gtidSet)),
+		}},
+	}, false)
+}
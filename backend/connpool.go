@@ -18,6 +18,7 @@ import (
 	stdsql "database/sql"
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/apecloud/myduckserver/catalog"
 	"github.com/dolthub/go-mysql-server/sql"
@@ -30,9 +31,15 @@ type ConnectionPool struct {
 	connector *duckdb.Connector
 	catalog   string
 	conns     sync.Map // concurrent-safe map[uint32]*stdsql.Conn
+	states    sync.Map // concurrent-safe map[uint32]*connState
 	txns      sync.Map // concurrent-safe map[uint32]*stdsql.Tx
+	databases sync.Map // concurrent-safe map[uint32]string, the current database of each session
+	closed    atomic.Bool
 }
 
+// ErrPoolClosed is returned to the sessions that run a query after their connections are closed on shutdown.
+var ErrPoolClosed = errors.New("the server is shutting down")
+
 func NewConnectionPool(catalog string, connector *duckdb.Connector, db *stdsql.DB) *ConnectionPool {
 	return &ConnectionPool{
 		DB:        db,
@@ -59,6 +66,7 @@ func (p *ConnectionPool) CurrentSchema(id uint32) string {
 		logrus.WithError(err).Error("Failed to get current schema")
 		return ""
 	}
+	p.setSchema(id, schema)
 	return schema
 }
 
@@ -66,11 +74,20 @@ func (p *ConnectionPool) GetConn(ctx context.Context, id uint32) (*stdsql.Conn,
 	var conn *stdsql.Conn
 	entry, ok := p.conns.Load(id)
 	if !ok {
+		if p.closed.Load() {
+			return nil, ErrPoolClosed
+		}
 		c, err := p.DB.Conn(ctx)
 		if err != nil {
 			return nil, err
 		}
+		state, err := newConnState(ctx, c)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
 		p.conns.Store(id, c)
+		p.states.Store(id, state)
 		conn = c
 	} else {
 		conn = entry.(*stdsql.Conn)
@@ -98,6 +115,7 @@ func (p *ConnectionPool) GetConnForSchema(ctx context.Context, id uint32, schema
 				return nil, err
 			}
 		}
+		p.setSchema(id, schemaName)
 	}
 
 	return conn, nil
@@ -105,6 +123,10 @@ func (p *ConnectionPool) GetConnForSchema(ctx context.Context, id uint32, schema
 
 func (p *ConnectionPool) CloseConn(id uint32) error {
 	defer p.conns.Delete(id)
+	if state, ok := p.states.LoadAndDelete(id); ok {
+		state.(*connState).close()
+	}
+	p.databases.Delete(id)
 	entry, ok := p.conns.Load(id)
 	if ok {
 		conn := entry.(*stdsql.Conn)
@@ -116,6 +138,16 @@ func (p *ConnectionPool) CloseConn(id uint32) error {
 	return nil
 }
 
+// NumConns returns the number of connections held by client sessions.
+func (p *ConnectionPool) NumConns() int {
+	n := 0
+	p.conns.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
 func (p *ConnectionPool) GetTxn(ctx context.Context, id uint32, schemaName string, options *stdsql.TxOptions) (*stdsql.Tx, error) {
 	var tx *stdsql.Tx
 	entry, ok := p.txns.Load(id)
@@ -148,10 +180,15 @@ func (p *ConnectionPool) CloseTxn(id uint32) {
 	p.txns.Delete(id)
 }
 
-func (p *ConnectionPool) Close() error {
+// CloseConns rolls back the transactions of the client sessions and closes their connections,
+// waiting for the statements running on them. The sessions cannot open new connections afterwards.
+func (p *ConnectionPool) CloseConns() error {
+	p.closed.Store(true)
+
 	var txns []*stdsql.Tx
-	p.txns.Range(func(_, value any) bool {
+	p.txns.Range(func(key, value any) bool {
 		txns = append(txns, value.(*stdsql.Tx))
+		p.txns.Delete(key)
 		return true
 	})
 	var lastErr error
@@ -163,8 +200,12 @@ func (p *ConnectionPool) Close() error {
 	}
 
 	var conns []*stdsql.Conn
-	p.conns.Range(func(_, value any) bool {
+	p.conns.Range(func(key, value any) bool {
 		conns = append(conns, value.(*stdsql.Conn))
+		p.conns.Delete(key)
+		if state, ok := p.states.LoadAndDelete(key); ok {
+			state.(*connState).close()
+		}
 		return true
 	})
 	for _, conn := range conns {
@@ -173,5 +214,9 @@ func (p *ConnectionPool) Close() error {
 			lastErr = err
 		}
 	}
-	return errors.Join(lastErr, p.DB.Close())
+	return lastErr
+}
+
+func (p *ConnectionPool) Close() error {
+	return errors.Join(p.CloseConns(), p.DB.Close())
 }
@@ -14,10 +14,14 @@
 package backend
 
 import (
+	"context"
 	stdsql "database/sql"
 	"fmt"
+	"time"
 
+	"github.com/apecloud/myduckserver/audit"
 	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/plugin"
 	"github.com/apecloud/myduckserver/transpiler"
 	"github.com/dolthub/go-mysql-server/sql"
 	"github.com/dolthub/go-mysql-server/sql/expression"
@@ -34,7 +38,8 @@ type DuckBuilder struct {
 
 	provider *catalog.DatabaseProvider
 
-	FlushDeltaBuffer func() error
+	// FlushDeltaBuffer merges the buffered replicated changes to |tables| into the database.
+	FlushDeltaBuffer func(ctx *sql.Context, tables []sql.TableNode) error
 }
 
 var _ sql.NodeExecBuilder = (*DuckBuilder)(nil)
@@ -52,14 +57,27 @@ func (b *DuckBuilder) Provider() *catalog.DatabaseProvider {
 }
 
 func (b *DuckBuilder) Build(ctx *sql.Context, root sql.Node, r sql.Row) (sql.RowIter, error) {
-	// Flush the delta buffer before executing the query.
-	// TODO(fan): Be fine-grained and flush only when the replicated tables are touched.
-	if b.FlushDeltaBuffer != nil {
-		if err := b.FlushDeltaBuffer(); err != nil {
+	if kind := accountChange(root); kind != "" && audit.Enabled() {
+		// The accounts are changed when the node is built.
+		iter, err := b.build(ctx, root, r)
+		auditAccountChange(ctx, kind, err)
+		return iter, err
+	}
+	return b.build(ctx, root, r)
+}
+
+func (b *DuckBuilder) build(ctx *sql.Context, root sql.Node, r sql.Row) (sql.RowIter, error) {
+	if !plan.IsReadOnly(root) {
+		if err := CheckWritable(ctx); err != nil {
 			return nil, err
 		}
 	}
 
+	// Flush the delta buffers of the tables touched by the query before executing it.
+	if err := b.flushDeltaBuffer(ctx, root); err != nil {
+		return nil, err
+	}
+
 	n := root
 	ctx.GetLogger().WithFields(logrus.Fields{
 		"Query":    ctx.Query(),
@@ -77,6 +95,24 @@ func (b *DuckBuilder) Build(ctx *sql.Context, root sql.Node, r sql.Row) (sql.Row
 		*plan.Set, *plan.ShowVariables,
 		*plan.AlterDefaultSet, *plan.AlterDefaultDrop:
 		return b.base.Build(ctx, root, r)
+	case *plan.CreateUser, *plan.AlterUser:
+		// The SCRAM verifiers for the PostgreSQL port are derived from the passwords in clear text.
+		verifiers, err := plugin.DerivePasswordVerifiers(n)
+		if err != nil {
+			return nil, err
+		}
+		n, err := plugin.HashPasswords(n)
+		if err != nil {
+			return nil, err
+		}
+		iter, err := b.base.Build(ctx, n, r)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifiers.Store(ctx); err != nil {
+			return nil, err
+		}
+		return iter, nil
 	case *plan.InsertInto:
 		insert := n.(*plan.InsertInto)
 		src := insert.Source
@@ -92,7 +128,7 @@ func (b *DuckBuilder) Build(ctx *sql.Context, root sql.Node, r sql.Row) (sql.Row
 	}
 
 	// Fallback to the base builder if the plan contains system/user variables or is not a pure data query.
-	if containsVariable(n) || !IsPureDataQuery(n) {
+	if !isPushedDown(n) {
 		return b.base.Build(ctx, root, r)
 	}
 
@@ -152,7 +188,7 @@ func (b *DuckBuilder) executeQuery(ctx *sql.Context, n sql.Node, conn *stdsql.Co
 	case *plan.ShowTables:
 		duckSQL = ctx.Query()
 	default:
-		duckSQL, err = transpiler.TranslateWithSQLGlot(ctx.Query())
+		duckSQL, err = transpiler.Translate(ctx.Query())
 	}
 	if err != nil {
 		return nil, catalog.ErrTranspiler.New(err)
@@ -162,19 +198,39 @@ func (b *DuckBuilder) executeQuery(ctx *sql.Context, n sql.Node, conn *stdsql.Co
 		"Query":   ctx.Query(),
 		"DuckSQL": duckSQL,
 	}).Trace("Executing Query...")
+	b.pool.RecordQuery(ctx.ID(), duckSQL)
 
-	// Execute the DuckDB query
-	rows, err := conn.QueryContext(ctx.Context, duckSQL)
+	// Execute the DuckDB query, within max_execution_time if it is a SELECT statement
+	var timeout time.Duration
+	if plan.IsReadOnly(n) {
+		timeout = MaxExecutionTime(ctx)
+	}
+	if timeout <= 0 {
+		rows, err := conn.QueryContext(ctx.Context, duckSQL)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLRowIter(rows, n.Schema())
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx.Context, timeout)
+	rows, err := conn.QueryContext(queryCtx, duckSQL)
+	if err != nil {
+		cancel()
+		return nil, deadlineExceeded(ctx, queryCtx, err)
+	}
+	iter, err := NewSQLRowIter(rows, n.Schema())
 	if err != nil {
+		rows.Close()
+		cancel()
 		return nil, err
 	}
-
-	return NewSQLRowIter(rows, n.Schema())
+	return &deadlineRowIter{iter, queryCtx, cancel}, nil
 }
 
 func (b *DuckBuilder) executeDML(ctx *sql.Context, conn *stdsql.Conn) (sql.RowIter, error) {
 	// Translate the MySQL query to a DuckDB query
-	duckSQL, err := transpiler.TranslateWithSQLGlot(ctx.Query())
+	duckSQL, err := transpiler.Translate(ctx.Query())
 	if err != nil {
 		return nil, catalog.ErrTranspiler.New(err)
 	}
@@ -183,6 +239,7 @@ func (b *DuckBuilder) executeDML(ctx *sql.Context, conn *stdsql.Conn) (sql.RowIt
 		"Query":   ctx.Query(),
 		"DuckSQL": duckSQL,
 	}).Trace("Executing DML...")
+	b.pool.RecordQuery(ctx.ID(), duckSQL)
 
 	// Execute the DuckDB query
 	result, err := conn.ExecContext(ctx.Context, duckSQL)
@@ -206,6 +263,11 @@ func (b *DuckBuilder) executeDML(ctx *sql.Context, conn *stdsql.Conn) (sql.RowIt
 	})), nil
 }
 
+// isPushedDown inspects if the plan is executed by DuckDB rather than by the engine.
+func isPushedDown(n sql.Node) bool {
+	return !containsVariable(n) && IsPureDataQuery(n)
+}
+
 // containsVariable inspects if the plan contains a system or user variable.
 func containsVariable(n sql.Node) bool {
 	found := false
@@ -0,0 +1,156 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/transpiler"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"github.com/sirupsen/logrus"
+)
+
+// explainRegex matches EXPLAIN [ANALYZE] [FORMAT=<format>] on a SELECT statement, without its semicolon.
+// The engine rejects FORMAT=JSON, so the statement is handled before the query reaches the engine.
+var explainRegex = regexp.MustCompile(`(?is)^\s*(?:EXPLAIN|DESCRIBE|DESC)\s+(ANALYZE\s+)?(?:FORMAT\s*=\s*(\w+)\s+)?((?:(?:SELECT|WITH)\b|\().*?)\s*$`)
+
+type explainStmt struct {
+	statement string // the EXPLAIN statement
+	query     string // the explained query
+	analyze   bool
+	json      bool
+}
+
+// parseExplain returns the EXPLAIN statement of |query|, if it is one. A query of several statements
+// is not, as the explained query would take in the next statements.
+func parseExplain(query string) (explainStmt, bool) {
+	stmt, remainder, err := sqlparser.SplitStatement(query)
+	if err != nil || strings.TrimSpace(remainder) != "" {
+		return explainStmt{}, false
+	}
+	m := explainRegex.FindStringSubmatch(stmt)
+	if m == nil {
+		return explainStmt{}, false
+	}
+	return explainStmt{
+		statement: stmt,
+		query:     m[3],
+		analyze:   m[1] != "",
+		json:      strings.EqualFold(m[2], "json"),
+	}, true
+}
+
+// duckSQL returns the DuckDB statement that explains |duckQuery| as requested by |stmt|.
+func (stmt explainStmt) duckSQL(duckQuery string) string {
+	switch {
+	case stmt.analyze && stmt.json:
+		return "EXPLAIN (ANALYZE, FORMAT JSON) " + duckQuery
+	case stmt.analyze:
+		return "EXPLAIN ANALYZE " + duckQuery
+	case stmt.json:
+		return "EXPLAIN (FORMAT JSON) " + duckQuery
+	default:
+		return "EXPLAIN " + duckQuery
+	}
+}
+
+// comExplain explains a query that is pushed down to DuckDB with the translated query and the plan of DuckDB,
+// or its profile for EXPLAIN ANALYZE. It returns false if the query is not pushed down,
+// in which case the statement is left to the engine.
+func (h *MyHandler) comExplain(ctx context.Context, c *mysql.Conn, stmt explainStmt, callback mysql.ResultSpoolFn) (bool, error) {
+	sqlCtx, err := h.NewContext(ctx, c, stmt.statement)
+	if err != nil {
+		return false, err
+	}
+	// The engine reports the errors of the query, such as a missing privilege.
+	n, err := h.engine.AnalyzeQuery(sqlCtx, stmt.query)
+	if err != nil || !plan.IsReadOnly(n) || !isPushedDown(n) {
+		return false, nil
+	}
+
+	duckQuery, err := transpiler.Translate(stmt.query)
+	if err != nil {
+		return true, catalog.ErrTranspiler.New(err)
+	}
+	duckSQL := stmt.duckSQL(duckQuery)
+
+	sqlCtx, err = sqlCtx.ProcessList.BeginQuery(sqlCtx, stmt.statement)
+	if err != nil {
+		return true, err
+	}
+	defer sqlCtx.ProcessList.EndQuery(sqlCtx)
+
+	sqlCtx.GetLogger().WithFields(logrus.Fields{
+		"Query":   stmt.statement,
+		"DuckSQL": duckSQL,
+	}).Trace("Explaining Query...")
+
+	// EXPLAIN ANALYZE runs the query, so it reads the replicated changes as the query would.
+	if builder, ok := h.engine.Analyzer.ExecBuilder.(*DuckBuilder); ok && stmt.analyze {
+		if err := builder.flushDeltaBuffer(sqlCtx, n); err != nil {
+			return true, err
+		}
+	}
+
+	conn, err := h.pool.GetConnForSchema(sqlCtx, c.ConnectionID, sqlCtx.GetCurrentDatabase())
+	if err != nil {
+		return true, err
+	}
+	h.pool.RecordQuery(c.ConnectionID, duckSQL)
+
+	// EXPLAIN ANALYZE runs the query, within max_execution_time
+	queryCtx := sqlCtx.Context
+	if timeout := MaxExecutionTime(sqlCtx); stmt.analyze && timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(queryCtx, timeout)
+		defer cancel()
+	}
+	rows, err := conn.QueryContext(queryCtx, duckSQL)
+	if err != nil {
+		return true, deadlineExceeded(sqlCtx, queryCtx, err)
+	}
+	defer rows.Close()
+
+	// DuckDB returns the plan as (explain_key, explain_value) pairs.
+	var plans []string
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return true, err
+		}
+		plans = append(plans, value)
+	}
+	if err := rows.Err(); err != nil {
+		return true, deadlineExceeded(sqlCtx, queryCtx, err)
+	}
+
+	return true, callback(&sqltypes.Result{
+		Fields: []*query.Field{
+			{Name: "EXPLAIN", Type: query.Type_VARCHAR, Charset: mysql.CharacterSetUtf8},
+			{Name: "DuckDB_SQL", Type: query.Type_VARCHAR, Charset: mysql.CharacterSetUtf8},
+		},
+		Rows: [][]sqltypes.Value{{
+			sqltypes.MakeTrusted(query.Type_VARCHAR, []byte(strings.Join(plans, "\n"))),
+			sqltypes.MakeTrusted(query.Type_VARCHAR, []byte(duckQuery)),
+		}},
+	}, false)
+}
@@ -0,0 +1,49 @@
+package backend
+
+import "testing"
+
+func TestParseExplain(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		want  explainStmt
+	}{
+		{"EXPLAIN SELECT 1", explainStmt{statement: "EXPLAIN SELECT 1", query: "SELECT 1"}},
+		{"  explain analyze select * from t;  ", explainStmt{statement: "  explain analyze select * from t", query: "select * from t", analyze: true}},
+		{"EXPLAIN FORMAT=JSON WITH c AS (SELECT 1) SELECT * FROM c", explainStmt{statement: "EXPLAIN FORMAT=JSON WITH c AS (SELECT 1) SELECT * FROM c", query: "WITH c AS (SELECT 1) SELECT * FROM c", json: true}},
+		{"DESC ANALYZE FORMAT = json (SELECT 1)", explainStmt{statement: "DESC ANALYZE FORMAT = json (SELECT 1)", query: "(SELECT 1)", analyze: true, json: true}},
+		{"EXPLAIN SELECT ';'\n", explainStmt{statement: "EXPLAIN SELECT ';'\n", query: "SELECT ';'"}},
+	} {
+		got, ok := parseExplain(tt.query)
+		if !ok || got != tt.want {
+			t.Errorf("parseExplain(%q) = %+v, %v; want %+v", tt.query, got, ok, tt.want)
+		}
+	}
+
+	for _, query := range []string{
+		"EXPLAIN SELECT 1; DROP TABLE t",
+		"EXPLAIN SELECT 1; SELECT 2;",
+		"EXPLAIN INSERT INTO t VALUES (1)",
+		"EXPLAIN t",
+		"SELECT 1",
+	} {
+		if got, ok := parseExplain(query); ok {
+			t.Errorf("parseExplain(%q) = %+v; want no EXPLAIN statement", query, got)
+		}
+	}
+}
+
+func TestExplainDuckSQL(t *testing.T) {
+	for _, tt := range []struct {
+		stmt explainStmt
+		want string
+	}{
+		{explainStmt{}, "EXPLAIN SELECT 1"},
+		{explainStmt{analyze: true}, "EXPLAIN ANALYZE SELECT 1"},
+		{explainStmt{json: true}, "EXPLAIN (FORMAT JSON) SELECT 1"},
+		{explainStmt{analyze: true, json: true}, "EXPLAIN (ANALYZE, FORMAT JSON) SELECT 1"},
+	} {
+		if got := tt.stmt.duckSQL("SELECT 1"); got != tt.want {
+			t.Errorf("%+v.duckSQL() = %q; want %q", tt.stmt, got, tt.want)
+		}
+	}
+}
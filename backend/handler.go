@@ -17,23 +17,53 @@ package backend
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/metrics"
+
+	sqle "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/server"
 	"github.com/dolthub/vitess/go/mysql"
 	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/dolthub/vitess/go/vt/sqlparser"
 )
 
 type MyHandler struct {
 	*server.Handler
-	pool *ConnectionPool
+	pool   *ConnectionPool
+	engine *sqle.Engine
+
+	// loggedIn holds the IDs of the connections whose login has been audited.
+	loggedIn sync.Map
 }
 
 func (h *MyHandler) ConnectionClosed(c *mysql.Conn) {
+	h.loggedIn.Delete(c.ConnectionID)
 	h.pool.CloseConn(c.ConnectionID)
 	h.Handler.ConnectionClosed(c)
 }
 
+// ConnectionAborted is called when a connection fails before it is established, e.g., on a wrong password.
+func (h *MyHandler) ConnectionAborted(c *mysql.Conn, reason string) error {
+	if audit.Enabled() {
+		auditLogin(c, fmt.Errorf("%s", reason))
+	}
+	return h.Handler.ConnectionAborted(c, reason)
+}
+
 func (h *MyHandler) ComInitDB(c *mysql.Conn, schemaName string) error {
+	err := h.comInitDB(c, schemaName)
+	// The first COM_INIT_DB of a connection is issued by the server once the client is authenticated.
+	if _, loaded := h.loggedIn.LoadOrStore(c.ConnectionID, struct{}{}); !loaded && audit.Enabled() {
+		auditLogin(c, err)
+	}
+	return err
+}
+
+func (h *MyHandler) comInitDB(c *mysql.Conn, schemaName string) error {
 	_, err := h.pool.GetConnForSchema(context.Background(), c.ConnectionID, schemaName)
 	if err != nil {
 		return err
@@ -58,10 +88,43 @@ func (h *MyHandler) ComMultiQuery(
 	query string,
 	callback mysql.ResultSpoolFn,
 ) (string, error) {
+	start := time.Now()
 	var modifiers []ResultModifier
 	query, modifiers = applyRequestModifiers(query, defaultRequestModifiers)
+	callback = wrapResultCallback(callback, modifiers...)
+	var rows int64
+	if audit.Enabled() {
+		callback = countRows(callback, &rows)
+	}
 
-	return h.Handler.ComMultiQuery(ctx, c, query, wrapResultCallback(callback, modifiers...))
+	var (
+		remainder string
+		handled   bool
+		err       error
+	)
+	// The statements handled outside the engine are recognized at the start of the query,
+	// and the next statements are passed to the next call.
+	if stmt, rest, splitErr := sqlparser.SplitStatement(query); splitErr == nil {
+		remainder = strings.TrimLeft(rest, " \t\r\n")
+		more := remainder != ""
+		handled, err = h.comQueryOutsideEngine(ctx, c, stmt, func(res *sqltypes.Result, _ bool) error {
+			return callback(res, more)
+		})
+	}
+	if !handled {
+		remainder, err = h.Handler.ComMultiQuery(ctx, c, query, callback)
+	}
+	err = QueryInterrupted(err)
+	metrics.ObserveQuery(metrics.ProtocolMySQL, start, err)
+	if audit.Enabled() {
+		// Only the first statement has been executed; the remainder is passed to the next call.
+		executed := query
+		if err == nil && len(remainder) <= len(query) {
+			executed = query[:len(query)-len(remainder)]
+		}
+		h.auditQuery(c, audit.EventQuery, executed, start, rows, err)
+	}
+	return remainder, err
 }
 
 // Naive query rewriting. This is just a temporary solution
@@ -72,13 +135,63 @@ func (h *MyHandler) ComQuery(
 	query string,
 	callback mysql.ResultSpoolFn,
 ) error {
+	start := time.Now()
 	var modifiers []ResultModifier
 	query, modifiers = applyRequestModifiers(query, defaultRequestModifiers)
+	callback = wrapResultCallback(callback, modifiers...)
+	var rows int64
+	if audit.Enabled() {
+		callback = countRows(callback, &rows)
+	}
 
-	return h.Handler.ComQuery(ctx, c, query, wrapResultCallback(callback, modifiers...))
+	handled, err := h.comQueryOutsideEngine(ctx, c, query, callback)
+	if !handled {
+		err = h.Handler.ComQuery(ctx, c, query, callback)
+	}
+	err = QueryInterrupted(err)
+	metrics.ObserveQuery(metrics.ProtocolMySQL, start, err)
+	if audit.Enabled() {
+		h.auditQuery(c, audit.EventQuery, query, start, rows, err)
+	}
+	return err
+}
+
+// comQueryOutsideEngine handles the statements that are not passed to the engine, which are either
+// not supported by it or answered differently. It returns false for the other statements.
+func (h *MyHandler) comQueryOutsideEngine(ctx context.Context, c *mysql.Conn, query string, callback mysql.ResultSpoolFn) (bool, error) {
+	if target, ok := parseBackup(query); ok {
+		return true, h.comBackup(ctx, c, target, callback)
+	}
+	if stmt, ok := parseExplain(query); ok {
+		return h.comExplain(ctx, c, stmt, callback)
+	}
+	if full, ok := ParseShowProcessList(query); ok {
+		return true, h.comShowProcessList(ctx, c, full, callback)
+	}
+	return false, nil
+}
+
+// ComStmtExecute executes a prepared statement.
+func (h *MyHandler) ComStmtExecute(ctx context.Context, c *mysql.Conn, prepare *mysql.PrepareData, callback func(*sqltypes.Result) error) error {
+	start := time.Now()
+	var rows int64
+	if audit.Enabled() {
+		next := callback
+		callback = func(res *sqltypes.Result) error {
+			rows += resultRows(res)
+			return next(res)
+		}
+	}
+
+	err := QueryInterrupted(h.Handler.ComStmtExecute(ctx, c, prepare, callback))
+	metrics.ObserveQuery(metrics.ProtocolMySQL, start, err)
+	if audit.Enabled() {
+		h.auditQuery(c, audit.EventExecute, prepare.PrepareStmt, start, rows, err)
+	}
+	return err
 }
 
-func WrapHandler(pool *ConnectionPool) server.HandlerWrapper {
+func WrapHandler(pool *ConnectionPool, engine *sqle.Engine) server.HandlerWrapper {
 	return func(h mysql.Handler) (mysql.Handler, error) {
 		handler, ok := h.(*server.Handler)
 		if !ok {
@@ -88,6 +201,7 @@ func WrapHandler(pool *ConnectionPool) server.HandlerWrapper {
 		return &MyHandler{
 			Handler: handler,
 			pool:    pool,
+			engine:  engine,
 		}, nil
 	}
 }
@@ -0,0 +1,66 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/marcboeker/go-duckdb"
+)
+
+// ErrQueryInterrupted is returned for a query killed by KILL QUERY, KILL or a PostgreSQL CancelRequest.
+// It carries the error code of MySQL (ER_QUERY_INTERRUPTED).
+var ErrQueryInterrupted = mysql.NewSQLError(mysql.ERQueryInterrupted, mysql.SSQueryInterrupted,
+	"Query execution was interrupted")
+
+// ErrQueryTimeout is returned for a query that exceeds max_execution_time or statement_timeout.
+// It carries the error code of MySQL (ER_QUERY_TIMEOUT).
+var ErrQueryTimeout = mysql.NewSQLError(mysql.ERQueryTimeout, mysql.SSUnknownSQLState,
+	"Query execution was interrupted, maximum statement execution time exceeded")
+
+// QueryInterrupted returns ErrQueryInterrupted if err results from the cancellation of the query,
+// ErrQueryTimeout if it results from the expiry of its deadline, and err otherwise.
+//
+// KILL cancels the context of the query, on which the DuckDB driver interrupts the connection
+// that runs it. The connection can be used again by the next query of the session.
+func QueryInterrupted(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrQueryInterrupted
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrQueryTimeout
+	}
+	var duckErr *duckdb.Error
+	if errors.As(err, &duckErr) && duckErr.Type == duckdb.ErrorTypeInterrupt {
+		return ErrQueryInterrupted
+	}
+	// The errors that do not carry a MySQL error code are cast to ER_UNKNOWN_ERROR by the engine,
+	// which only keeps their messages.
+	var sqlErr *mysql.SQLError
+	if errors.As(err, &sqlErr) && sqlErr.Num == mysql.ERUnknownError {
+		switch sqlErr.Message {
+		case context.Canceled.Error():
+			return ErrQueryInterrupted
+		case context.DeadlineExceeded.Error():
+			return ErrQueryTimeout
+		}
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/marcboeker/go-duckdb"
+)
+
+func TestQueryInterrupted(t *testing.T) {
+	other := errors.New("syntax error")
+	catalogErr := &duckdb.Error{Type: duckdb.ErrorTypeCatalog, Msg: "Catalog Error: Table t does not exist"}
+	for _, tt := range []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{other, other},
+		{fmt.Errorf("query failed: %w", context.Canceled), ErrQueryInterrupted},
+		{fmt.Errorf("query failed: %w", context.DeadlineExceeded), ErrQueryTimeout},
+		{&duckdb.Error{Type: duckdb.ErrorTypeInterrupt, Msg: "INTERRUPT Error: Interrupted!"}, ErrQueryInterrupted},
+		{catalogErr, catalogErr},
+		{mysql.NewSQLError(mysql.ERUnknownError, mysql.SSUnknownSQLState, "%s", context.Canceled.Error()), ErrQueryInterrupted},
+		{mysql.NewSQLError(mysql.ERUnknownError, mysql.SSUnknownSQLState, "%s", context.DeadlineExceeded.Error()), ErrQueryTimeout},
+	} {
+		if got := QueryInterrupted(tt.err); got != tt.want {
+			t.Errorf("QueryInterrupted(%v) = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -200,6 +200,7 @@ func (db *DuckBuilder) executeLoadData(ctx *sql.Context, insert *plan.InsertInto
 	// Execute the DuckDB INSERT INTO statement.
 	duckSQL := b.String()
 	ctx.GetLogger().Trace(duckSQL)
+	db.pool.RecordQuery(ctx.ID(), duckSQL)
 
 	result, err := adapter.Exec(ctx, duckSQL)
 	if err != nil {
@@ -0,0 +1,333 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+/*
+#include <stdint.h>
+
+// The progress API of DuckDB, which is linked in by go-duckdb but not exposed by it.
+typedef struct {
+	double percentage;
+	uint64_t rows_processed;
+	uint64_t total_rows_to_process;
+} duckdb_query_progress_type;
+
+duckdb_query_progress_type duckdb_query_progress(void *connection);
+*/
+import "C"
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/information_schema"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/go-mysql-server/sql/types"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/dolthub/vitess/go/sqltypes"
+	"github.com/dolthub/vitess/go/vt/proto/query"
+	"github.com/sirupsen/logrus"
+)
+
+// connState is what SHOW PROCESSLIST knows about the DuckDB connection of a session.
+// The connection itself cannot be queried while it is running a statement.
+type connState struct {
+	mu      sync.Mutex
+	handle  unsafe.Pointer // the duckdb_connection, which is nil once the connection is closed
+	schema  string         // the current schema, as last seen by the pool
+	query   string         // the last DuckDB statement run on the connection
+	queryAt time.Time
+}
+
+// newConnState enables the progress of the queries on the new connection |conn| and takes its DuckDB handle,
+// which go-duckdb keeps in its driver connection without exposing it. The handle is only used under s.mu,
+// and close clears it under s.mu before the connection is closed.
+func newConnState(ctx context.Context, conn *stdsql.Conn) (*connState, error) {
+	// DuckDB keeps track of the progress of a query only with the progress bar enabled, which is a per-connection setting.
+	for _, stmt := range []string{"SET enable_progress_bar = true", "SET enable_progress_bar_print = false"} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &connState{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := conn.Raw(func(driverConn any) error {
+		v := reflect.ValueOf(driverConn)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		if f := v.Elem().FieldByName("duckdbCon"); f.IsValid() && f.Kind() == reflect.Pointer {
+			s.handle = f.UnsafePointer()
+		}
+		return nil
+	})
+	if s.handle == nil {
+		logrus.Warn("The progress of the queries on a DuckDB connection is not available")
+	}
+	return s, err
+}
+
+// progress returns the percentage of the running statement that DuckDB has done, or -1 if it is unknown.
+// s.mu must be held, so that the connection is not closed meanwhile.
+func (s *connState) progress() float64 {
+	if s.handle == nil {
+		return -1
+	}
+	return float64(C.duckdb_query_progress(s.handle).percentage)
+}
+
+// close forgets the handle of the connection, which must be done before the connection is closed.
+func (s *connState) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handle = nil
+}
+
+func (p *ConnectionPool) setSchema(id uint32, schema string) {
+	if entry, ok := p.states.Load(id); ok {
+		s := entry.(*connState)
+		s.mu.Lock()
+		s.schema = schema
+		s.mu.Unlock()
+	}
+}
+
+// Database returns the current database of the session |id|, as set by the session itself,
+// without building a context for it.
+func (p *ConnectionPool) Database(id uint32) string {
+	if db, ok := p.databases.Load(id); ok {
+		return db.(string)
+	}
+	return ""
+}
+
+// RecordQuery records |query| as the DuckDB statement that the connection |id| is about to run.
+func (p *ConnectionPool) RecordQuery(id uint32, query string) {
+	if entry, ok := p.states.Load(id); ok {
+		s := entry.(*connState)
+		s.mu.Lock()
+		s.query, s.queryAt = query, time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// ProcessInfo is a session in SHOW PROCESSLIST, on either port.
+type ProcessInfo struct {
+	sql.Process
+	// DuckSQL is the DuckDB statement run by the current query, if any.
+	DuckSQL string
+	// DuckProgress is the percentage of DuckSQL done, or -1 if it is unknown.
+	DuckProgress float64
+}
+
+// Processes returns the sessions of |processList| ordered by ID, with the current schemas of their
+// DuckDB connections as their databases.
+func (p *ConnectionPool) Processes(processList sql.ProcessList) []ProcessInfo {
+	processes := processList.Processes()
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Connection < processes[j].Connection })
+
+	infos := make([]ProcessInfo, len(processes))
+	for i, proc := range processes {
+		info := ProcessInfo{Process: proc, DuckProgress: -1}
+		if entry, ok := p.states.Load(proc.Connection); ok {
+			s := entry.(*connState)
+			s.mu.Lock()
+			if s.schema != "" {
+				info.Database = s.schema
+			}
+			// The recorded statement belongs to the current query if it was run after the query started.
+			if proc.Command == sql.ProcessCommandQuery && !s.queryAt.Before(proc.StartedAt) {
+				info.DuckSQL = s.query
+				info.DuckProgress = s.progress()
+			}
+			s.mu.Unlock()
+		}
+		infos[i] = info
+	}
+	return infos
+}
+
+// VisibleProcesses returns the sessions that the user of |ctx| may see: all of them with the
+// PROCESS privilege, and its own ones otherwise.
+func VisibleProcesses(ctx *sql.Context, db *mysql_db.MySQLDb, processes []ProcessInfo) []ProcessInfo {
+	if !db.Enabled() || db.UserHasPrivileges(ctx, sql.NewPrivilegedOperation(sql.PrivilegeCheckSubject{}, sql.PrivilegeType_Process)) {
+		return processes
+	}
+	user := ctx.Session.Client().User
+	var visible []ProcessInfo
+	for _, proc := range processes {
+		if proc.User == user {
+			visible = append(visible, proc)
+		}
+	}
+	return visible
+}
+
+// State returns the state of the session in SHOW PROCESSLIST.
+func (info ProcessInfo) State() string {
+	if info.Command == sql.ProcessCommandQuery {
+		return "running"
+	}
+	return ""
+}
+
+// ProcessListColumns are the columns of SHOW PROCESSLIST, which adds the DuckDB statement of
+// the query and its progress in percent to the columns of MySQL.
+var ProcessListColumns = []string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info", "DuckDB_SQL", "Progress"}
+
+// ProcessListRow returns the row of the session in SHOW PROCESSLIST, with nil for NULL.
+// The statements are truncated to 100 characters unless |full| is set, as in MySQL.
+func (info ProcessInfo) ProcessListRow(full bool) []any {
+	text := func(s string) any {
+		if s == "" {
+			return nil
+		}
+		if r := []rune(s); !full && len(r) > 100 {
+			return string(r[:100])
+		}
+		return s
+	}
+	return []any{
+		int64(info.Connection), info.User, info.Host, text(info.Database), string(info.Command),
+		int64(info.Seconds()), info.State(), text(info.Query), text(info.DuckSQL), info.progress(),
+	}
+}
+
+// progress returns the progress of the DuckDB statement rounded to two decimals, or nil if it is unknown.
+func (info ProcessInfo) progress() any {
+	if info.DuckProgress < 0 {
+		return nil
+	}
+	return math.Round(info.DuckProgress*100) / 100
+}
+
+// showProcessListRegex matches SHOW [FULL] PROCESSLIST, whose result has more columns than that of the engine.
+var showProcessListRegex = regexp.MustCompile(`(?is)^\s*SHOW\s+(FULL\s+)?PROCESSLIST\s*;?\s*$`)
+
+// ParseShowProcessList reports whether |query| is SHOW [FULL] PROCESSLIST and whether it is FULL.
+func ParseShowProcessList(query string) (full bool, ok bool) {
+	m := showProcessListRegex.FindStringSubmatch(query)
+	if m == nil {
+		return false, false
+	}
+	return m[1] != "", true
+}
+
+// comShowProcessList lists the sessions of both ports with the DuckDB statements they are running.
+func (h *MyHandler) comShowProcessList(ctx context.Context, c *mysql.Conn, full bool, callback mysql.ResultSpoolFn) error {
+	sqlCtx, err := h.NewContext(ctx, c, "")
+	if err != nil {
+		return err
+	}
+	processes := VisibleProcesses(sqlCtx, h.engine.Analyzer.Catalog.MySQLDb, h.pool.Processes(h.engine.ProcessList))
+
+	fields := make([]*query.Field, len(ProcessListColumns))
+	for i, name := range ProcessListColumns {
+		fields[i] = &query.Field{Name: name, Type: query.Type_VARCHAR, Charset: mysql.CharacterSetUtf8}
+	}
+	fields[0].Type, fields[0].Charset = query.Type_INT64, mysql.CharacterSetBinary
+	fields[5].Type, fields[5].Charset = query.Type_INT64, mysql.CharacterSetBinary
+	fields[9].Type, fields[9].Charset = query.Type_FLOAT64, mysql.CharacterSetBinary
+
+	rows := make([][]sqltypes.Value, len(processes))
+	for i, proc := range processes {
+		values := proc.ProcessListRow(full)
+		row := make([]sqltypes.Value, len(values))
+		for j, v := range values {
+			switch v := v.(type) {
+			case nil:
+				row[j] = sqltypes.NULL
+			case int64:
+				row[j] = sqltypes.NewInt64(v)
+			case float64:
+				row[j] = sqltypes.NewFloat64(v)
+			case string:
+				row[j] = sqltypes.NewVarChar(v)
+			}
+		}
+		rows[i] = row
+	}
+	return callback(&sqltypes.Result{Fields: fields, Rows: rows}, false)
+}
+
+// infoSchemaDatabase is information_schema, whose PROCESSLIST table has the DuckDB statements of the sessions
+// in a DUCKDB_SQL column and their progress in a PROGRESS column.
+type infoSchemaDatabase struct {
+	sql.Database
+	processList *information_schema.InformationSchemaTable
+}
+
+// NewInformationSchema returns |infoSchema| with the PROCESSLIST table of |pool|.
+func NewInformationSchema(infoSchema sql.Database, pool *ConnectionPool) (sql.Database, error) {
+	table, ok, err := infoSchema.GetTableInsensitive(sql.NewEmptyContext(), information_schema.ProcessListTableName)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("information_schema.%s not found", information_schema.ProcessListTableName)
+	}
+	// DUCKDB_SQL is typed as INFO, the MySQL statement.
+	schema := table.Schema().Copy()
+	duckSQL := *schema[schema.IndexOfColName("INFO")]
+	duckSQL.Name = "DUCKDB_SQL"
+	progress := &sql.Column{Name: "PROGRESS", Type: types.Float64, Nullable: true, Source: duckSQL.Source}
+	schema = append(schema, &duckSQL, progress)
+	return &infoSchemaDatabase{
+		Database: infoSchema,
+		processList: &information_schema.InformationSchemaTable{
+			TableName:   information_schema.ProcessListTableName,
+			TableSchema: schema,
+			Reader:      pool.processListRowIter,
+		},
+	}, nil
+}
+
+func (db *infoSchemaDatabase) GetTableInsensitive(ctx *sql.Context, name string) (sql.Table, bool, error) {
+	if strings.EqualFold(name, information_schema.ProcessListTableName) {
+		return db.processList, true, nil
+	}
+	return db.Database.GetTableInsensitive(ctx, name)
+}
+
+// processListRowIter returns the rows of information_schema.PROCESSLIST, as the engine does.
+func (p *ConnectionPool) processListRowIter(ctx *sql.Context, _ sql.Catalog) (sql.RowIter, error) {
+	processes := p.Processes(ctx.ProcessList)
+	rows := make([]sql.Row, len(processes))
+	for i, proc := range processes {
+		var db, duckSQL any
+		if proc.Database != "" {
+			db = proc.Database
+		}
+		if proc.DuckSQL != "" {
+			duckSQL = proc.DuckSQL
+		}
+		rows[i] = sql.Row{
+			uint64(proc.Connection), proc.User, proc.Host, db, string(proc.Command),
+			int32(proc.Seconds()), proc.State(), proc.Query, duckSQL, proc.progress(),
+		}
+	}
+	return sql.RowsToRowIter(rows...), nil
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"context"
+	stdsql "database/sql"
+	"testing"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+func TestConnStateProgress(t *testing.T) {
+	connector, err := duckdb.NewConnector("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := stdsql.OpenDB(connector)
+	defer db.Close()
+	pool := NewConnectionPool("memory", connector, db)
+
+	const id = 1
+	conn, err := pool.GetConn(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DuckDB reports the progress of table scans, but not of table functions such as range.
+	if _, err := conn.ExecContext(context.Background(), "CREATE TABLE t AS SELECT range AS n FROM range(100000)"); err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := pool.states.Load(uint32(id))
+	state := entry.(*connState)
+	if state.handle == nil {
+		t.Fatal("the DuckDB handle of the connection is not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.ExecContext(ctx, "SELECT count(*) FROM t a, t b WHERE a.n < b.n")
+		done <- err
+	}()
+
+	var progress float64
+	for deadline := time.Now().Add(30 * time.Second); progress <= 0 && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		state.mu.Lock()
+		progress = state.progress()
+		state.mu.Unlock()
+	}
+	cancel()
+	<-done
+	if progress <= 0 || progress > 100 {
+		t.Errorf("progress = %v; want a percentage of the running query", progress)
+	}
+
+	if err := pool.CloseConn(id); err != nil {
+		t.Fatal(err)
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if got := state.progress(); got != -1 {
+		t.Errorf("progress after close = %v; want -1", got)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backend
+
+import (
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+// ErrReadOnly is returned for a write from a client while the server is read-only.
+// It carries the error code of MySQL (ER_OPTION_PREVENTS_STATEMENT).
+var ErrReadOnly = mysql.NewSQLError(mysql.EROptionPreventsStatement, mysql.SSUnknownSQLState,
+	"The MySQL server is running with the --read-only option so it cannot execute this statement")
+
+// SetReadOnly turns the read-only mode on or off, as SET GLOBAL super_read_only would.
+func SetReadOnly(readOnly bool) error {
+	if err := sql.SystemVariables.SetGlobal("read_only", readOnly); err != nil {
+		return err
+	}
+	return sql.SystemVariables.SetGlobal("super_read_only", readOnly)
+}
+
+// CheckWritable returns ErrReadOnly if the session must not write.
+//
+// As in MySQL, super_read_only rejects writes from all clients, while read_only
+// exempts the users with the SUPER privilege. The replication applier is never
+// rejected, so that a read-only replica keeps following its source.
+func CheckWritable(ctx *sql.Context) error {
+	if sess, ok := ctx.Session.(*Session); ok && sess.replica {
+		return nil
+	}
+	if globalBool("super_read_only") {
+		return ErrReadOnly
+	}
+	if globalBool("read_only") && !hasSuperPrivilege(ctx) {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+func globalBool(name string) bool {
+	_, v, ok := sql.SystemVariables.GetGlobal(name)
+	if !ok {
+		return false
+	}
+	b, ok := v.(int8)
+	return ok && b != 0
+}
+
+// hasSuperPrivilege checks the privilege set cached in the session during analysis.
+func hasSuperPrivilege(ctx *sql.Context) bool {
+	ps, counter := ctx.Session.GetPrivilegeSet()
+	return counter > 0 && ps != nil && ps.Has(sql.PrivilegeType_Super)
+}
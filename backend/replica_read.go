@@ -0,0 +1,85 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"strings"
+
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/transform"
+	"github.com/dolthub/go-mysql-server/sql/types"
+)
+
+const replicaReadConsistency = "myduck_replica_read_consistency"
+
+// RegisterReplicaReadConsistency registers the myduck_replica_read_consistency system variable.
+// With 'batched', the default, queries see the replicated transactions once their batch is committed.
+// With 'fresh', the buffered changes to the tables of a query are merged before it runs,
+// so that it sees all the transactions replicated so far. It applies to the MySQL port only,
+// since the queries of the PostgreSQL port are not planned by the engine.
+func RegisterReplicaReadConsistency() {
+	sql.SystemVariables.AddSystemVariables([]sql.SystemVariable{
+		&sql.MysqlSystemVariable{
+			Name:              replicaReadConsistency,
+			Scope:             sql.GetMysqlScope(sql.SystemVariableScope_Both),
+			Dynamic:           true,
+			SetVarHintApplies: false,
+			Type:              types.NewSystemEnumType(replicaReadConsistency, "batched", "fresh"),
+			Default:           "batched",
+		},
+	})
+}
+
+// readsFreshReplica reports whether the session of |ctx| reads fresh replicated data.
+// The replication applier writes the changes itself, so it never waits for them.
+func readsFreshReplica(ctx *sql.Context) bool {
+	if sess, ok := ctx.Session.(*Session); !ok || sess.replica {
+		return false
+	}
+	v, err := ctx.GetSessionVariable(ctx, replicaReadConsistency)
+	if err != nil {
+		return false
+	}
+	s, _ := v.(string)
+	return strings.EqualFold(s, "fresh")
+}
+
+// dataTables returns the data tables that |n| refers to, including those in subqueries and views.
+func dataTables(n sql.Node) []sql.TableNode {
+	c := &tableAndFuncCollector{}
+	transform.Walk(c, n)
+
+	var tables []sql.TableNode
+	for _, tn := range c.tables {
+		switch tn.UnderlyingTable().(type) {
+		case *catalog.Table, *catalog.IndexedTable:
+			tables = append(tables, tn)
+		}
+	}
+	return tables
+}
+
+// flushDeltaBuffer merges the buffered replicated changes to the data tables of |n| before it runs,
+// if the session reads fresh replicated data.
+func (b *DuckBuilder) flushDeltaBuffer(ctx *sql.Context, n sql.Node) error {
+	if b.FlushDeltaBuffer == nil || !readsFreshReplica(ctx) {
+		return nil
+	}
+	if tables := dataTables(n); len(tables) > 0 {
+		return b.FlushDeltaBuffer(ctx, tables)
+	}
+	return nil
+}
@@ -17,7 +17,6 @@ import (
 	"context"
 	stdsql "database/sql"
 	"fmt"
-	"strconv"
 
 	"github.com/sirupsen/logrus"
 
@@ -33,10 +32,18 @@ type Session struct {
 	*memory.Session
 	db   *catalog.DatabaseProvider
 	pool *ConnectionPool
+
+	// replica is set for the session of the replication applier, which keeps writing in read-only mode.
+	replica bool
 }
 
 func NewSession(base *memory.Session, provider *catalog.DatabaseProvider, pool *ConnectionPool) *Session {
-	return &Session{base, provider, pool}
+	return &Session{Session: base, db: provider, pool: pool}
+}
+
+// NewReplicaSession returns a session for the replication applier.
+func NewReplicaSession(base *memory.Session, provider *catalog.DatabaseProvider, pool *ConnectionPool) *Session {
+	return &Session{Session: base, db: provider, pool: pool, replica: true}
 }
 
 // Provider returns the database provider for the session.
@@ -48,6 +55,22 @@ func (sess *Session) CurrentSchemaOfUnderlyingConn() string {
 	return sess.pool.CurrentSchema(sess.ID())
 }
 
+// SetCurrentDatabase implements sql.Session.
+func (sess *Session) SetCurrentDatabase(dbName string) {
+	sess.Session.SetCurrentDatabase(dbName)
+	sess.pool.databases.Store(sess.ID(), dbName)
+}
+
+// RecordQuery records |query| as the DuckDB statement that the session is about to run, for SHOW PROCESSLIST.
+func (sess *Session) RecordQuery(query string) {
+	sess.pool.RecordQuery(sess.ID(), query)
+}
+
+// Processes returns the sessions of |processList| with the DuckDB statements they are running.
+func (sess *Session) Processes(processList sql.ProcessList) []ProcessInfo {
+	return sess.pool.Processes(processList)
+}
+
 // NewSessionBuilder returns a session builder for the given database provider.
 func NewSessionBuilder(provider *catalog.DatabaseProvider, pool *ConnectionPool) func(ctx context.Context, conn *mysql.Conn, addr string) (sql.Session, error) {
 	return func(ctx context.Context, conn *mysql.Conn, addr string) (sql.Session, error) {
@@ -62,14 +85,15 @@ func NewSessionBuilder(provider *catalog.DatabaseProvider, pool *ConnectionPool)
 		client := sql.Client{Address: host, User: user, Capabilities: conn.Capabilities}
 		baseSession := sql.NewBaseSessionWithClientServer(addr, client, conn.ConnectionID)
 		memSession := memory.NewSession(baseSession, provider)
+		sess := &Session{Session: memSession, db: provider, pool: pool}
 
 		schema := pool.CurrentSchema(conn.ConnectionID)
 		if schema != "" {
 			logrus.Traceln("SessionBuilder: new session: current schema:", schema)
-			memSession.SetCurrentDatabase(schema)
+			sess.SetCurrentDatabase(schema)
 		}
 
-		return &Session{memSession, provider, pool}, nil
+		return sess, nil
 	}
 }
 
@@ -188,16 +212,7 @@ func (sess *Session) GetPersistedValue(k string) (interface{}, error) {
 	case err != nil:
 		return nil, err
 	default:
-		switch vtype {
-		case "string":
-			return value, nil
-		case "int":
-			return strconv.Atoi(value)
-		case "bool":
-			return value == "true", nil
-		default:
-			return nil, fmt.Errorf("unknown variable type %s", vtype)
-		}
+		return catalog.DecodePersistentVariable(value, vtype)
 	}
 }
 
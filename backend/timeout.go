@@ -0,0 +1,96 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/types"
+)
+
+// RegisterMaxExecutionTime registers the max_execution_time system variable, the time limit of
+// SELECT statements in milliseconds, with |defaultTimeout| as its global value. 0 means no limit.
+// It does not apply to the PostgreSQL port, which has its own statement_timeout.
+// https://dev.mysql.com/doc/refman/8.4/en/server-system-variables.html#sysvar_max_execution_time
+func RegisterMaxExecutionTime(defaultTimeout time.Duration) {
+	sql.SystemVariables.AddSystemVariables([]sql.SystemVariable{
+		&sql.MysqlSystemVariable{
+			Name:              "max_execution_time",
+			Scope:             sql.GetMysqlScope(sql.SystemVariableScope_Both),
+			Dynamic:           true,
+			SetVarHintApplies: true,
+			Type:              types.NewSystemIntType("max_execution_time", 0, math.MaxUint32, false),
+			Default:           defaultTimeout.Milliseconds(),
+		},
+	})
+}
+
+// maxExecutionTimeHint matches the MAX_EXECUTION_TIME optimizer hint, which MySQL only honors
+// right after the SELECT keyword of the top-level statement.
+var maxExecutionTimeHint = regexp.MustCompile(`(?is)^\s*(?:\(\s*)*SELECT\s*/\*\+[^*]*\bMAX_EXECUTION_TIME\s*\(\s*(\d+)\s*\)`)
+
+// MaxExecutionTime returns the time limit of the query of |ctx|, given by its MAX_EXECUTION_TIME hint
+// or else by the session value of max_execution_time. It returns 0 if the query is not limited.
+func MaxExecutionTime(ctx *sql.Context) time.Duration {
+	if m := maxExecutionTimeHint.FindStringSubmatch(ctx.Query()); m != nil {
+		if ms, err := strconv.ParseUint(m[1], 10, 32); err == nil {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	v, err := ctx.GetSessionVariable(ctx, "max_execution_time")
+	if err != nil {
+		return 0
+	}
+	ms, _ := v.(int64)
+	return time.Duration(ms) * time.Millisecond
+}
+
+// deadlineRowIter iterates over the result of a query run with a deadline, which is released
+// when the iterator is closed.
+type deadlineRowIter struct {
+	*SQLRowIter
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (iter *deadlineRowIter) Next(ctx *sql.Context) (sql.Row, error) {
+	row, err := iter.SQLRowIter.Next(ctx)
+	if err != nil && err != io.EOF {
+		return nil, deadlineExceeded(ctx, iter.ctx, err)
+	}
+	return row, err
+}
+
+func (iter *deadlineRowIter) Close(ctx *sql.Context) error {
+	defer iter.cancel()
+	return iter.SQLRowIter.Close(ctx)
+}
+
+// deadlineExceeded returns ErrQueryTimeout if |err| results from the expiry of the deadline of |queryCtx|,
+// on which the DuckDB driver interrupts the query, and |err| otherwise.
+func deadlineExceeded(ctx *sql.Context, queryCtx context.Context, err error) error {
+	if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		sql.IncrementStatusVariable(ctx, "Max_execution_time_exceeded", 1)
+		return ErrQueryTimeout
+	}
+	return err
+}
@@ -0,0 +1,285 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backup takes consistent snapshots of a data directory and restores them.
+//
+// A backup is a directory holding a copy of the DuckDB database, made with
+// COPY FROM DATABASE in a single transaction, and a manifest. Since the internal
+// tables, including the binlog position and the grant tables, are copied in the
+// same transaction as the user schemas, and the running state of the replication
+// applier is read within it as well, a restored replica resumes replication where
+// the snapshot was taken.
+package backup
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apecloud/myduckserver/catalog"
+)
+
+const (
+	// ManifestFile is written last, so a directory without it is not a complete backup.
+	ManifestFile  = "myduckserver_backup.json"
+	formatVersion = 1
+
+	// replicaStateDir is the directory of the data directory where the replication applier
+	// records whether it is running, with the replicaRunningFile marker, so that it is
+	// restarted along with the server.
+	replicaStateDir    = ".replica"
+	replicaRunningFile = "replica-running"
+
+	attachAlias = "__myduck_backup"
+)
+
+// Manifest describes a backup.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Database is the file name of the database, e.g., "mysql.db".
+	Database string `json:"database"`
+	// BinlogPosition is the replication position of the snapshot, if the server is a replica.
+	BinlogPosition string `json:"binlog_position,omitempty"`
+	// ReplicaRunning tells whether replication was running when the snapshot was taken.
+	ReplicaRunning bool `json:"replica_running,omitempty"`
+}
+
+// The database copy is attached under a fixed alias, so backups are taken one at a time.
+var mu sync.Mutex
+
+// Run writes a backup of the database |catalogName| of |db| into the directory |target|,
+// which is created if missing and must be empty otherwise.
+func Run(ctx context.Context, db *stdsql.DB, catalogName string, target string) (manifest *Manifest, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var dbPath string
+	if err := conn.QueryRowContext(ctx,
+		"SELECT path FROM duckdb_databases() WHERE database_name = ?", catalogName,
+	).Scan(&dbPath); err != nil {
+		return nil, fmt.Errorf("failed to locate the database file: %w", err)
+	}
+	if dbPath == "" {
+		return nil, errors.New("an in-memory database cannot be backed up")
+	}
+
+	if err := prepareTarget(target); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			cleanTarget(target)
+		}
+	}()
+
+	manifest = &Manifest{
+		FormatVersion: formatVersion,
+		Database:      filepath.Base(dbPath),
+	}
+	replicaState := filepath.Join(filepath.Dir(dbPath), replicaStateDir, replicaRunningFile)
+	if err = copyDatabase(ctx, conn, catalogName, filepath.Join(target, manifest.Database), replicaState, manifest); err != nil {
+		return nil, err
+	}
+
+	manifest.CreatedAt = time.Now().UTC()
+	if err = writeManifest(target, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// copyDatabase copies the database |catalogName| into a new database file at |dst|. The binlog position
+// and the replication running state, i.e., whether the marker file |replicaState| exists, are read in
+// the same transaction and recorded in |manifest|.
+func copyDatabase(ctx context.Context, conn *stdsql.Conn, catalogName string, dst string, replicaState string, manifest *Manifest) (err error) {
+	if _, err := conn.ExecContext(ctx, "ATTACH "+quote(dst)+" AS "+attachAlias); err != nil {
+		return err
+	}
+	defer func() {
+		if _, detachErr := conn.ExecContext(context.Background(), "DETACH "+attachAlias); detachErr != nil && err == nil {
+			err = detachErr
+		}
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "COPY FROM DATABASE "+catalogName+" TO "+attachAlias); err != nil {
+		return fmt.Errorf("failed to copy the database: %w", err)
+	}
+	bp := catalog.InternalTables.BinlogPosition
+	err = tx.QueryRowContext(ctx,
+		"SELECT "+bp.ValueColumns[0]+" FROM "+catalogName+"."+bp.QualifiedName()+" WHERE "+bp.KeyColumns[0]+" = ''",
+	).Scan(&manifest.BinlogPosition)
+	if err != nil && !errors.Is(err, stdsql.ErrNoRows) {
+		return err
+	}
+	if _, err := os.Stat(replicaState); err == nil {
+		manifest.ReplicaRunning = true
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read the replication state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Checkpoint the copy so that it is a single file without a WAL.
+	if _, err := conn.ExecContext(ctx, "CHECKPOINT "+attachAlias); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Restore copies the backup in the directory |source| into the data directory |dataDir|,
+// which must not contain a database yet. The server started on |dataDir| afterwards
+// resumes replication if it was running when the backup was taken.
+func Restore(source string, dataDir string) (*Manifest, error) {
+	manifest, err := ReadManifest(source)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(dataDir, manifest.Database)
+	for _, path := range []string{dst, dst + ".wal"} {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists; restore into an empty data directory", path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// The database file is copied under a temporary name, so an interrupted restore does not leave
+	// a partial database behind that the server would open.
+	tmp := dst + ".restoring"
+	if err := copyFile(filepath.Join(source, manifest.Database), tmp); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to copy the database: %w", err)
+	}
+	if manifest.ReplicaRunning {
+		if err := writeReplicaRunning(dataDir); err != nil {
+			os.Remove(tmp)
+			return nil, fmt.Errorf("failed to restore the replication state: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads the manifest of the backup in the directory |dir|.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s is not a complete backup: %s is missing", dir, ManifestFile)
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	if m.FormatVersion != formatVersion {
+		return nil, fmt.Errorf("unsupported backup format version %d", m.FormatVersion)
+	}
+	if m.Database == "" || filepath.Base(m.Database) != m.Database {
+		return nil, fmt.Errorf("invalid database file name %q in the backup manifest", m.Database)
+	}
+	return &m, nil
+}
+
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0o644)
+}
+
+func prepareTarget(target string) error {
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("backup directory %s is not empty", target)
+	}
+	return nil
+}
+
+// cleanTarget removes what a failed backup has written. The directory was empty before.
+func cleanTarget(target string) {
+	entries, _ := os.ReadDir(target)
+	for _, e := range entries {
+		os.RemoveAll(filepath.Join(target, e.Name()))
+	}
+}
+
+// writeReplicaRunning creates the marker file that makes the server on |dataDir| start replication.
+func writeReplicaRunning(dataDir string) error {
+	dir := filepath.Join(dataDir, replicaStateDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, replicaRunningFile), nil, 0o644)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
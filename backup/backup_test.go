@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"context"
+	stdsql "database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/myduckserver/catalog"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func openTestDB(t *testing.T, path string) *stdsql.DB {
+	db, err := stdsql.Open("duckdb", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	db := openTestDB(t, filepath.Join(dataDir, "mysql.db"))
+	bp := catalog.InternalTables.BinlogPosition
+	for _, stmt := range []string{
+		"CREATE TABLE " + bp.QualifiedName() + " (" + bp.DDL + ")",
+		"INSERT INTO " + bp.QualifiedName() + " VALUES ('', 'MySQL56/3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5')",
+		"CREATE SCHEMA s1",
+		"CREATE TABLE s1.t (id INT PRIMARY KEY, v BLOB)",
+		"COMMENT ON TABLE s1.t IS 'meta'",
+		"INSERT INTO s1.t VALUES (1, '\\xAA'::BLOB)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(stmt, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dataDir, replicaStateDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, replicaStateDir, replicaRunningFile), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "backup")
+	manifest, err := Run(context.Background(), db, "mysql", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Database != "mysql.db" || manifest.BinlogPosition != "MySQL56/3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5" || !manifest.ReplicaRunning {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+	if _, err := Run(context.Background(), db, "mysql", target); err == nil {
+		t.Error("expected a backup into a non-empty directory to fail")
+	}
+
+	restored := t.TempDir()
+	if _, err := Restore(target, restored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(target, restored); err == nil {
+		t.Error("expected a restore over an existing database to fail")
+	}
+	if _, err := os.Stat(filepath.Join(restored, replicaStateDir, replicaRunningFile)); err != nil {
+		t.Errorf("the replication state was not restored: %v", err)
+	}
+
+	db = openTestDB(t, filepath.Join(restored, "mysql.db"))
+	var comment string
+	if err := db.QueryRow("SELECT comment FROM duckdb_tables() WHERE schema_name = 's1' AND table_name = 't'").Scan(&comment); err != nil {
+		t.Fatal(err)
+	}
+	if comment != "meta" {
+		t.Errorf("expected the table comment to be kept, got %q", comment)
+	}
+	var v []byte
+	if err := db.QueryRow("SELECT v FROM s1.t WHERE id = 1").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 || v[0] != 0xAA {
+		t.Errorf("unexpected value: %x", v)
+	}
+	var position string
+	if err := db.QueryRow("SELECT " + bp.ValueColumns[0] + " FROM " + bp.QualifiedName()).Scan(&position); err != nil {
+		t.Fatal(err)
+	}
+	if position != manifest.BinlogPosition {
+		t.Errorf("expected the binlog position %q, got %q", manifest.BinlogPosition, position)
+	}
+}
+
+func TestBackupStoppedReplica(t *testing.T) {
+	dataDir := t.TempDir()
+	db := openTestDB(t, filepath.Join(dataDir, "mysql.db"))
+	bp := catalog.InternalTables.BinlogPosition
+	if _, err := db.Exec("CREATE TABLE " + bp.QualifiedName() + " (" + bp.DDL + ")"); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "backup")
+	manifest, err := Run(context.Background(), db, "mysql", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ReplicaRunning {
+		t.Error("expected replication to be recorded as stopped")
+	}
+
+	restored := t.TempDir()
+	if _, err := Restore(target, restored); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(restored, replicaStateDir, replicaRunningFile)); !os.IsNotExist(err) {
+		t.Errorf("expected replication not to be started on the restored server, got %v", err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadManifest(dir); err == nil {
+		t.Error("expected an error for a directory without a manifest")
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte(`{"format_version": 1, "database": "../mysql.db"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(dir); err == nil {
+		t.Error("expected an error for a database outside of the backup")
+	}
+}
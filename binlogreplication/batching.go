@@ -0,0 +1,56 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package binlogreplication
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// BatchOptions controls how row-based binlog transactions are grouped into a single DuckDB transaction.
+type BatchOptions struct {
+	// CommitInterval is the maximum time a batched transaction may stay open.
+	CommitInterval time.Duration
+	// MaxDeltaSize is the size of the delta buffer, in bytes, that forces the batched transaction to be committed.
+	MaxDeltaSize uint64
+}
+
+// DefaultBatchOptions commits the batched transaction every 200ms or once the delta buffer reaches 128MB.
+var DefaultBatchOptions = BatchOptions{
+	CommitInterval: 200 * time.Millisecond,
+	MaxDeltaSize:   128 << 20,
+}
+
+var batchOptions atomic.Pointer[BatchOptions]
+
+func init() {
+	batchOptions.Store(&DefaultBatchOptions)
+}
+
+// SetBatchOptions replaces the batching limits used by the applier. Zero fields fall back to the defaults.
+// It is safe to call while replication is running; the new limits take effect on the next batch.
+func SetBatchOptions(opts BatchOptions) {
+	if opts.CommitInterval <= 0 {
+		opts.CommitInterval = DefaultBatchOptions.CommitInterval
+	}
+	if opts.MaxDeltaSize == 0 {
+		opts.MaxDeltaSize = DefaultBatchOptions.MaxDeltaSize
+	}
+	batchOptions.Store(&opts)
+}
+
+// GetBatchOptions returns the batching limits currently in effect.
+func GetBatchOptions() BatchOptions {
+	return *batchOptions.Load()
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/apecloud/myduckserver/adapter"
 	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/delta"
 	gms "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/sql"
 	"vitess.io/vitess/go/mysql/replication"
@@ -94,7 +95,70 @@ func (store *binlogPositionStore) Delete(ctx *sql.Context, engine *gms.Engine) e
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
-	_, err := adapter.ExecCatalogInTxn(ctx, catalog.InternalTables.BinlogPosition.DeleteStmt(), defaultChannelName)
+	if _, err := adapter.ExecCatalogInTxn(ctx, catalog.InternalTables.BinlogPosition.DeleteStmt(), defaultChannelName); err != nil {
+		return err
+	}
+	_, err := adapter.ExecCatalogInTxn(ctx, "DELETE FROM "+catalog.InternalTables.BinlogTablePosition.QualifiedName()+" WHERE channel = ?", defaultChannelName)
+	return err
+}
+
+// LoadTables loads the positions of the tables whose changes have been applied ahead of the saved position,
+// i.e., the sets of GTIDs whose changes to each of these tables have been applied.
+func (store *binlogPositionStore) LoadTables(ctx *sql.Context) (map[delta.TableIdentifier]replication.Position, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	rows, err := adapter.QueryCatalog(
+		ctx,
+		"SELECT db_name, table_name, position FROM "+catalog.InternalTables.BinlogTablePosition.QualifiedName()+" WHERE channel = ?",
+		defaultChannelName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load binlog table positions: %w", err)
+	}
+	defer rows.Close()
+
+	positions := make(map[delta.TableIdentifier]replication.Position)
+	for rows.Next() {
+		var table delta.TableIdentifier
+		var positionString string
+		if err := rows.Scan(&table.DBName, &table.TableName, &positionString); err != nil {
+			return nil, fmt.Errorf("unable to load binlog table positions: %w", err)
+		}
+		position, err := replication.ParsePosition(mysqlFlavor, strings.TrimPrefix(positionString, "MySQL56/"))
+		if err != nil {
+			return nil, err
+		}
+		positions[table] = position
+	}
+	return positions, rows.Err()
+}
+
+// SaveTable persists the |position| up to which the changes to |table| have been applied, ahead of the saved position.
+func (store *binlogPositionStore) SaveTable(ctx *sql.Context, table delta.TableIdentifier, position replication.Position) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, err := adapter.ExecCatalogInTxn(
+		ctx,
+		catalog.InternalTables.BinlogTablePosition.UpsertStmt(),
+		defaultChannelName, table.DBName, table.TableName, position.String(),
+	); err != nil {
+		return fmt.Errorf("unable to save binlog table position: %w", err)
+	}
+	return nil
+}
+
+// DeleteTable deletes the position of |table|, once the saved position has caught up with it.
+func (store *binlogPositionStore) DeleteTable(ctx *sql.Context, table delta.TableIdentifier) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, err := adapter.ExecCatalogInTxn(
+		ctx,
+		catalog.InternalTables.BinlogTablePosition.DeleteStmt(),
+		defaultChannelName, table.DBName, table.TableName,
+	)
 	return err
 }
 
@@ -22,6 +22,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/apecloud/myduckserver/binlog"
 	"github.com/apecloud/myduckserver/charset"
 	"github.com/apecloud/myduckserver/delta"
+	"github.com/apecloud/myduckserver/metrics"
 	"github.com/apecloud/myduckserver/mysqlutil"
 	gms "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/sql"
@@ -66,6 +68,7 @@ type binlogReplicaApplier struct {
 	currentPosition       replication.Position // successfully executed GTIDs
 	filters               *filterConfiguration
 	running               atomic.Bool
+	handlerWg             sync.WaitGroup // tracks the binlog event handler goroutine
 	engine                *gms.Engine
 
 	tableWriterProvider TableWriterProvider
@@ -77,6 +80,16 @@ type binlogReplicaApplier struct {
 	inTxnStmtID         atomic.Uint64 // auto-incrementing ID for statements within a transaction
 	deltaBufSize        atomic.Uint64 // size of the delta buffer
 	lastCommitTime      time.Time     // time of the last commit
+	lastEventTimestamp  uint32        // source timestamp of the last binlog event, used to report the replication lag
+
+	// tablePositions are the positions up to which the changes to some tables have been committed ahead of
+	// the saved position, by the queries that read them. Their changes in these transactions are not applied again.
+	tablePositions map[delta.TableIdentifier]replication.Position
+
+	// The queries that read fresh replicated data request the applier to flush the delta buffers of their tables.
+	flushMutex    sync.Mutex     // protects flushRequests, which is the only state shared with the queries
+	flushRequests []flushRequest // pending requests, served between source transactions
+	flushWakeup   chan struct{}  // signals the event handler that there are new requests
 }
 
 func newBinlogReplicaApplier(filters *filterConfiguration) *binlogReplicaApplier {
@@ -84,6 +97,7 @@ func newBinlogReplicaApplier(filters *filterConfiguration) *binlogReplicaApplier
 		tableMapsById:       make(map[uint64]*mysql.TableMap),
 		stopReplicationChan: make(chan struct{}),
 		filters:             filters,
+		flushWakeup:         make(chan struct{}, 1),
 	}
 }
 
@@ -100,7 +114,9 @@ const rowFlag_rowsAreComplete = 0x0008
 
 // Go spawns a new goroutine to run the applier's binlog event handler.
 func (a *binlogReplicaApplier) Go(ctx *sql.Context) {
+	a.handlerWg.Add(1)
 	go func() {
+		defer a.handlerWg.Done()
 		a.running.Store(true)
 		err := a.replicaBinlogEventHandler(ctx)
 		a.running.Store(false)
@@ -250,6 +266,9 @@ func (a *binlogReplicaApplier) startReplicationEventStream(ctx *sql.Context, con
 
 	a.currentPosition = position
 	a.pendingPosition = position
+	if a.tablePositions, err = positionStore.LoadTables(ctx); err != nil {
+		return err
+	}
 	if err := sql.SystemVariables.AssignValues(map[string]interface{}{"gtid_executed": a.currentPosition.GTIDSet.String()}); err != nil {
 		ctx.GetLogger().Errorf("unable to set @@GLOBAL.gtid_executed: %s", err.Error())
 	}
@@ -301,7 +320,8 @@ func (a *binlogReplicaApplier) replicaBinlogEventHandler(ctx *sql.Context) error
 	var conn *mysql.Conn
 	var eventProducer *binlogEventProducer
 
-	ticker := time.NewTicker(200 * time.Millisecond)
+	commitInterval := GetBatchOptions().CommitInterval
+	ticker := time.NewTicker(commitInterval)
 	defer ticker.Stop()
 
 	// Process binlog events
@@ -329,6 +349,11 @@ func (a *binlogReplicaApplier) replicaBinlogEventHandler(ctx *sql.Context) error
 				ctx.GetLogger().Errorf("unexpected error of type %T: '%v'", err, err.Error())
 				MyBinlogReplicaController.setSqlError(sqlerror.ERUnknownError, err.Error())
 			}
+			// Serve the flush requests received in the middle of a transaction once it ends.
+			a.serveFlushRequests(ctx, engine)
+
+		case <-a.flushWakeup:
+			a.serveFlushRequests(ctx, engine)
 
 		case err := <-eventProducer.ErrorChan():
 			if sqlError, isSqlError := err.(*sqlerror.SQLError); isSqlError {
@@ -352,6 +377,11 @@ func (a *binlogReplicaApplier) replicaBinlogEventHandler(ctx *sql.Context) error
 			}
 
 		case <-ticker.C:
+			// Pick up the commit interval if it has been reloaded.
+			if interval := GetBatchOptions().CommitInterval; interval != commitInterval {
+				commitInterval = interval
+				ticker.Reset(commitInterval)
+			}
 			if a.ongoingBatchTxn.Load() && !a.dirtyStream.Load() {
 				// We should commit the transaction to flush the changes to the database
 				// if we're in a batched transaction and haven't seen any changes for a while.
@@ -402,6 +432,11 @@ func (a *binlogReplicaApplier) processBinlogEvent(ctx *sql.Context, engine *gms.
 		}
 	}
 
+	// Heartbeat and artificial events carry a zero timestamp.
+	if ts := event.Timestamp(); ts != 0 {
+		a.lastEventTimestamp = ts
+	}
+
 	// ------------------- NOTE -----------------------
 	// Since this function is called in a hot loop,
 	// we invoke the logging API conditionally
@@ -651,6 +686,10 @@ func (a *binlogReplicaApplier) processBinlogEvent(ctx *sql.Context, engine *gms.
 			// when the primary has no binlog events to send to replica servers.
 			// For more details, see: https://mariadb.com/kb/en/heartbeat_log_event/
 			ctx.GetLogger().Trace("Received binlog event: Heartbeat")
+			if !a.ongoingBatchTxn.Load() && !a.dirtyTxn.Load() {
+				// The replica has caught up with the source.
+				metrics.SetReplicationLag(0)
+			}
 		case 0x03:
 			ctx.GetLogger().Trace("Received binlog event: Stop")
 		default:
@@ -685,23 +724,20 @@ func (a *binlogReplicaApplier) commitOngoingTxn(ctx *sql.Context, engine *gms.En
 	if err := positionStore.Save(ctx, engine, a.pendingPosition); err != nil {
 		return fmt.Errorf("unable to store GTID executed metadata to disk: %s", err.Error())
 	}
+	// The positions of the tables that the saved position has caught up with are no longer needed.
+	for table, position := range a.tablePositions {
+		if a.pendingPosition.AtLeast(position) {
+			if err := positionStore.DeleteTable(ctx, table); err != nil {
+				return fmt.Errorf("unable to delete binlog table position: %s", err.Error())
+			}
+			delete(a.tablePositions, table)
+		}
+	}
 
 	// --- Commit the transaction --- //
 
-	// Commit the transaction started on this session
-	if kind != ImplicitCommitAfterStmt || !getAutocommit(ctx) {
-		subctx := sql.NewContext(ctx, sql.WithSession(ctx.Session)).WithQuery("COMMIT")
-		if err := a.execute(subctx, engine, "COMMIT"); err != nil {
-			return err
-		}
-	}
-	// The session manager does not start an actual transaction in autocommit=1 mode,
-	// but there may be a transaction in progress if we have started it manually.
-	if tx := adapter.TryGetTxn(ctx); tx != nil {
-		if err := tx.Commit(); err != nil && err != stdsql.ErrTxDone {
-			return err
-		}
-		adapter.CloseTxn(ctx)
+	if err := a.commitTxn(ctx, engine, kind); err != nil {
+		return err
 	}
 
 	// --- Update the in-memory states --- //
@@ -724,17 +760,67 @@ func (a *binlogReplicaApplier) commitOngoingTxn(ctx *sql.Context, engine *gms.En
 		ctx.GetLogger().Errorf("unable to set @@GLOBAL.gtid_executed: %s", err.Error())
 	}
 
+	if a.lastEventTimestamp != 0 {
+		metrics.SetReplicationLag(time.Since(time.Unix(int64(a.lastEventTimestamp), 0)))
+	}
+	if gtidSet, ok := a.currentPosition.GTIDSet.(replication.Mysql56GTIDSet); ok {
+		metrics.SetReplicationAppliedGTIDs(countGTIDs(gtidSet))
+	}
+
+	return nil
+}
+
+// commitTxn commits the transaction of the applier's session, along with its DuckDB transaction.
+func (a *binlogReplicaApplier) commitTxn(ctx *sql.Context, engine *gms.Engine, kind CommitKind) error {
+	// Commit the transaction started on this session
+	if kind != ImplicitCommitAfterStmt || !getAutocommit(ctx) {
+		subctx := sql.NewContext(ctx, sql.WithSession(ctx.Session)).WithQuery("COMMIT")
+		if err := a.execute(subctx, engine, "COMMIT"); err != nil {
+			return err
+		}
+	}
+	// The session manager does not start an actual transaction in autocommit=1 mode,
+	// but there may be a transaction in progress if we have started it manually.
+	if tx := adapter.TryGetTxn(ctx); tx != nil {
+		if err := tx.Commit(); err != nil && err != stdsql.ErrTxDone {
+			return err
+		}
+		adapter.CloseTxn(ctx)
+	}
 	return nil
 }
 
+// countGTIDs returns the number of transactions in |set|.
+func countGTIDs(set replication.Mysql56GTIDSet) int64 {
+	// The SID block is the only exported view of the intervals:
+	// the number of SIDs, followed by each SID, its number of intervals and the intervals as [start, end).
+	block := set.SIDBlock()
+	var count int64
+	nSIDs := binary.LittleEndian.Uint64(block)
+	block = block[8:]
+	for range nSIDs {
+		block = block[16:]
+		nIntervals := binary.LittleEndian.Uint64(block)
+		block = block[8:]
+		for range nIntervals {
+			start := int64(binary.LittleEndian.Uint64(block))
+			end := int64(binary.LittleEndian.Uint64(block[8:]))
+			count += end - start
+			block = block[16:]
+		}
+	}
+	return count
+}
+
 func (a *binlogReplicaApplier) mayExtendBatchTxn() (bool, delta.FlushReason) {
 	extend, reason := false, delta.UnknownFlushReason
 	if a.ongoingBatchTxn.Load() {
 		extend = true
+		opts := GetBatchOptions()
 		switch {
-		case time.Since(a.lastCommitTime) >= 200*time.Millisecond: // commit the batched txn every 200ms
+		case time.Since(a.lastCommitTime) >= opts.CommitInterval: // commit the batched txn periodically
 			extend, reason = false, delta.TimeTickFlushReason
-		case a.deltaBufSize.Load() >= (128 << 20): // commit the batched txn if the delta buffer is too large (>= 128MB)
+		case a.deltaBufSize.Load() >= opts.MaxDeltaSize: // commit the batched txn if the delta buffer is too large
 			extend, reason = false, delta.MemoryLimitFlushReason
 		}
 	}
@@ -938,6 +1024,11 @@ func (a *binlogReplicaApplier) processRowEvent(ctx *sql.Context, event mysql.Bin
 	}
 	schema := pkSchema.Schema
 
+	if a.appliedToTable(delta.TableIdentifier{DBName: tableMap.Database, TableName: tableName}) {
+		// The changes of this transaction to the table have been committed for a query before a restart.
+		return nil
+	}
+
 	fieldCount := len(schema)
 	if len(tableMap.Types) != fieldCount {
 		return fmt.Errorf("schema mismatch: expected %d fields, got %d from binlog", fieldCount, len(tableMap.Types))
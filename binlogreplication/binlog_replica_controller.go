@@ -231,6 +231,41 @@ func (d *myBinlogReplicaController) StopReplica(ctx *sql.Context) error {
 	return nil
 }
 
+// Shutdown stops the applier when the server is shutting down, and waits until it has committed the ongoing
+// batched transaction, which flushes the delta buffer with delta.OnCloseFlushReason. Unlike StopReplica, the
+// running state is not persisted, so replication is restarted automatically when the server starts again.
+func (d *myBinlogReplicaController) Shutdown(ctx context.Context) error {
+	d.operationMutex.Lock()
+	defer d.operationMutex.Unlock()
+
+	if !d.applier.IsRunning() {
+		return nil
+	}
+
+	select {
+	case d.applier.stopReplicationChan <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		d.applier.handlerWg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	d.updateStatus(func(status *binlogreplication.ReplicaStatus) {
+		status.ReplicaIoRunning = binlogreplication.ReplicaIoNotRunning
+		status.ReplicaSqlRunning = binlogreplication.ReplicaSqlNotRunning
+	})
+	return nil
+}
+
 // SetReplicationSourceOptions implements the BinlogReplicaController interface.
 func (d *myBinlogReplicaController) SetReplicationSourceOptions(ctx *sql.Context, options []binlogreplication.ReplicationOption) error {
 	replicaSourceInfo, err := loadReplicationConfiguration(ctx, d.engine.Analyzer.Catalog.MySQLDb)
@@ -0,0 +1,141 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package binlogreplication
+
+import (
+	"time"
+
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/delta"
+	gms "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/binlogreplication"
+	"vitess.io/vitess/go/mysql/replication"
+)
+
+// flushRequest is the request of a query to merge the buffered changes to the tables it reads.
+type flushRequest struct {
+	tables []delta.TableIdentifier
+	done   chan error
+}
+
+// FlushDeltaBuffer merges the changes buffered by the ongoing batched transaction to |tables|, so that a query
+// on them sees all the transactions replicated so far. The changes are merged by the applier between two source
+// transactions, so the caller waits until the applier is done with the transaction at hand. It returns without
+// merging anything if replication is not running or is reconnecting to the source.
+func (d *myBinlogReplicaController) FlushDeltaBuffer(ctx *sql.Context, tables []delta.TableIdentifier) error {
+	a := d.applier
+	if len(tables) == 0 || !a.IsRunning() || !a.ongoingBatchTxn.Load() {
+		return nil
+	}
+
+	req := flushRequest{tables: tables, done: make(chan error, 1)}
+	a.flushMutex.Lock()
+	a.flushRequests = append(a.flushRequests, req)
+	a.flushMutex.Unlock()
+	select {
+	case a.flushWakeup <- struct{}{}:
+	default:
+	}
+
+	ticker := time.NewTicker(GetBatchOptions().CommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-req.done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			// The request is left to the applier, which answers it on the buffered channel later on.
+			if !a.IsRunning() || !a.ongoingBatchTxn.Load() || !d.ioRunning() {
+				return nil
+			}
+		}
+	}
+}
+
+// ioRunning reports whether the replica is connected to the source. The delta buffer is discarded on reconnection.
+func (d *myBinlogReplicaController) ioRunning() bool {
+	d.statusMutex.Lock()
+	defer d.statusMutex.Unlock()
+	return d.status.ReplicaIoRunning == binlogreplication.ReplicaIoRunning
+}
+
+// serveFlushRequests merges the buffered changes that the queries wait for. The requests are left pending
+// in the middle of a source transaction, whose changes must not be seen partially.
+func (a *binlogReplicaApplier) serveFlushRequests(ctx *sql.Context, engine *gms.Engine) {
+	if a.dirtyStream.Load() {
+		return
+	}
+	a.flushMutex.Lock()
+	requests := a.flushRequests
+	a.flushRequests = nil
+	a.flushMutex.Unlock()
+	if len(requests) == 0 {
+		return
+	}
+
+	var tables []delta.TableIdentifier
+	for _, req := range requests {
+		tables = append(tables, req.tables...)
+	}
+	var err error
+	if a.ongoingBatchTxn.Load() && a.tableWriterProvider.DeltaBuffered(tables) {
+		if err = a.flushTables(ctx, engine, tables); err != nil {
+			recordReplicationError(ctx, err)
+		}
+	}
+	for _, req := range requests {
+		req.done <- err
+	}
+}
+
+// flushTables merges the buffered changes to |tables| into the database and commits them, without ending
+// the batched transaction. The changes to the other tables stay in the buffer, and the saved position stays
+// behind them. Instead, the position of the batch is saved for each merged table, so that the transactions
+// merged into it are not applied to it again after a restart.
+func (a *binlogReplicaApplier) flushTables(ctx *sql.Context, engine *gms.Engine, tables []delta.TableIdentifier) error {
+	tx, err := adapter.GetCatalogTxn(ctx, nil)
+	if err != nil {
+		return err
+	}
+	flushed, err := a.tableWriterProvider.FlushTableDeltas(ctx, tx, tables)
+	if err != nil {
+		return err
+	}
+	for _, table := range flushed {
+		if err := positionStore.SaveTable(ctx, table, a.pendingPosition); err != nil {
+			return err
+		}
+	}
+	if err := a.commitTxn(ctx, engine, NormalCommit); err != nil {
+		return err
+	}
+
+	if a.tablePositions == nil {
+		a.tablePositions = make(map[delta.TableIdentifier]replication.Position, len(flushed))
+	}
+	for _, table := range flushed {
+		a.tablePositions[table] = a.pendingPosition
+	}
+	return nil
+}
+
+// appliedToTable reports whether the changes of the current transaction to |table| have been committed
+// ahead of the saved position by flushTables.
+func (a *binlogReplicaApplier) appliedToTable(table delta.TableIdentifier) bool {
+	position, ok := a.tablePositions[table]
+	return ok && a.currentGtid != nil && position.GTIDSet.ContainsGTID(a.currentGtid)
+}
@@ -0,0 +1,256 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package binlogreplication
+
+import (
+	"context"
+	stdsql "database/sql"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/delta"
+	gms "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/binlogreplication"
+	"github.com/marcboeker/go-duckdb"
+	"github.com/stretchr/testify/require"
+	"vitess.io/vitess/go/mysql/replication"
+)
+
+// bufferedTables is a TableWriterProvider whose delta buffer has changes to |tables|.
+type bufferedTables struct {
+	TableWriterProvider
+	tables []delta.TableIdentifier
+}
+
+func (p bufferedTables) DeltaBuffered(tables []delta.TableIdentifier) bool {
+	for _, table := range tables {
+		for _, buffered := range p.tables {
+			if table == buffered {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newFlushTestController returns a controller whose applier is in the middle of a batched transaction
+// with changes to db.t1, which are not to be committed by the tests since there is no engine.
+func newFlushTestController() *myBinlogReplicaController {
+	a := newBinlogReplicaApplier(nil)
+	a.tableWriterProvider = bufferedTables{tables: []delta.TableIdentifier{{DBName: "db", TableName: "t1"}}}
+	a.running.Store(true)
+	a.ongoingBatchTxn.Store(true)
+	d := &myBinlogReplicaController{applier: a, statusMutex: &sync.Mutex{}}
+	d.status.ReplicaIoRunning = binlogreplication.ReplicaIoRunning
+	return d
+}
+
+func pendingFlushRequests(a *binlogReplicaApplier) int {
+	a.flushMutex.Lock()
+	defer a.flushMutex.Unlock()
+	return len(a.flushRequests)
+}
+
+func TestFlushRequestHandoff(t *testing.T) {
+	d := newFlushTestController()
+	a := d.applier
+	ctx := sql.NewEmptyContext()
+	tables := []delta.TableIdentifier{{DBName: "db", TableName: "t2"}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.FlushDeltaBuffer(ctx, tables)
+	}()
+	select {
+	case <-a.flushWakeup:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the applier was not woken up")
+	}
+
+	// The request waits for the end of the source transaction at hand.
+	a.dirtyStream.Store(true)
+	a.serveFlushRequests(ctx, nil)
+	select {
+	case err := <-done:
+		t.Fatalf("the request was answered in the middle of a source transaction: %v", err)
+	case <-time.After(2 * GetBatchOptions().CommitInterval):
+	}
+	require.Equal(t, 1, pendingFlushRequests(a))
+
+	// The batch has no changes to the queried table, so the request is answered without committing it.
+	a.dirtyStream.Store(false)
+	a.serveFlushRequests(ctx, nil)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not answered")
+	}
+	require.Zero(t, pendingFlushRequests(a))
+	require.True(t, a.ongoingBatchTxn.Load())
+}
+
+func TestFlushRequestAbandoned(t *testing.T) {
+	d := newFlushTestController()
+	a := d.applier
+	tables := []delta.TableIdentifier{{DBName: "db", TableName: "t1"}}
+	a.dirtyStream.Store(true)
+
+	// A canceled query leaves its request to the applier, which answers it without blocking.
+	ctx, cancel := context.WithCancel(context.Background())
+	sqlCtx := sql.NewContext(ctx)
+	cancel()
+	require.ErrorIs(t, d.FlushDeltaBuffer(sqlCtx, tables), context.Canceled)
+	require.Equal(t, 1, pendingFlushRequests(a))
+
+	// A query stops waiting once the replica is disconnected from the source.
+	d.status.ReplicaIoRunning = binlogreplication.ReplicaIoConnecting
+	require.NoError(t, d.FlushDeltaBuffer(sql.NewEmptyContext(), tables))
+	require.Equal(t, 2, pendingFlushRequests(a))
+
+	// The batch is committed in the meantime, and the abandoned requests are answered.
+	a.dirtyStream.Store(false)
+	a.ongoingBatchTxn.Store(false)
+	a.serveFlushRequests(sql.NewEmptyContext(), nil)
+	require.Zero(t, pendingFlushRequests(a))
+
+	// Without an ongoing batch, there is nothing to wait for.
+	require.NoError(t, d.FlushDeltaBuffer(sql.NewEmptyContext(), tables))
+	require.Zero(t, pendingFlushRequests(a))
+}
+
+// mergingTables is a TableWriterProvider that merges the changes to its buffered |tables| by recording them
+// in the table "merged".
+type mergingTables struct {
+	TableWriterProvider
+	tables []delta.TableIdentifier
+}
+
+func (p *mergingTables) DeltaBuffered(tables []delta.TableIdentifier) bool {
+	return bufferedTables{tables: p.tables}.DeltaBuffered(tables)
+}
+
+func (p *mergingTables) FlushTableDeltas(ctx *sql.Context, tx *stdsql.Tx, tables []delta.TableIdentifier) ([]delta.TableIdentifier, error) {
+	var flushed []delta.TableIdentifier
+	for _, table := range tables {
+		if i := slices.Index(p.tables, table); i >= 0 {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO merged VALUES (?, ?)", table.DBName, table.TableName); err != nil {
+				return flushed, err
+			}
+			p.tables = slices.Delete(p.tables, i, i+1)
+			flushed = append(flushed, table)
+		}
+	}
+	return flushed, nil
+}
+
+func (p *mergingTables) FlushDeltaBuffer(ctx *sql.Context, tx *stdsql.Tx, reason delta.FlushReason) error {
+	_, err := p.FlushTableDeltas(ctx, tx, slices.Clone(p.tables))
+	return err
+}
+
+// newFlushTablesTestContext returns the context of a replica session on an in-memory DuckDB database
+// with the replication metadata tables, and the database.
+func newFlushTablesTestContext(t *testing.T) (*sql.Context, *stdsql.DB) {
+	connector, err := duckdb.NewConnector("", nil)
+	require.NoError(t, err)
+	storage := stdsql.OpenDB(connector)
+	t.Cleanup(func() { storage.Close() })
+	for _, table := range []catalog.InternalTable{catalog.InternalTables.BinlogPosition, catalog.InternalTables.BinlogTablePosition} {
+		_, err := storage.Exec("CREATE TABLE " + table.QualifiedName() + "(" + table.DDL + ")")
+		require.NoError(t, err)
+	}
+	_, err = storage.Exec("CREATE TABLE merged (db_name TEXT, table_name TEXT)")
+	require.NoError(t, err)
+
+	pool := backend.NewConnectionPool("memory", connector, storage)
+	t.Cleanup(func() { pool.Close() })
+	session := backend.NewReplicaSession(memory.NewSession(sql.NewBaseSession(), nil), nil, pool)
+	return sql.NewContext(context.Background(), sql.WithSession(session)), storage
+}
+
+func TestFlushTables(t *testing.T) {
+	ctx, storage := newFlushTablesTestContext(t)
+	engine := gms.NewDefault(memory.NewDBProvider())
+
+	t1 := delta.TableIdentifier{DBName: "db", TableName: "t1"}
+	t2 := delta.TableIdentifier{DBName: "db", TableName: "t2"}
+	t3 := delta.TableIdentifier{DBName: "db", TableName: "t3"}
+	p := &mergingTables{tables: []delta.TableIdentifier{t1, t2}}
+	a := newBinlogReplicaApplier(nil)
+	a.tableWriterProvider = p
+	a.ongoingBatchTxn.Store(true)
+
+	const sid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	position := func(gtids string) replication.Position {
+		pos, err := replication.ParsePosition(mysqlFlavor, sid+":"+gtids)
+		require.NoError(t, err)
+		return pos
+	}
+	merged := func() (tables []delta.TableIdentifier) {
+		rows, err := storage.Query("SELECT db_name, table_name FROM merged ORDER BY table_name")
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var table delta.TableIdentifier
+			require.NoError(t, rows.Scan(&table.DBName, &table.TableName))
+			tables = append(tables, table)
+		}
+		return tables
+	}
+	savedTablePositions := func() map[delta.TableIdentifier]replication.Position {
+		positions, err := positionStore.LoadTables(ctx)
+		require.NoError(t, err)
+		return positions
+	}
+
+	// A query on t1 and t3 merges and commits the changes to t1 only, along with the position of the batch for t1.
+	a.pendingPosition = position("1-5")
+	require.NoError(t, a.flushTables(ctx, engine, []delta.TableIdentifier{t1, t3}))
+	require.Equal(t, []delta.TableIdentifier{t1}, merged())
+	require.Equal(t, map[delta.TableIdentifier]replication.Position{t1: position("1-5")}, savedTablePositions())
+	require.Equal(t, []delta.TableIdentifier{t2}, p.tables)
+	require.True(t, a.ongoingBatchTxn.Load())
+
+	// When the transactions are replayed after a restart, their changes to t1 are skipped.
+	sidBytes, err := replication.ParseSID(sid)
+	require.NoError(t, err)
+	a.currentGtid = replication.Mysql56GTID{Server: sidBytes, Sequence: 5}
+	require.True(t, a.appliedToTable(t1))
+	require.False(t, a.appliedToTable(t2))
+	a.currentGtid = replication.Mysql56GTID{Server: sidBytes, Sequence: 6}
+	require.False(t, a.appliedToTable(t1))
+
+	// A commit of the batch behind the position of t1 keeps it.
+	a.pendingPosition = position("1-3")
+	require.NoError(t, a.commitOngoingTxn(ctx, engine, ImplicitCommitBeforeStmt, delta.DDLStmtFlushReason))
+	require.Equal(t, []delta.TableIdentifier{t1, t2}, merged())
+	require.Equal(t, map[delta.TableIdentifier]replication.Position{t1: position("1-5")}, savedTablePositions())
+
+	// Once the saved position catches up with it, it is deleted.
+	a.ongoingBatchTxn.Store(true)
+	a.pendingPosition = position("1-5")
+	require.NoError(t, a.commitOngoingTxn(ctx, engine, ImplicitCommitBeforeStmt, delta.DDLStmtFlushReason))
+	require.Empty(t, savedTablePositions())
+	require.Empty(t, a.tablePositions)
+	saved, err := positionStore.Load(ctx, engine)
+	require.NoError(t, err)
+	require.Equal(t, position("1-5"), saved)
+}
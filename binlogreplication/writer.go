@@ -50,6 +50,13 @@ type TableWriterProvider interface {
 	// FlushDelta writes the accumulated changes to the database.
 	FlushDeltaBuffer(ctx *sql.Context, tx *stdsql.Tx, reason delta.FlushReason) error
 
+	// FlushTableDeltas writes the accumulated changes to |tables| to the database, for a query on them,
+	// and returns the tables that had changes.
+	FlushTableDeltas(ctx *sql.Context, tx *stdsql.Tx, tables []delta.TableIdentifier) ([]delta.TableIdentifier, error)
+
+	// DeltaBuffered reports whether there are accumulated changes to any of |tables|.
+	DeltaBuffered(tables []delta.TableIdentifier) bool
+
 	// DiscardDeltaBuffer discards the accumulated changes.
 	DiscardDeltaBuffer(ctx *sql.Context)
 }
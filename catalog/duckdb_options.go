@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/types"
+	"github.com/sirupsen/logrus"
+)
+
+// duckdbOptions are the DuckDB settings exposed as MySQL global system variables.
+// https://duckdb.org/docs/configuration/overview
+var duckdbOptions = []struct {
+	name string
+	kind string // "string", "int" or "bool"
+}{
+	{"memory_limit", "string"},
+	{"threads", "int"},
+	{"temp_directory", "string"},
+	{"max_temp_directory_size", "string"},
+	{"preserve_insertion_order", "bool"},
+}
+
+// RegisterDuckDBOptions registers the DuckDB settings as global system variables.
+// The defaults are the current settings of |storage|, and SET GLOBAL or SET PERSIST applies
+// the new values to it. Values persisted by SET PERSIST are re-applied here.
+func RegisterDuckDBOptions(storage *stdsql.DB) error {
+	sysVars := make([]sql.SystemVariable, 0, len(duckdbOptions))
+	for _, opt := range duckdbOptions {
+		var current string
+		if err := storage.QueryRow("SELECT current_setting(?)::VARCHAR", opt.name).Scan(&current); err != nil {
+			return fmt.Errorf("failed to get the DuckDB setting %s: %w", opt.name, err)
+		}
+
+		sysVar := &sql.MysqlSystemVariable{
+			Name:              opt.name,
+			Scope:             sql.GetMysqlScope(sql.SystemVariableScope_Global),
+			Dynamic:           true,
+			SetVarHintApplies: false,
+			NotifyChanged:     notifyDuckDBOptionChanged(storage, opt.name),
+		}
+		switch opt.kind {
+		case "int":
+			n, err := strconv.ParseInt(current, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for the DuckDB setting %s: %w", current, opt.name, err)
+			}
+			sysVar.Type = types.NewSystemIntType(opt.name, 1, math.MaxInt32, false)
+			sysVar.Default = n
+		case "bool":
+			sysVar.Type = types.NewSystemBoolType(opt.name)
+			sysVar.Default = int8(0)
+			if current == "true" {
+				sysVar.Default = int8(1)
+			}
+		default:
+			sysVar.Type = types.NewSystemStringType(opt.name)
+			sysVar.Default = current
+		}
+		sysVars = append(sysVars, sysVar)
+	}
+	sql.SystemVariables.AddSystemVariables(sysVars)
+
+	return loadPersistedDuckDBOptions(storage)
+}
+
+func notifyDuckDBOptionChanged(storage *stdsql.DB, name string) func(sql.SystemVariableScope, sql.SystemVarValue) error {
+	return func(_ sql.SystemVariableScope, value sql.SystemVarValue) error {
+		var literal string
+		switch v := value.Val.(type) {
+		case string:
+			literal = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		case int8: // SystemBoolType
+			literal = strconv.FormatBool(v != 0)
+		case int64:
+			literal = strconv.FormatInt(v, 10)
+		default:
+			return fmt.Errorf("unexpected value %v of type %T for %s", v, v, name)
+		}
+		if _, err := storage.Exec("SET GLOBAL " + name + " = " + literal); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+		logrus.Infof("DuckDB setting %s changed to %s", name, literal)
+		return nil
+	}
+}
+
+// loadPersistedDuckDBOptions applies the values saved by SET PERSIST to the system variables,
+// and thereby to DuckDB. A value that DuckDB rejects is skipped with a warning rather than
+// preventing the server from starting.
+func loadPersistedDuckDBOptions(storage *stdsql.DB) error {
+	pv := InternalTables.PersistentVariable
+	for _, opt := range duckdbOptions {
+		var value, vtype string
+		err := storage.QueryRowContext(context.Background(), pv.SelectStmt(), opt.name).Scan(&value, &vtype)
+		switch {
+		case err == stdsql.ErrNoRows:
+			continue
+		case err != nil:
+			return err
+		}
+		v, err := DecodePersistentVariable(value, vtype)
+		if err != nil {
+			return fmt.Errorf("invalid persisted value for %s: %w", opt.name, err)
+		}
+		if err := sql.SystemVariables.AssignValues(map[string]any{opt.name: v}); err != nil {
+			logrus.WithError(err).Warnf("Failed to apply the persisted value %q of %s", value, opt.name)
+		}
+	}
+	return nil
+}
+
+// DecodePersistentVariable converts a value stored in the persistent_variable table
+// back to a Go value of the type named by |vtype|, the type of the value that was persisted.
+func DecodePersistentVariable(value, vtype string) (any, error) {
+	switch vtype {
+	case "string":
+		return value, nil
+	case "bool":
+		return value == "true", nil
+	case "int":
+		return strconv.Atoi(value)
+	case "int8", "int16", "int32", "int64":
+		bits, _ := strconv.Atoi(strings.TrimPrefix(vtype, "int"))
+		v, err := strconv.ParseInt(value, 10, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 8:
+			return int8(v), nil
+		case 16:
+			return int16(v), nil
+		case 32:
+			return int32(v), nil
+		default:
+			return v, nil
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		bits, _ := strconv.Atoi(strings.TrimPrefix(vtype, "uint"))
+		if bits == 0 {
+			bits = strconv.IntSize
+		}
+		v, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch vtype {
+		case "uint":
+			return uint(v), nil
+		case "uint8":
+			return uint8(v), nil
+		case "uint16":
+			return uint16(v), nil
+		case "uint32":
+			return uint32(v), nil
+		default:
+			return v, nil
+		}
+	case "float32":
+		v, err := strconv.ParseFloat(value, 32)
+		return float32(v), err
+	case "float64":
+		return strconv.ParseFloat(value, 64)
+	default:
+		return nil, fmt.Errorf("unknown variable type %s", vtype)
+	}
+}
@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	stdsql "database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestPersistentVariableRoundTrip(t *testing.T) {
+	db, err := stdsql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	pv := InternalTables.PersistentVariable
+	if _, err := db.Exec("CREATE TABLE " + pv.QualifiedName() + " (" + pv.DDL + ")"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The values are stored as sql.PersistableSession.PersistGlobal does.
+	values := []any{
+		"utf8mb4", true, false, 42, -1, int8(-8), int16(16), int32(32), int64(1 << 40),
+		uint(7), uint8(8), uint16(16), uint32(32), uint64(1 << 40), float32(0.5), 1.25,
+	}
+	for i, v := range values {
+		name := fmt.Sprintf("var%d", i)
+		if _, err := db.Exec(pv.UpsertStmt(), name, v, fmt.Sprintf("%T", v)); err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		var value, vtype string
+		if err := db.QueryRow(pv.SelectStmt(), name).Scan(&value, &vtype); err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		got, err := DecodePersistentVariable(value, vtype)
+		if err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("got %v (%T), want %v (%T)", got, got, v, v)
+		}
+	}
+}
+
+func TestDecodePersistentVariableErrors(t *testing.T) {
+	for _, tt := range []struct{ value, vtype string }{
+		{"x", "int"},
+		{"300", "int8"},
+		{"-1", "uint64"},
+		{"1", "complex128"},
+	} {
+		if v, err := DecodePersistentVariable(tt.value, tt.vtype); err == nil {
+			t.Errorf("DecodePersistentVariable(%q, %q) = %v, want an error", tt.value, tt.vtype, v)
+		}
+	}
+}
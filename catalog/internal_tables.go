@@ -44,6 +44,7 @@ func (it *InternalTable) DeleteStmt() string {
 	b.WriteString(it.KeyColumns[0])
 	b.WriteString(" = ?")
 	for _, c := range it.KeyColumns[1:] {
+		b.WriteString(" AND ")
 		b.WriteString(c)
 		b.WriteString(" = ?")
 	}
@@ -75,9 +76,12 @@ func (it *InternalTable) SelectStmt() string {
 }
 
 var InternalTables = struct {
-	PersistentVariable InternalTable
-	BinlogPosition     InternalTable
-	GlobalStatus       InternalTable
+	PersistentVariable  InternalTable
+	BinlogPosition      InternalTable
+	BinlogTablePosition InternalTable
+	GlobalStatus        InternalTable
+	AuditLog            InternalTable
+	MySQLDb             InternalTable
 }{
 	PersistentVariable: InternalTable{
 		Schema:       "main",
@@ -93,6 +97,15 @@ var InternalTables = struct {
 		ValueColumns: []string{"position"},
 		DDL:          "channel TEXT PRIMARY KEY, position TEXT",
 	},
+	// BinlogTablePosition holds the positions up to which the replicated changes to some tables have been
+	// applied ahead of the binlog position, as the queries that read them commit them before the rest of the batch.
+	BinlogTablePosition: InternalTable{
+		Schema:       "main",
+		Name:         "binlog_table_position",
+		KeyColumns:   []string{"channel", "db_name", "table_name"},
+		ValueColumns: []string{"position"},
+		DDL:          "channel TEXT, db_name TEXT, table_name TEXT, position TEXT, PRIMARY KEY (channel, db_name, table_name)",
+	},
 	GlobalStatus: InternalTable{
 		Schema:       "performance_schema",
 		Name:         "global_status",
@@ -103,10 +116,33 @@ var InternalTables = struct {
 			{"Innodb_redo_log_enabled", "OFF"}, // Queried by MySQL Shell
 		},
 	},
+	AuditLog: InternalTable{
+		Schema:     "performance_schema",
+		Name:       "audit_log",
+		KeyColumns: []string{"EVENT_ID"},
+		ValueColumns: []string{
+			"TIMESTAMP", "CLASS", "EVENT", "CONNECTION_ID", "PROTOCOL", "USER", "HOST",
+			"SCHEMA_NAME", "STATEMENT", "DURATION_US", "ROWS", "ERROR",
+		},
+		DDL: `EVENT_ID UBIGINT PRIMARY KEY, "TIMESTAMP" TIMESTAMP, CLASS TEXT, EVENT TEXT, CONNECTION_ID UINTEGER, ` +
+			`PROTOCOL TEXT, "USER" TEXT, HOST TEXT, SCHEMA_NAME TEXT, STATEMENT TEXT, DURATION_US BIGINT, "ROWS" BIGINT, ERROR TEXT`,
+	},
+	// MySQLDb holds the serialized "mysql" database of go-mysql-server:
+	// the accounts, the grants and the replication source configuration.
+	MySQLDb: InternalTable{
+		Schema:       "main",
+		Name:         "mysql_db",
+		KeyColumns:   []string{"name"},
+		ValueColumns: []string{"data"},
+		DDL:          "name TEXT PRIMARY KEY, data BLOB",
+	},
 }
 
 var internalTables = []InternalTable{
 	InternalTables.PersistentVariable,
 	InternalTables.BinlogPosition,
+	InternalTables.BinlogTablePosition,
 	InternalTables.GlobalStatus,
+	InternalTables.AuditLog,
+	InternalTables.MySQLDb,
 }
@@ -0,0 +1,202 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	stdsql "database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/binlogreplication"
+	"github.com/apecloud/myduckserver/configuration"
+	"github.com/apecloud/myduckserver/transpiler"
+	"github.com/sirupsen/logrus"
+)
+
+// explicitFlags records the flags given on the command line, which take precedence over the config file.
+var explicitFlags = map[string]string{}
+
+// loadConfig overlays the config file, if any, and then the environment onto the defaults and the flags.
+func loadConfig() error {
+	flag.Visit(func(f *flag.Flag) {
+		explicitFlags[f.Name] = f.Value.String()
+	})
+	if configFile != "" {
+		if err := configuration.LoadFile(configFile, &cfg); err != nil {
+			return err
+		}
+	}
+	if err := configuration.ApplyEnv(&cfg); err != nil {
+		return err
+	}
+	for name, value := range explicitFlags {
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %w", value, name, err)
+		}
+	}
+	return nil
+}
+
+// cfgMutex protects the reloadable settings of cfg, which are replaced on SIGHUP.
+var cfgMutex sync.RWMutex
+
+// reloadConfig reads the config file into a fresh copy of the defaults, with the environment and the
+// explicit flags on top as on startup, and takes its reloadable settings. A key removed from the file
+// falls back to its default, and the settings that cannot be reloaded keep their startup values.
+func reloadConfig() error {
+	next := configuration.Default()
+	if err := configuration.LoadFile(configFile, &next); err != nil {
+		return err
+	}
+	if err := configuration.ApplyEnv(&next); err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	registerConfigFlags(fs, &next)
+	for name, value := range explicitFlags {
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %w", value, name, err)
+		}
+	}
+
+	if err := checkTranslation(next.Transpiler); err != nil {
+		return err
+	}
+
+	cfgMutex.Lock()
+	defer cfgMutex.Unlock()
+	cfg.CopyReloadable(&next)
+	return nil
+}
+
+// checkTranslation reports why the translation settings |c| cannot translate queries, if they cannot:
+// the native translator is required without Python, and sqlglot is required otherwise.
+func checkTranslation(c configuration.TranspilerConfig) error {
+	if c.NoPython {
+		if !c.Native {
+			return errors.New("the native translator cannot be disabled without Python")
+		}
+		return nil
+	}
+	if _, err := transpiler.TranslateWithSQLGlot("SELECT 1"); err != nil {
+		return fmt.Errorf("sqlglot is not available: %w", err)
+	}
+	return nil
+}
+
+// applyReloadableConfig applies the settings that can be changed at runtime by sending SIGHUP.
+func applyReloadableConfig() {
+	cfgMutex.RLock()
+	defer cfgMutex.RUnlock()
+
+	logrus.SetLevel(logrus.Level(cfg.Log.Level))
+	binlogreplication.SetBatchOptions(binlogreplication.BatchOptions{
+		CommitInterval: cfg.Replication.BatchCommitInterval,
+		MaxDeltaSize:   uint64(cfg.Replication.BatchMaxDeltaSize),
+	})
+	transpiler.SetCacheOptions(transpiler.CacheOptions{
+		Size:      cfg.Transpiler.CacheSize,
+		Normalize: cfg.Transpiler.CacheNormalize,
+	})
+	transpiler.SetOptions(transpiler.Options{
+		Native:   cfg.Transpiler.Native,
+		NoPython: cfg.Transpiler.NoPython,
+	})
+	transpiler.SetPoolOptions(transpiler.PoolOptions{
+		Workers: cfg.Transpiler.Workers,
+		Timeout: cfg.Transpiler.Timeout,
+	})
+}
+
+// handleReloadSignal reloads the config file on SIGHUP.
+// Only the log level, the replication batching limits and the translation settings are applied;
+// other changes require a restart.
+func handleReloadSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if configFile == "" {
+			logrus.Warnln("Received SIGHUP, but no config file was given; nothing to reload")
+			continue
+		}
+		if err := reloadConfig(); err != nil {
+			logrus.Errorln("Failed to reload the configuration:", err)
+			continue
+		}
+		applyReloadableConfig()
+		cfgMutex.RLock()
+		logrus.WithFields(logrus.Fields{
+			"loglevel":               logrus.Level(cfg.Log.Level).String(),
+			"batch-commit-interval":  cfg.Replication.BatchCommitInterval,
+			"batch-max-delta-size":   uint64(cfg.Replication.BatchMaxDeltaSize),
+			"translation-cache-size": cfg.Transpiler.CacheSize,
+			"translation-workers":    cfg.Transpiler.Workers,
+		}).Infoln("Reloaded the configuration")
+		cfgMutex.RUnlock()
+	}
+}
+
+// applyDuckDBConfig applies the DuckDB settings of the config to the storage.
+func applyDuckDBConfig(db *stdsql.DB) error {
+	settings := map[string]string{}
+	if v := cfg.DuckDB.MemoryLimit; v != "" {
+		settings["memory_limit"] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	if v := cfg.DuckDB.Threads; v > 0 {
+		settings["threads"] = strconv.Itoa(v)
+	}
+	if v := cfg.DuckDB.TempDirectory; v != "" {
+		settings["temp_directory"] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	for name, value := range settings {
+		if _, err := db.Exec("SET GLOBAL " + name + " = " + value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// openAuditLog opens the audit log described by the config and installs it.
+func openAuditLog(db *stdsql.DB) error {
+	dir := cfg.Audit.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cfg.Server.DataDir, dir)
+	}
+	l, err := audit.Open(audit.Options{
+		Dir:         dir,
+		MaxFileSize: int64(cfg.Audit.MaxFileSize),
+		MaxFiles:    cfg.Audit.MaxFiles,
+		TableRows:   cfg.Audit.TableRows,
+	}, db)
+	if err != nil {
+		return err
+	}
+	audit.SetLogger(l)
+	logrus.Infoln("Writing the audit log to", dir)
+	return nil
+}
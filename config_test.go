@@ -0,0 +1,57 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/myduckserver/configuration"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReloadRejectsInvalidTranslation(t *testing.T) {
+	savedCfg, savedFile := cfg, configFile
+	t.Cleanup(func() { cfg, configFile = savedCfg, savedFile })
+
+	cfg = configuration.Default()
+	cfg.Transpiler.NoPython = true
+	configFile = filepath.Join(t.TempDir(), "config.yaml")
+	data := "log:\n  level: debug\ntranspiler:\n  native: false\n  no-python: true\n"
+	if err := os.WriteFile(configFile, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reloadConfig(); err == nil {
+		t.Fatal("expected the reload to be rejected without any translator")
+	}
+	if !cfg.Transpiler.Native || !cfg.Transpiler.NoPython {
+		t.Errorf("transpiler = %+v, want the previous settings", cfg.Transpiler)
+	}
+	if want := configuration.LogLevel(logrus.InfoLevel); cfg.Log.Level != want {
+		t.Errorf("log level = %v, want the previous %v", cfg.Log.Level, want)
+	}
+
+	data = "log:\n  level: debug\ntranspiler:\n  no-python: true\n"
+	if err := os.WriteFile(configFile, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if want := configuration.LogLevel(logrus.DebugLevel); cfg.Log.Level != want {
+		t.Errorf("log level = %v, want the reloaded %v", cfg.Log.Level, want)
+	}
+}
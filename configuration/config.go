@@ -0,0 +1,372 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package configuration
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the server configuration. It can be loaded from a YAML or TOML file
+// and is overridden by the command-line flags that are explicitly set.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Postgres    PostgresConfig    `yaml:"postgres" toml:"postgres"`
+	FlightSQL   FlightSQLConfig   `yaml:"flight-sql" toml:"flight-sql"`
+	HTTP        HTTPConfig        `yaml:"http" toml:"http"`
+	TLS         TLSConfig         `yaml:"tls" toml:"tls"`
+	DuckDB      DuckDBConfig      `yaml:"duckdb" toml:"duckdb"`
+	Replication ReplicationConfig `yaml:"replication" toml:"replication"`
+	Transpiler  TranspilerConfig  `yaml:"transpiler" toml:"transpiler"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Metrics     MetricsConfig     `yaml:"metrics" toml:"metrics"`
+	Admin       AdminConfig       `yaml:"admin" toml:"admin"`
+	Bootstrap   BootstrapConfig   `yaml:"bootstrap" toml:"bootstrap"`
+	Audit       AuditConfig       `yaml:"audit" toml:"audit"`
+}
+
+// ServerConfig holds the MySQL listener and storage settings.
+type ServerConfig struct {
+	Address string `yaml:"address" toml:"address"`
+	Port    int    `yaml:"port" toml:"port"`
+	Socket  string `yaml:"socket" toml:"socket"`
+	DataDir string `yaml:"datadir" toml:"datadir"`
+
+	// ShutdownTimeout bounds the graceful shutdown on SIGTERM or SIGINT, including the wait for the running
+	// queries, which are canceled when it expires.
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout" toml:"shutdown-timeout"`
+
+	// ReadOnly rejects writes from clients. The replication applier is not affected.
+	ReadOnly bool `yaml:"read-only" toml:"read-only"`
+
+	// MaxExecutionTime is the default time limit of SELECT statements on the MySQL port,
+	// i.e., the global max_execution_time. Zero means no limit.
+	MaxExecutionTime time.Duration `yaml:"max-execution-time" toml:"max-execution-time"`
+}
+
+// PostgresConfig holds the PostgreSQL listener settings. A non-positive port disables the listener.
+type PostgresConfig struct {
+	Port int `yaml:"port" toml:"port"`
+
+	// StatementTimeout is the default time limit of all statements on the PostgreSQL port,
+	// i.e., the default statement_timeout. Zero means no limit.
+	StatementTimeout time.Duration `yaml:"statement-timeout" toml:"statement-timeout"`
+}
+
+// FlightSQLConfig holds the Arrow Flight SQL listener settings. A non-positive port disables the listener.
+type FlightSQLConfig struct {
+	Port int `yaml:"port" toml:"port"`
+}
+
+// HTTPConfig holds the HTTP query interface settings.
+type HTTPConfig struct {
+	// Address is the host:port of the HTTP query listener. An empty address disables it.
+	Address string `yaml:"address" toml:"address"`
+}
+
+// TLSConfig holds the TLS settings shared by the MySQL and PostgreSQL listeners.
+type TLSConfig struct {
+	Cert string `yaml:"cert" toml:"cert"` // PEM certificate file
+	Key  string `yaml:"key" toml:"key"`   // PEM private key file
+	CA   string `yaml:"ca" toml:"ca"`     // PEM CA bundle used to verify client certificates
+
+	// Require rejects clients that do not use TLS.
+	Require bool `yaml:"require" toml:"require"`
+	// VerifyClientCert requires clients to present a certificate signed by the CA.
+	// Without it, a client certificate is only verified if one is presented.
+	VerifyClientCert bool `yaml:"verify-client-cert" toml:"verify-client-cert"`
+}
+
+// DuckDBConfig holds the DuckDB settings applied at startup. Empty values keep DuckDB's defaults.
+type DuckDBConfig struct {
+	MemoryLimit   string `yaml:"memory-limit" toml:"memory-limit"`
+	Threads       int    `yaml:"threads" toml:"threads"`
+	TempDirectory string `yaml:"temp-directory" toml:"temp-directory"`
+}
+
+// ReplicationConfig holds the replica settings.
+type ReplicationConfig struct {
+	ReportHost     string `yaml:"report-host" toml:"report-host"`
+	ReportPort     int    `yaml:"report-port" toml:"report-port"`
+	ReportUser     string `yaml:"report-user" toml:"report-user"`
+	ReportPassword string `yaml:"report-password" toml:"report-password"`
+
+	// BatchCommitInterval and BatchMaxDeltaSize bound the batched transactions of the applier.
+	// Both can be reloaded with SIGHUP.
+	BatchCommitInterval time.Duration `yaml:"batch-commit-interval" toml:"batch-commit-interval"`
+	BatchMaxDeltaSize   ByteSize      `yaml:"batch-max-delta-size" toml:"batch-max-delta-size"`
+}
+
+// TranspilerConfig holds the settings of the MySQL-to-DuckDB translation. They can be reloaded with SIGHUP.
+type TranspilerConfig struct {
+	// CacheSize is the number of translations kept in the LRU cache. A non-positive size disables the cache.
+	CacheSize int `yaml:"cache-size" toml:"cache-size"`
+	// CacheNormalize shares a cached translation among the queries that differ only in their literals.
+	CacheNormalize bool `yaml:"cache-normalize" toml:"cache-normalize"`
+	// Workers is the maximum number of sqlglot processes, started as needed. If not positive, it is the number of CPUs.
+	Workers int `yaml:"workers" toml:"workers"`
+	// Timeout bounds a translation, including the wait for an idle process. A non-positive timeout disables it.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// Native translates the common queries in Go, without sqlglot.
+	Native bool `yaml:"native" toml:"native"`
+	// NoPython disables sqlglot, so that the server runs without Python. The queries that the native
+	// translator does not support then fail.
+	NoPython bool `yaml:"no-python" toml:"no-python"`
+}
+
+// LogConfig holds the logging settings. The level can be reloaded with SIGHUP.
+type LogConfig struct {
+	Level LogLevel `yaml:"level" toml:"level"`
+}
+
+// MetricsConfig holds the Prometheus exporter settings.
+type MetricsConfig struct {
+	// Address is the host:port of the HTTP listener serving /metrics. An empty address disables it.
+	Address string `yaml:"address" toml:"address"`
+}
+
+// AdminConfig holds the HTTP admin API settings.
+type AdminConfig struct {
+	// Address is the host:port of the admin API listener. An empty address disables it.
+	Address string `yaml:"address" toml:"address"`
+	// Token is the bearer token that every admin request must present. It is required if the API is enabled.
+	Token string `yaml:"token" toml:"token"`
+}
+
+// AuditConfig holds the audit log settings.
+type AuditConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Dir is the directory of the JSON-lines files. A relative path is resolved against the data directory.
+	Dir string `yaml:"dir" toml:"dir"`
+	// MaxFileSize is the size at which a file is rotated, and MaxFiles the number of rotated files kept.
+	MaxFileSize ByteSize `yaml:"max-file-size" toml:"max-file-size"`
+	MaxFiles    int      `yaml:"max-files" toml:"max-files"`
+	// TableRows is the number of recent records kept in performance_schema.audit_log.
+	TableRows int `yaml:"table-rows" toml:"table-rows"`
+}
+
+// BootstrapConfig holds the accounts created when the data directory is initialized.
+// It is ignored once the grant tables have been written, so that a restart never
+// resets a password or brings back an account that was dropped.
+type BootstrapConfig struct {
+	RootPassword string `yaml:"root-password" toml:"root-password"`
+	// RootHost is the host part of the root account, e.g., "localhost" to refuse remote logins.
+	RootHost string `yaml:"root-host" toml:"root-host"`
+	// Users are additional accounts with all privileges.
+	Users BootstrapUsers `yaml:"users" toml:"users"`
+}
+
+// BootstrapUser is an account created when the data directory is initialized.
+type BootstrapUser struct {
+	Name     string `yaml:"name" toml:"name"`
+	Host     string `yaml:"host" toml:"host"` // "%" if empty
+	Password string `yaml:"password" toml:"password"`
+}
+
+// BootstrapUsers is a list of accounts given on the command line or in the environment
+// as comma-separated "name[@host]:password" items.
+type BootstrapUsers []BootstrapUser
+
+func (u BootstrapUsers) String() string {
+	items := make([]string, len(u))
+	for i, user := range u {
+		items[i] = user.Name
+		if user.Host != "" {
+			items[i] += "@" + user.Host
+		}
+		items[i] += ":" + user.Password
+	}
+	return strings.Join(items, ",")
+}
+
+// Set implements flag.Value. It replaces the list.
+func (u *BootstrapUsers) Set(s string) error {
+	var users BootstrapUsers
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		account, password, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("invalid bootstrap user %q, expected name[@host]:password", item)
+		}
+		name, host, _ := strings.Cut(account, "@")
+		if name == "" {
+			return fmt.Errorf("invalid bootstrap user %q: empty user name", item)
+		}
+		users = append(users, BootstrapUser{Name: name, Host: host, Password: password})
+	}
+	*u = users
+	return nil
+}
+
+// Environment variables that override the config file. Flags that are explicitly set take precedence over them.
+const (
+	EnvRootPassword   = "MYDUCK_ROOT_PASSWORD"
+	EnvRootHost       = "MYDUCK_ROOT_HOST"
+	EnvBootstrapUsers = "MYDUCK_BOOTSTRAP_USERS"
+)
+
+// ApplyEnv overrides cfg with the environment variables that are set.
+func ApplyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv(EnvRootPassword); ok {
+		cfg.Bootstrap.RootPassword = v
+	}
+	if v, ok := os.LookupEnv(EnvRootHost); ok {
+		cfg.Bootstrap.RootHost = v
+	}
+	if v, ok := os.LookupEnv(EnvBootstrapUsers); ok {
+		if err := cfg.Bootstrap.Users.Set(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvBootstrapUsers, err)
+		}
+	}
+	return nil
+}
+
+// Default returns the configuration used when neither a file nor flags are given.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address: "0.0.0.0",
+			Port:    3306,
+			DataDir: ".",
+
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Postgres: PostgresConfig{
+			Port: 5432,
+		},
+		Replication: ReplicationConfig{
+			BatchCommitInterval: 200 * time.Millisecond,
+			BatchMaxDeltaSize:   128 << 20,
+		},
+		Transpiler: TranspilerConfig{
+			CacheSize: 10000,
+			Timeout:   10 * time.Second,
+			Native:    true,
+		},
+		Log: LogConfig{
+			Level: LogLevel(logrus.InfoLevel),
+		},
+		Bootstrap: BootstrapConfig{
+			RootHost: "%",
+		},
+		Audit: AuditConfig{
+			Dir:         "audit",
+			MaxFileSize: 100 << 20,
+			MaxFiles:    10,
+			TableRows:   100000,
+		},
+	}
+}
+
+// CopyReloadable copies the settings that can be reloaded with SIGHUP from src into c:
+// the log level, the replication batching limits and the translation settings.
+func (c *Config) CopyReloadable(src *Config) {
+	c.Log.Level = src.Log.Level
+	c.Replication.BatchCommitInterval = src.Replication.BatchCommitInterval
+	c.Replication.BatchMaxDeltaSize = src.Replication.BatchMaxDeltaSize
+	c.Transpiler = src.Transpiler
+}
+
+// LoadFile decodes the configuration file at path into cfg. The format is chosen by the file extension:
+// ".toml" for TOML, and ".yaml" or ".yml" for YAML. Keys that are absent in the file leave cfg untouched.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown key %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) { // io.EOF means an empty file
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .toml", ext)
+	}
+	return nil
+}
+
+// LogLevel is a logrus level that accepts either a number or a level name, e.g., "debug".
+type LogLevel logrus.Level
+
+func (l LogLevel) String() string {
+	return strconv.Itoa(int(l))
+}
+
+// Set implements flag.Value.
+func (l *LogLevel) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < int(logrus.PanicLevel) || n > int(logrus.TraceLevel) {
+			return fmt.Errorf("invalid log level %d", n)
+		}
+		*l = LogLevel(n)
+		return nil
+	}
+	level, err := logrus.ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = LogLevel(level)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *LogLevel) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+// ByteSize is a size in bytes that accepts human-readable values, e.g., "128MiB".
+type ByteSize uint64
+
+func (b ByteSize) String() string {
+	return strconv.FormatUint(uint64(b), 10)
+}
+
+// Set implements flag.Value.
+func (b *ByteSize) Set(s string) error {
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
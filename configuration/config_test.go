@@ -0,0 +1,192 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+server:
+  port: 3307
+  datadir: /var/lib/myduck
+duckdb:
+  memory-limit: 4GB
+replication:
+  batch-commit-interval: 1s
+  batch-max-delta-size: 64MiB
+log:
+  level: debug
+bootstrap:
+  root-password: secret
+  users:
+    - name: app
+      host: 10.0.0.%
+      password: apppass
+audit:
+  enabled: true
+  max-file-size: 10MiB
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[server]
+port = 3307
+datadir = "/var/lib/myduck"
+
+[duckdb]
+memory-limit = "4GB"
+
+[replication]
+batch-commit-interval = "1s"
+batch-max-delta-size = "64MiB"
+
+[log]
+level = 5
+
+[bootstrap]
+root-password = "secret"
+
+[[bootstrap.users]]
+name = "app"
+host = "10.0.0.%"
+password = "apppass"
+
+[audit]
+enabled = true
+max-file-size = "10MiB"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg := Default()
+			if err := LoadFile(path, &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != 3307 || cfg.Server.DataDir != "/var/lib/myduck" {
+				t.Errorf("unexpected server config: %+v", cfg.Server)
+			}
+			if cfg.Server.Address != "0.0.0.0" || cfg.Postgres.Port != 5432 {
+				t.Errorf("defaults were not preserved: %+v %+v", cfg.Server, cfg.Postgres)
+			}
+			if cfg.DuckDB.MemoryLimit != "4GB" {
+				t.Errorf("unexpected memory limit: %q", cfg.DuckDB.MemoryLimit)
+			}
+			if cfg.Replication.BatchCommitInterval != time.Second || cfg.Replication.BatchMaxDeltaSize != 64<<20 {
+				t.Errorf("unexpected replication config: %+v", cfg.Replication)
+			}
+			if logrus.Level(cfg.Log.Level) != logrus.DebugLevel {
+				t.Errorf("unexpected log level: %v", cfg.Log.Level)
+			}
+			expectedUsers := BootstrapUsers{{Name: "app", Host: "10.0.0.%", Password: "apppass"}}
+			if cfg.Bootstrap.RootPassword != "secret" || cfg.Bootstrap.RootHost != "%" || !reflect.DeepEqual(cfg.Bootstrap.Users, expectedUsers) {
+				t.Errorf("unexpected bootstrap config: %+v", cfg.Bootstrap)
+			}
+			if !cfg.Audit.Enabled || cfg.Audit.MaxFileSize != 10<<20 || cfg.Audit.Dir != "audit" || cfg.Audit.MaxFiles != 10 {
+				t.Errorf("unexpected audit config: %+v", cfg.Audit)
+			}
+		})
+	}
+}
+
+func TestLoadFileUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  prot: 3307\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Default()
+	if err := LoadFile(path, &cfg); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestBootstrapUsers(t *testing.T) {
+	var users BootstrapUsers
+	if err := users.Set("app:secret, admin@localhost:p:w@d"); err != nil {
+		t.Fatal(err)
+	}
+	expected := BootstrapUsers{
+		{Name: "app", Password: "secret"},
+		{Name: "admin", Host: "localhost", Password: "p:w@d"},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %+v, got %+v", expected, users)
+	}
+	if s := users.String(); s != "app:secret,admin@localhost:p:w@d" {
+		t.Errorf("unexpected string %q", s)
+	}
+
+	for _, s := range []string{"app", ":secret", "@localhost:secret"} {
+		if err := users.Set(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv(EnvRootPassword, "secret")
+	t.Setenv(EnvRootHost, "localhost")
+	t.Setenv(EnvBootstrapUsers, "app@%:apppass")
+
+	cfg := Default()
+	if err := ApplyEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := BootstrapConfig{
+		RootPassword: "secret",
+		RootHost:     "localhost",
+		Users:        BootstrapUsers{{Name: "app", Host: "%", Password: "apppass"}},
+	}
+	if !reflect.DeepEqual(cfg.Bootstrap, expected) {
+		t.Errorf("expected %+v, got %+v", expected, cfg.Bootstrap)
+	}
+
+	t.Setenv(EnvBootstrapUsers, "app")
+	if err := ApplyEnv(&cfg); err == nil {
+		t.Error("expected an error for an invalid bootstrap user")
+	}
+}
+
+func TestCopyReloadable(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 3307
+	cfg.Log.Level = LogLevel(logrus.DebugLevel)
+	cfg.Transpiler.CacheSize = 10
+
+	// The reloaded file sets the port and the translation workers, and no longer sets the log level.
+	next := Default()
+	next.Server.Port = 3308
+	next.Transpiler.Workers = 4
+	cfg.CopyReloadable(&next)
+
+	if cfg.Server.Port != 3307 {
+		t.Errorf("port = %d, want the startup value 3307", cfg.Server.Port)
+	}
+	if want := LogLevel(logrus.InfoLevel); cfg.Log.Level != want {
+		t.Errorf("log level = %v, want the default %v", cfg.Log.Level, want)
+	}
+	if want := (Default().Transpiler); cfg.Transpiler.CacheSize != want.CacheSize || cfg.Transpiler.Workers != 4 {
+		t.Errorf("transpiler = %+v, want the default cache size %d and 4 workers", cfg.Transpiler, want.CacheSize)
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/apache/arrow-go/v18/arrow/ipc"
 	"github.com/apecloud/myduckserver/backend"
 	"github.com/apecloud/myduckserver/binlog"
 	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/metrics"
 	"github.com/dolthub/go-mysql-server/sql"
 	"github.com/dolthub/go-mysql-server/sql/types"
 	"github.com/sirupsen/logrus"
@@ -27,14 +29,14 @@ type FlushStats struct {
 
 type DeltaController struct {
 	mutex  sync.Mutex
-	tables map[tableIdentifier]*DeltaAppender
+	tables map[TableIdentifier]*DeltaAppender
 	pool   *backend.ConnectionPool
 }
 
 func NewController(pool *backend.ConnectionPool) *DeltaController {
 	return &DeltaController{
 		pool:   pool,
-		tables: make(map[tableIdentifier]*DeltaAppender),
+		tables: make(map[TableIdentifier]*DeltaAppender),
 	}
 }
 
@@ -45,7 +47,7 @@ func (c *DeltaController) GetDeltaAppender(
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	id := tableIdentifier{databaseName, tableName}
+	id := TableIdentifier{databaseName, tableName}
 	appender, ok := c.tables[id]
 	if ok {
 		return appender, nil
@@ -68,8 +70,38 @@ func (c *DeltaController) Close() {
 	}
 }
 
+// Buffered reports whether there are accumulated changes to any of |tables|.
+func (c *DeltaController) Buffered(tables []TableIdentifier) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, table := range tables {
+		if appender, ok := c.tables[table]; ok && appender.RowCount() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // Flush writes the accumulated changes to the database.
 func (c *DeltaController) Flush(ctx *sql.Context, tx *stdsql.Tx, reason FlushReason) (FlushStats, error) {
+	_, stats, err := c.flush(ctx, tx, reason, nil)
+	return stats, err
+}
+
+// FlushTables writes the accumulated changes to |tables| to the database, so that a query on them sees the changes.
+// The changes to the other tables are kept in the buffer. It returns the tables that had changes.
+func (c *DeltaController) FlushTables(ctx *sql.Context, tx *stdsql.Tx, tables []TableIdentifier) ([]TableIdentifier, FlushStats, error) {
+	selected := make(map[TableIdentifier]struct{}, len(tables))
+	for _, table := range tables {
+		selected[table] = struct{}{}
+	}
+	return c.flush(ctx, tx, QueryFlushReason, selected)
+}
+
+// flush writes the accumulated changes to the tables in |selected|, or to all tables if it is nil,
+// and returns the tables that had changes.
+func (c *DeltaController) flush(ctx *sql.Context, tx *stdsql.Tx, reason FlushReason, selected map[TableIdentifier]struct{}) ([]TableIdentifier, FlushStats, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -96,16 +128,24 @@ func (c *DeltaController) Flush(ctx *sql.Context, tx *stdsql.Tx, reason FlushRea
 	//  https://github.com/duckdb/duckdb/issues/14133
 	var (
 		// Share the buffer among all tables.
-		buf   bytes.Buffer
-		stats FlushStats
+		buf     bytes.Buffer
+		stats   FlushStats
+		flushed []TableIdentifier
+		start   = time.Now()
 	)
 
 	for table, appender := range c.tables {
+		if selected != nil {
+			if _, ok := selected[table]; !ok {
+				continue
+			}
+		}
 		deltaRowCount := appender.RowCount()
 		if deltaRowCount > 0 {
 			if err := c.updateTable(ctx, tx, table, appender, &buf, &stats); err != nil {
-				return stats, err
+				return flushed, stats, err
 			}
+			flushed = append(flushed, table)
 		}
 		switch reason {
 		case DDLStmtFlushReason:
@@ -121,6 +161,7 @@ func (c *DeltaController) Flush(ctx *sql.Context, tx *stdsql.Tx, reason FlushRea
 	}
 
 	if stats.DeltaSize > 0 {
+		metrics.ObserveDeltaFlush(reason.String(), start, stats.DeltaSize, stats.Insertions, stats.Deletions)
 		if log := ctx.GetLogger(); log.Logger.IsLevelEnabled(logrus.TraceLevel) {
 			ctx.GetLogger().WithFields(logrus.Fields{
 				"DeltaSize":  stats.DeltaSize,
@@ -131,13 +172,13 @@ func (c *DeltaController) Flush(ctx *sql.Context, tx *stdsql.Tx, reason FlushRea
 		}
 	}
 
-	return stats, nil
+	return flushed, stats, nil
 }
 
 func (c *DeltaController) updateTable(
 	ctx *sql.Context,
 	tx *stdsql.Tx,
-	table tableIdentifier,
+	table TableIdentifier,
 	appender *DeltaAppender,
 	buf *bytes.Buffer,
 	stats *FlushStats,
@@ -167,7 +208,7 @@ func (c *DeltaController) updateTable(
 		uintptr(ptr), size,
 	)
 
-	qualifiedTableName := catalog.ConnectIdentifiersANSI(table.dbName, table.tableName)
+	qualifiedTableName := catalog.ConnectIdentifiersANSI(table.DBName, table.TableName)
 
 	pkColumns := make([]int, 0, 1) // Most tables have a single-column primary key
 	for i, col := range schema {
@@ -0,0 +1,51 @@
+package delta
+
+import (
+	"testing"
+
+	"github.com/apecloud/myduckserver/binlog"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffered(t *testing.T) {
+	c := NewController(nil)
+	defer c.Close()
+
+	t1 := TableIdentifier{DBName: "db", TableName: "t1"}
+	t2 := TableIdentifier{DBName: "db", TableName: "t2"}
+	require.False(t, c.Buffered([]TableIdentifier{t1}))
+
+	schema := sql.Schema{{Name: "id", Type: types.Int32, PrimaryKey: true}}
+	appender, err := c.GetDeltaAppender(t1.DBName, t1.TableName, schema)
+	require.NoError(t, err)
+	require.False(t, c.Buffered([]TableIdentifier{t1}), "an empty buffer has no changes")
+
+	appender.Action().Append(int8(binlog.InsertRowEvent))
+	require.True(t, c.Buffered([]TableIdentifier{t1}))
+	require.True(t, c.Buffered([]TableIdentifier{t2, t1}))
+	require.False(t, c.Buffered([]TableIdentifier{t2}))
+	require.False(t, c.Buffered(nil))
+}
+
+func TestFlushTablesSelection(t *testing.T) {
+	c := NewController(nil)
+	defer c.Close()
+
+	t1 := TableIdentifier{DBName: "db", TableName: "t1"}
+	t2 := TableIdentifier{DBName: "db", TableName: "t2"}
+	schema := sql.Schema{{Name: "id", Type: types.Int32, PrimaryKey: true}}
+	appender, err := c.GetDeltaAppender(t1.DBName, t1.TableName, schema)
+	require.NoError(t, err)
+	appender.Action().Append(int8(binlog.InsertRowEvent))
+	_, err = c.GetDeltaAppender(t2.DBName, t2.TableName, schema)
+	require.NoError(t, err)
+
+	// The changes to the tables that are not queried stay in the buffer, and empty buffers are not merged.
+	flushed, stats, err := c.FlushTables(sql.NewEmptyContext(), nil, []TableIdentifier{t2})
+	require.NoError(t, err)
+	require.Empty(t, flushed)
+	require.Zero(t, stats.DeltaSize)
+	require.True(t, c.Buffered([]TableIdentifier{t1}))
+}
@@ -12,8 +12,9 @@ const (
 	AugmentedColumnList = "action, txn_tag, txn_server, txn_group, txn_seq, txn_stmt"
 )
 
-type tableIdentifier struct {
-	dbName, tableName string
+// TableIdentifier identifies a replicated table by its database and name.
+type TableIdentifier struct {
+	DBName, TableName string
 }
 
 type DeltaAppender struct {
@@ -35,6 +35,8 @@ func (r FlushReason) String() string {
 		return "TimeTick"
 	case QueryFlushReason:
 		return "Query"
+	case OnCloseFlushReason:
+		return "OnClose"
 	default:
 		return "Unknown"
 	}
@@ -39,7 +39,7 @@ wait_for_my_duck_server_ready() {
 
     echo "Waiting for MyDuck Server at $host:$port to be ready..."
 
-    until mysqlsh --sql --host "$host" --user "$user" --password="" --port "$port" --execute "SELECT 1;" &> /dev/null; do
+    until mysqlsh --sql --host "$host" --user "$user" --password="${MYDUCK_ROOT_PASSWORD}" --port "$port" --execute "SELECT 1;" &> /dev/null; do
         attempt=$((attempt+1))
         if [ "$attempt" -ge "$max_attempts" ]; then
             echo "Error: MySQL connection timed out after $max_attempts attempts."
@@ -0,0 +1,152 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package flightserver
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authHeader   = "authorization"
+	basicPrefix  = "Basic "
+	bearerPrefix = "Bearer "
+)
+
+type sessionKey struct{}
+
+// sessionFromContext returns the session of an authenticated call.
+func sessionFromContext(ctx context.Context) *session {
+	sess, _ := ctx.Value(sessionKey{}).(*session)
+	return sess
+}
+
+// authMiddleware authenticates every call, as the basic authentication middleware of Flight does.
+// A call with basic credentials, usually the Handshake, opens a session whose bearer token is
+// returned in the response headers; later calls present the token, or the same credentials. Unlike the middleware of Flight,
+// the peer address is known here, which is needed to match accounts by host.
+func (s *Server) authMiddleware() flight.ServerMiddleware {
+	return flight.ServerMiddleware{
+		Unary: func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := s.authenticate(ctx, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) })
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		},
+		Stream: func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.authenticate(stream.Context(), stream.SetHeader)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+		},
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
+
+func (s *Server) authenticate(ctx context.Context, setHeader func(metadata.MD) error) (context.Context, error) {
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(authHeader); len(vals) > 0 {
+			auth = vals[0]
+		}
+	}
+
+	if token, ok := strings.CutPrefix(auth, bearerPrefix); ok {
+		sess := s.lookup(token)
+		if sess == nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+		}
+		return context.WithValue(ctx, sessionKey{}, sess), nil
+	}
+
+	encoded, ok := strings.CutPrefix(auth, basicPrefix)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "basic or bearer authentication required")
+	}
+	user, password, err := decodeBasicAuth(encoded)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	var addr net.Addr = &net.UnixAddr{Name: "localhost"}
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr
+	}
+
+	sess := s.reuse(user, password, addr)
+	if sess == nil {
+		sess, err = s.login(user, password, addr)
+		if audit.Enabled() {
+			auditLogin(user, addr, sess, err)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "access denied for user '%s'", user)
+		}
+	}
+	if err := setHeader(metadata.Pairs(authHeader, bearerPrefix+sess.token)); err != nil {
+		s.endSession(sess)
+		return nil, err
+	}
+	return context.WithValue(ctx, sessionKey{}, sess), nil
+}
+
+func decodeBasicAuth(encoded string) (user, password string, err error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(encoded); err != nil {
+			return "", "", errors.New("invalid basic authentication encoding")
+		}
+	}
+	user, password, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return "", "", errors.New("invalid basic authentication credentials")
+	}
+	return user, password, nil
+}
+
+func auditLogin(user string, addr net.Addr, sess *session, err error) {
+	record := audit.Record{
+		Class:    audit.ClassConnection,
+		Event:    audit.EventLogin,
+		Protocol: metrics.ProtocolFlightSQL,
+		User:     user,
+		Host:     addr.String(),
+		Error:    audit.ErrorString(err),
+	}
+	if err != nil {
+		record.Event = audit.EventLoginFailed
+	} else {
+		record.ConnectionID = sess.conn.ConnectionID
+	}
+	audit.Log(record)
+}
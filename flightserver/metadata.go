@@ -0,0 +1,270 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package flightserver
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql/schema_ref"
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/dolthub/go-mysql-server/sql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The table types reported by GetTables, as named by Flight SQL clients.
+const (
+	tableTypeTable = "TABLE"
+	tableTypeView  = "VIEW"
+)
+
+// hiddenSchemas are the DuckDB schemas that are not MySQL databases.
+var hiddenSchemas = []string{"information_schema", "main", "pg_catalog"}
+
+func (s *Server) flightInfoForCommand(desc *flight.FlightDescriptor, schema *arrow.Schema) *flight.FlightInfo {
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: desc.Cmd}}},
+		FlightDescriptor: desc,
+		Schema:           flight.SerializeSchema(schema, s.Alloc),
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}
+}
+
+// metadataContext creates the context of a metadata request, during which the session's
+// connection is locked. The returned function releases it.
+func (s *Server) metadataContext(ctx context.Context) (*sql.Context, func(), error) {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return nil, nil, status.Error(codes.Unauthenticated, "no session")
+	}
+	sess.mu.Lock()
+	if sess.closed.Load() {
+		sess.mu.Unlock()
+		return nil, nil, status.Error(codes.Unauthenticated, "the session has been closed")
+	}
+	sqlCtx, err := s.newContext(ctx, sess, "")
+	if err != nil {
+		sess.mu.Unlock()
+		return nil, nil, err
+	}
+	return sqlCtx, sess.mu.Unlock, nil
+}
+
+// visibility decides which databases and tables are listed to the user: as in MySQL,
+// those on which the user holds any privilege.
+type visibility struct {
+	privileges sql.PrivilegeSet // nil if the grant tables are disabled
+}
+
+func (s *Server) visibility(ctx *sql.Context) visibility {
+	db := s.engine.Analyzer.Catalog.MySQLDb
+	if !db.Enabled() {
+		return visibility{}
+	}
+	return visibility{privileges: db.UserActivePrivilegeSet(ctx)}
+}
+
+func (v visibility) database(name string) bool {
+	return v.privileges == nil || v.privileges.Count() > 0 || v.privileges.Database(name).HasPrivileges()
+}
+
+func (v visibility) table(database, table string) bool {
+	if v.privileges == nil || v.privileges.Count() > 0 {
+		return true
+	}
+	dbSet := v.privileges.Database(database)
+	return dbSet.Count() > 0 || dbSet.Table(table).HasPrivileges()
+}
+
+func singleBatch(rec arrow.Record) <-chan flight.StreamChunk {
+	ch := make(chan flight.StreamChunk, 1)
+	ch <- flight.StreamChunk{Data: rec}
+	close(ch)
+	return ch
+}
+
+func (s *Server) GetFlightInfoCatalogs(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	return s.flightInfoForCommand(desc, schema_ref.Catalogs), nil
+}
+
+// DoGetCatalogs returns the DuckDB catalog of the server, whose schemas are the MySQL databases.
+func (s *Server) DoGetCatalogs(context.Context) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	b := array.NewRecordBuilder(s.Alloc, schema_ref.Catalogs)
+	defer b.Release()
+	b.Field(0).(*array.StringBuilder).Append(s.catalog)
+	return schema_ref.Catalogs, singleBatch(b.NewRecord()), nil
+}
+
+func (s *Server) GetFlightInfoSchemas(_ context.Context, _ flightsql.GetDBSchemas, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	return s.flightInfoForCommand(desc, schema_ref.DBSchemas), nil
+}
+
+func (s *Server) DoGetDBSchemas(ctx context.Context, cmd flightsql.GetDBSchemas) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	b := array.NewRecordBuilder(s.Alloc, schema_ref.DBSchemas)
+	defer b.Release()
+	if c := cmd.GetCatalog(); c != nil && *c != s.catalog {
+		return schema_ref.DBSchemas, singleBatch(b.NewRecord()), nil
+	}
+
+	sqlCtx, release, err := s.metadataContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	query := "SELECT schema_name FROM information_schema.schemata WHERE catalog_name = ? AND schema_name NOT IN (" + placeholders(len(hiddenSchemas)) + ")"
+	args := []any{s.catalog}
+	for _, name := range hiddenSchemas {
+		args = append(args, name)
+	}
+	if p := cmd.GetDBSchemaFilterPattern(); p != nil {
+		query += " AND schema_name LIKE ?"
+		args = append(args, *p)
+	}
+	rows, err := adapter.QueryCatalog(sqlCtx, query+" ORDER BY schema_name", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	v := s.visibility(sqlCtx)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, nil, err
+		}
+		if v.database(name) {
+			b.Field(0).(*array.StringBuilder).Append(s.catalog)
+			b.Field(1).(*array.StringBuilder).Append(name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return schema_ref.DBSchemas, singleBatch(b.NewRecord()), nil
+}
+
+func (s *Server) GetFlightInfoTables(_ context.Context, cmd flightsql.GetTables, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if cmd.GetIncludeSchema() {
+		return s.flightInfoForCommand(desc, schema_ref.TablesWithIncludedSchema), nil
+	}
+	return s.flightInfoForCommand(desc, schema_ref.Tables), nil
+}
+
+func (s *Server) DoGetTables(ctx context.Context, cmd flightsql.GetTables) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	schema := schema_ref.Tables
+	if cmd.GetIncludeSchema() {
+		schema = schema_ref.TablesWithIncludedSchema
+	}
+	b := array.NewRecordBuilder(s.Alloc, schema)
+	defer b.Release()
+	if c := cmd.GetCatalog(); c != nil && *c != s.catalog {
+		return schema, singleBatch(b.NewRecord()), nil
+	}
+
+	sqlCtx, release, err := s.metadataContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	query := "SELECT table_schema, table_name, table_type FROM information_schema.tables WHERE table_catalog = ? AND table_schema NOT IN (" + placeholders(len(hiddenSchemas)) + ")"
+	args := []any{s.catalog}
+	for _, name := range hiddenSchemas {
+		args = append(args, name)
+	}
+	if p := cmd.GetDBSchemaFilterPattern(); p != nil {
+		query += " AND table_schema LIKE ?"
+		args = append(args, *p)
+	}
+	if p := cmd.GetTableNameFilterPattern(); p != nil {
+		query += " AND table_name LIKE ?"
+		args = append(args, *p)
+	}
+	rows, err := adapter.QueryCatalog(sqlCtx, query+" ORDER BY table_schema, table_name", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	type table struct{ schema, name, typ string }
+	var tables []table
+	v := s.visibility(sqlCtx)
+	types := cmd.GetTableTypes()
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.schema, &t.name, &t.typ); err != nil {
+			return nil, nil, err
+		}
+		if t.typ == "VIEW" {
+			t.typ = tableTypeView
+		} else {
+			t.typ = tableTypeTable
+		}
+		if (len(types) == 0 || slices.Contains(types, t.typ)) && v.table(t.schema, t.name) {
+			tables = append(tables, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, t := range tables {
+		b.Field(0).(*array.StringBuilder).Append(s.catalog)
+		b.Field(1).(*array.StringBuilder).Append(t.schema)
+		b.Field(2).(*array.StringBuilder).Append(t.name)
+		b.Field(3).(*array.StringBuilder).Append(t.typ)
+		if cmd.GetIncludeSchema() {
+			tableSchema, err := s.tableSchema(sqlCtx, t.schema, t.name)
+			if err != nil {
+				return nil, nil, err
+			}
+			b.Field(4).(*array.BinaryBuilder).Append(flight.SerializeSchema(tableSchema, s.Alloc))
+		}
+	}
+	return schema, singleBatch(b.NewRecord()), nil
+}
+
+// tableSchema returns the Arrow schema of a table, which is that of the results of queries on it.
+func (s *Server) tableSchema(ctx *sql.Context, schema, table string) (*arrow.Schema, error) {
+	rdr, err := adapter.QueryArrow(ctx, "SELECT * FROM "+catalog.ConnectIdentifiersANSI(s.catalog, schema, table)+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Release()
+	return rdr.Schema(), nil
+}
+
+func (s *Server) GetFlightInfoTableTypes(_ context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	return s.flightInfoForCommand(desc, schema_ref.TableTypes), nil
+}
+
+func (s *Server) DoGetTableTypes(context.Context) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	b := array.NewRecordBuilder(s.Alloc, schema_ref.TableTypes)
+	defer b.Release()
+	b.Field(0).(*array.StringBuilder).AppendValues([]string{tableTypeTable, tableTypeView}, nil)
+	return schema_ref.TableTypes, singleBatch(b.NewRecord()), nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,163 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flightserver implements an Arrow Flight SQL listener.
+//
+// Queries are written in DuckDB syntax, as on the PostgreSQL port, and their results
+// are sent as the Arrow record batches produced by DuckDB, without the per-value
+// conversions of the MySQL and PostgreSQL wire protocols. The whole result of a query
+// is materialized in memory before it is sent. Clients log in with HTTP basic
+// authentication against the MySQL accounts and receive a bearer token that
+// identifies their session, which owns a DuckDB connection of the pool like the
+// sessions of the other listeners. A client that keeps sending basic credentials
+// instead of the token gets the session of its previous login back.
+package flightserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/catalog"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// sessionIdleTimeout is how long a session is kept without requests. Flight SQL has no
+// connection to close, so sessions that clients do not close explicitly are reaped.
+const sessionIdleTimeout = 30 * time.Minute
+
+// Server serves Flight SQL on top of the MySQL server's engine and session manager.
+type Server struct {
+	flightsql.BaseServer
+
+	engine   *sqle.Engine
+	sm       *server.SessionManager
+	listener *mysql.Listener // the connection IDs are shared with the MySQL listener
+	pool     *backend.ConnectionPool
+	catalog  string
+
+	mu       sync.Mutex
+	sessions map[string]*session // by bearer token
+	clients  map[string]*session // by user and peer address, for the calls with basic credentials
+
+	flight flight.Server
+	done   chan struct{}
+}
+
+// NewServer listens on |addr| for Flight SQL requests. A nil |tlsConfig| serves plain gRPC.
+func NewServer(addr string, srv *server.Server, provider *catalog.DatabaseProvider, pool *backend.ConnectionPool, tlsConfig *tls.Config) (*Server, error) {
+	s := &Server{
+		engine:   srv.Engine,
+		sm:       srv.SessionManager(),
+		listener: srv.Listener.(*mysql.Listener),
+		pool:     pool,
+		catalog:  provider.CatalogName(),
+		sessions: make(map[string]*session),
+		clients:  make(map[string]*session),
+		done:     make(chan struct{}),
+	}
+	s.Alloc = memory.DefaultAllocator
+	for id, v := range map[flightsql.SqlInfo]any{
+		flightsql.SqlInfoFlightSqlServerName:     "MyDuck Server",
+		flightsql.SqlInfoFlightSqlServerReadOnly: false,
+		flightsql.SqlInfoIdentifierQuoteChar:     `"`,
+	} {
+		if err := s.RegisterSqlInfo(id, v); err != nil {
+			return nil, err
+		}
+	}
+
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.flight = flight.NewServerWithMiddleware([]flight.ServerMiddleware{s.authMiddleware()}, opts...)
+	s.flight.RegisterFlightService(flightsql.NewFlightServer(s))
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.flight.InitListener(l)
+	return s, nil
+}
+
+// Start serves Flight SQL until the server is closed.
+func (s *Server) Start() {
+	logrus.Infof("Flight SQL listening on %s", s.flight.Addr())
+	go s.reapIdleSessions()
+	if err := s.flight.Serve(); err != nil {
+		logrus.Errorln("Flight SQL stopped:", err)
+	}
+}
+
+// Close stops the server and closes the sessions. In-flight calls are waited for until |ctx| is done.
+func (s *Server) Close(ctx context.Context) error {
+	close(s.done)
+	stopped := make(chan struct{})
+	go func() {
+		s.flight.Shutdown()
+		close(stopped)
+	}()
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.clients = make(map[string]*session)
+	s.mu.Unlock()
+	for _, sess := range sessions {
+		s.closeSession(sess)
+	}
+	return err
+}
+
+func (s *Server) reapIdleSessions() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			var idle []*session
+			s.mu.Lock()
+			for _, sess := range s.sessions {
+				if now.Sub(sess.lastUsed()) > sessionIdleTimeout {
+					s.forget(sess)
+					idle = append(idle, sess)
+				}
+			}
+			s.mu.Unlock()
+			for _, sess := range idle {
+				s.closeSession(sess)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package flightserver
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apecloud/myduckserver/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startTestServer starts a Flight SQL server on the server of testutil.NewServer.
+func startTestServer(t *testing.T) string {
+	srv := testutil.NewServer(t)
+	s, err := NewServer("127.0.0.1:0", srv.Server, srv.Provider, srv.Pool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(func() { s.Close(context.Background()) })
+	return s.flight.Addr().String()
+}
+
+func connect(t *testing.T, addr, user, password string) (*flightsql.Client, context.Context, error) {
+	client, err := flightsql.NewClient(addr, nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	ctx, err := client.Client.AuthenticateBasicToken(context.Background(), user, password)
+	return client, ctx, err
+}
+
+// fetch reads all the records of the first endpoint of |info|.
+func fetch(t *testing.T, ctx context.Context, client *flightsql.Client, info *flight.FlightInfo) [][]any {
+	rdr, err := client.DoGet(ctx, info.Endpoint[0].Ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Release()
+	var rows [][]any
+	for rdr.Next() {
+		rec := rdr.Record()
+		for i := 0; i < int(rec.NumRows()); i++ {
+			var row []any
+			for _, col := range rec.Columns() {
+				row = append(row, col.GetOneForMarshal(i))
+			}
+			rows = append(rows, row)
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestQuery(t *testing.T) {
+	addr := startTestServer(t)
+	client, ctx, err := connect(t, addr, "root", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := client.Execute(ctx, "SELECT id, name FROM db1.t WHERE id > 1 ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := fetch(t, ctx, client, info)
+	if len(rows) != 2 || rows[0][0] != int32(2) || rows[1][1] != nil {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+
+	n, err := client.ExecuteUpdate(ctx, "DELETE FROM db1.t WHERE id = 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 affected row, got %d", n)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	addr := startTestServer(t)
+	client, ctx, err := connect(t, addr, "root", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := "db%"
+	info, err := client.GetDBSchemas(ctx, &flightsql.GetDBSchemasOpts{DbSchemaFilterPattern: &pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := fetch(t, ctx, client, info)
+	if len(rows) != 1 || rows[0][1] != "db1" {
+		t.Errorf("unexpected schemas: %v", rows)
+	}
+
+	info, err = client.GetTables(ctx, &flightsql.GetTablesOpts{DbSchemaFilterPattern: &pattern, IncludeSchema: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdr, err := client.DoGet(ctx, info.Endpoint[0].Ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Release()
+	if !rdr.Next() {
+		t.Fatal("no tables returned")
+	}
+	rec := rdr.Record()
+	if rec.NumRows() != 1 || rec.Column(2).(*array.String).Value(0) != "t" || rec.Column(3).(*array.String).Value(0) != tableTypeTable {
+		t.Fatalf("unexpected tables: %v", rec)
+	}
+	schema, err := flight.DeserializeSchema(rec.Column(4).(*array.Binary).Value(0), client.Alloc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.NumFields() != 2 || schema.Field(1).Name != "name" {
+		t.Errorf("unexpected table schema: %v", schema)
+	}
+}
+
+func TestPrivileges(t *testing.T) {
+	addr := startTestServer(t)
+	if _, _, err := connect(t, addr, "root", "wrong"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a wrong password to be rejected, got %v", err)
+	}
+
+	client, ctx, err := connect(t, addr, "nobody", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := client.Execute(ctx, "SELECT * FROM db1.t")
+	if err == nil {
+		_, err = client.DoGet(ctx, info.Endpoint[0].Ticket)
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected the query to be denied, got %v", err)
+	}
+
+	info, err = client.GetTables(ctx, &flightsql.GetTablesOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := fetch(t, ctx, client, info); len(rows) != 0 {
+		t.Errorf("expected no visible tables, got %v", rows)
+	}
+}
+
+func TestBasicSessionReuse(t *testing.T) {
+	addr := startTestServer(t)
+	client, err := flightsql.NewClient(addr, nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Every call carries the basic credentials instead of the bearer token.
+	call := func(password string) (string, error) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(),
+			authHeader, basicPrefix+base64.StdEncoding.EncodeToString([]byte("root:"+password)))
+		var header metadata.MD
+		_, err := client.Execute(ctx, "SELECT 1", grpc.Header(&header))
+		if vals := header.Get(authHeader); len(vals) > 0 {
+			return vals[0], err
+		}
+		return "", err
+	}
+	first, err := call("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := call("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == "" || first != second {
+		t.Errorf("expected the session to be reused, got the tokens %q and %q", first, second)
+	}
+	if _, err := call("wrong"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a wrong password to be rejected, got %v", err)
+	}
+}
+
+func TestCountRecords(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int32}}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	var recs []arrow.Record
+	for _, n := range []int{3, 0, 2} {
+		for i := 0; i < n; i++ {
+			b.Field(0).(*array.Int32Builder).Append(int32(i))
+		}
+		rec := b.NewRecord()
+		defer rec.Release()
+		recs = append(recs, rec)
+	}
+	rdr, err := array.NewRecordReader(schema, recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counted, rows, err := countRecords(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer counted.Release()
+	if rows != 5 {
+		t.Errorf("expected 5 rows, got %d", rows)
+	}
+	var read int64
+	for counted.Next() {
+		read += counted.Record().NumRows()
+	}
+	if read != 5 {
+		t.Errorf("expected the records to be read again, got %d rows", read)
+	}
+}
+
+func TestDecodeBasicAuth(t *testing.T) {
+	user, password, err := decodeBasicAuth("cm9vdDpwOnc=") // root:p:w
+	if err != nil || user != "root" || password != "p:w" {
+		t.Errorf("unexpected credentials %q, %q, %v", user, password, err)
+	}
+	if _, _, err := decodeBasicAuth("cm9vdA=="); err == nil { // root
+		t.Error("expected an error for credentials without a password")
+	}
+}
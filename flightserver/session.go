@@ -0,0 +1,187 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package flightserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apecloud/myduckserver/plugin"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// session is a logged-in client. Its statements run on the DuckDB connection of the pool
+// that belongs to its connection ID, one at a time.
+type session struct {
+	token string
+	conn  *mysql.Conn
+
+	// client and credential identify the basic credentials that opened the session and the peer that sent them.
+	client     string
+	credential [sha256.Size]byte
+
+	mu       sync.Mutex
+	lastSeen atomic.Int64 // Unix nanoseconds
+	closed   atomic.Bool
+}
+
+func (sess *session) touch() {
+	sess.lastSeen.Store(time.Now().UnixNano())
+}
+
+func (sess *session) lastUsed() time.Time {
+	return time.Unix(0, sess.lastSeen.Load())
+}
+
+// peerConn stands in for the network connection of a session, which spans many gRPC calls.
+// The session manager only asks for its address, and closes it to kill the connection.
+type peerConn struct {
+	net.Conn
+	addr  net.Addr
+	close func()
+}
+
+func (c *peerConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *peerConn) Close() error {
+	c.close()
+	return nil
+}
+
+// clientKey identifies the client of a call with basic credentials by its user and peer address.
+// A gRPC client sends all its calls over the same connection.
+func clientKey(user string, addr net.Addr) string {
+	return user + "@" + addr.String()
+}
+
+// reuse returns the session opened by an earlier call of the client with the same basic credentials,
+// or nil if there is none.
+func (s *Server) reuse(user, password string, addr net.Addr) *session {
+	credential := sha256.Sum256([]byte(password))
+	s.mu.Lock()
+	sess := s.clients[clientKey(user, addr)]
+	s.mu.Unlock()
+	if sess == nil || sess.closed.Load() || subtle.ConstantTimeCompare(sess.credential[:], credential[:]) != 1 {
+		return nil
+	}
+	sess.touch()
+	return sess
+}
+
+// login verifies the credentials of |user| connecting from |addr| and opens a session.
+func (s *Server) login(user, password string, addr net.Addr) (*session, error) {
+	host := "localhost"
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		host = tcp.IP.String()
+	}
+
+	conn := &mysql.Conn{
+		ConnectionID: s.listener.ConnectionID.Add(1),
+		User:         user,
+		UserData:     sql.MysqlConnectionUser{User: user, Host: host},
+	}
+	if db := s.engine.Analyzer.Catalog.MySQLDb; db.Enabled() {
+		account, err := plugin.VerifyPassword(db, user, host, password)
+		if err != nil {
+			return nil, err
+		}
+		// Privileges are granted to the matched account, which may have a wildcard host.
+		conn.UserData = sql.MysqlConnectionUser{User: account.User, Host: account.Host}
+	}
+
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	sess := &session{
+		token:      hex.EncodeToString(token),
+		conn:       conn,
+		client:     clientKey(user, addr),
+		credential: sha256.Sum256([]byte(password)),
+	}
+	// The session manager holds its lock while closing the connection, so the session ends asynchronously.
+	conn.Conn = &peerConn{addr: addr, close: func() { go s.endSession(sess) }}
+	sess.touch()
+
+	s.mu.Lock()
+	s.sessions[sess.token] = sess
+	s.clients[sess.client] = sess
+	s.mu.Unlock()
+	s.sm.AddConn(conn)
+	logrus.WithField(sql.ConnectionIdLogField, conn.ConnectionID).Infof("NewConnection: Flight SQL user %q from %s", user, addr)
+	return sess, nil
+}
+
+// lookup returns the session of a bearer token, or nil if the token is unknown or expired.
+func (s *Server) lookup(token string) *session {
+	s.mu.Lock()
+	sess := s.sessions[token]
+	s.mu.Unlock()
+	if sess != nil {
+		sess.touch()
+	}
+	return sess
+}
+
+// endSession removes a session, e.g., on a CloseSession request or a KILL.
+func (s *Server) endSession(sess *session) {
+	s.mu.Lock()
+	s.forget(sess)
+	s.mu.Unlock()
+	s.closeSession(sess)
+}
+
+// forget removes a session from the lookup tables. The caller holds s.mu.
+func (s *Server) forget(sess *session) {
+	delete(s.sessions, sess.token)
+	if s.clients[sess.client] == sess {
+		delete(s.clients, sess.client)
+	}
+}
+
+func (s *Server) closeSession(sess *session) {
+	if sess.closed.Swap(true) {
+		return
+	}
+	// Wait for the running statement, which uses the DuckDB connection.
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	s.pool.CloseConn(sess.conn.ConnectionID)
+	s.engine.CloseSession(sess.conn.ConnectionID)
+	s.sm.RemoveConn(sess.conn)
+	logrus.WithField(sql.ConnectionIdLogField, sess.conn.ConnectionID).Infof("ConnectionClosed")
+}
+
+// newContext creates the context of a statement of the session.
+func (s *Server) newContext(ctx context.Context, sess *session, query string) (*sql.Context, error) {
+	return s.sm.NewContextWithQuery(ctx, sess.conn, query)
+}
+
+// CloseSession ends the session of the caller, releasing its DuckDB connection.
+func (s *Server) CloseSession(ctx context.Context, _ *flight.CloseSessionRequest) (*flight.CloseSessionResult, error) {
+	if sess := sessionFromContext(ctx); sess != nil {
+		s.endSession(sess)
+	}
+	return &flight.CloseSessionResult{Status: flight.CloseSessionResultClosed}, nil
+}
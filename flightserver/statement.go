@@ -0,0 +1,175 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package flightserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/flight/flightsql"
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/metrics"
+	"github.com/apecloud/myduckserver/pgserver"
+	"github.com/dolthub/go-mysql-server/sql"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetFlightInfoStatement returns a ticket that carries the query, which is run by DoGetStatement.
+func (s *Server) GetFlightInfoStatement(ctx context.Context, cmd flightsql.StatementQuery, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if len(cmd.GetTransactionId()) > 0 {
+		return nil, status.Error(codes.Unimplemented, "transactions are not supported")
+	}
+	ticket, err := flightsql.CreateStatementQueryTicket([]byte(cmd.GetQuery()))
+	if err != nil {
+		return nil, err
+	}
+	return &flight.FlightInfo{
+		Endpoint:         []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: ticket}}},
+		FlightDescriptor: desc,
+		TotalRecords:     -1,
+		TotalBytes:       -1,
+	}, nil
+}
+
+// DoGetStatement runs the query of a ticket on the session's DuckDB connection and streams its result.
+//
+// The Arrow interface of go-duckdb materializes the whole result before it returns, so the result is
+// held in memory until it has been sent, and the recorded runtime covers the query but not the transfer.
+func (s *Server) DoGetStatement(ctx context.Context, ticket flightsql.StatementQueryTicket) (*arrow.Schema, <-chan flight.StreamChunk, error) {
+	query := string(ticket.GetStatementHandle())
+	var rdr array.RecordReader
+	err := s.runStatement(ctx, audit.EventQuery, query, func(sqlCtx *sql.Context) (int64, error) {
+		result, err := adapter.QueryArrow(sqlCtx, query)
+		if err != nil {
+			return 0, err
+		}
+		var rows int64
+		rdr, rows, err = countRecords(result)
+		return rows, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	ch := make(chan flight.StreamChunk, 2)
+	go flight.StreamChunksFromReader(rdr, ch)
+	return rdr.Schema(), ch, nil
+}
+
+// countRecords reads the records of |rdr|, which are already in memory, and returns a reader
+// over them along with their number of rows. |rdr| is released.
+func countRecords(rdr array.RecordReader) (array.RecordReader, int64, error) {
+	defer rdr.Release()
+	var recs []arrow.Record
+	defer func() {
+		for _, rec := range recs {
+			rec.Release()
+		}
+	}()
+	var rows int64
+	for rdr.Next() {
+		rec := rdr.Record()
+		rec.Retain()
+		recs = append(recs, rec)
+		rows += rec.NumRows()
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, 0, err
+	}
+	counted, err := array.NewRecordReader(rdr.Schema(), recs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return counted, rows, nil
+}
+
+// DoPutCommandStatementUpdate runs a statement that returns no result, such as an INSERT.
+func (s *Server) DoPutCommandStatementUpdate(ctx context.Context, cmd flightsql.StatementUpdate) (int64, error) {
+	if len(cmd.GetTransactionId()) > 0 {
+		return 0, status.Error(codes.Unimplemented, "transactions are not supported")
+	}
+	var affected int64
+	err := s.runStatement(ctx, audit.EventExecute, cmd.GetQuery(), func(sqlCtx *sql.Context) (int64, error) {
+		res, err := adapter.Exec(sqlCtx, cmd.GetQuery())
+		if err != nil {
+			return 0, err
+		}
+		affected, err = res.RowsAffected()
+		return affected, err
+	})
+	return affected, err
+}
+
+// runStatement checks a statement against the session's grants and runs it with |exec|,
+// recording it in the process list, the metrics and the audit log.
+func (s *Server) runStatement(ctx context.Context, event string, query string, exec func(*sql.Context) (int64, error)) (err error) {
+	sess := sessionFromContext(ctx)
+	if sess == nil {
+		return status.Error(codes.Unauthenticated, "no session")
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed.Load() {
+		return status.Error(codes.Unauthenticated, "the session has been closed")
+	}
+
+	start := time.Now()
+	var rows int64
+	defer func() {
+		metrics.ObserveQuery(metrics.ProtocolFlightSQL, start, err)
+		if audit.Enabled() {
+			s.auditStatement(sess, event, query, start, rows, err)
+		}
+	}()
+
+	sqlCtx, err := s.newContext(ctx, sess, query)
+	if err != nil {
+		return err
+	}
+	if err := pgserver.AuthorizeQuery(sqlCtx, s.engine, query); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	sqlCtx, err = sqlCtx.ProcessList.BeginQuery(sqlCtx, query)
+	if err != nil {
+		return err
+	}
+	defer sqlCtx.ProcessList.EndQuery(sqlCtx)
+
+	rows, err = exec(sqlCtx)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func (s *Server) auditStatement(sess *session, event string, query string, start time.Time, rows int64, err error) {
+	audit.Log(audit.Record{
+		Time:         start,
+		Class:        audit.ClassStatement,
+		Event:        event,
+		ConnectionID: sess.conn.ConnectionID,
+		Protocol:     metrics.ProtocolFlightSQL,
+		User:         sess.conn.User,
+		Host:         sess.conn.RemoteAddr().String(),
+		Schema:       s.sm.GetCurrentDB(sess.conn),
+		Statement:    query,
+		DurationUs:   time.Since(start).Microseconds(),
+		Rows:         rows,
+		Error:        audit.ErrorString(err),
+	})
+}
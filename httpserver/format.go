@@ -0,0 +1,180 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package httpserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/csv"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// recordWriter encodes the records of a result. Close writes what the format puts after the records.
+type recordWriter interface {
+	Write(rec arrow.Record) error
+	Close() error
+}
+
+// format is an output format of query results.
+type format struct {
+	name        string
+	contentType string
+	newWriter   func(w io.Writer, schema *arrow.Schema) (recordWriter, error)
+}
+
+// formats are the output formats, the first of which is the default.
+var formats = []*format{
+	{name: "json", contentType: "application/json", newWriter: newJSONWriter},
+	{name: "csv", contentType: "text/csv", newWriter: newCSVWriter},
+	{name: "parquet", contentType: "application/vnd.apache.parquet", newWriter: newParquetWriter},
+	{name: "arrow", contentType: "application/vnd.apache.arrow.stream", newWriter: newArrowWriter},
+}
+
+// negotiateFormat picks the output format by the "format" parameter if it is set,
+// or else by the first media type of the Accept header that has a format.
+func negotiateFormat(param, accept string) (*format, error) {
+	if param != "" {
+		for _, f := range formats {
+			if strings.EqualFold(f.name, param) {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown format %q", param)
+	}
+	if accept == "" {
+		return formats[0], nil
+	}
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		if mediaType == "*/*" || mediaType == "application/*" {
+			return formats[0], nil
+		}
+		for _, f := range formats {
+			if mediaType == f.contentType {
+				return f, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("none of the accepted media types %q is supported", accept)
+}
+
+// jsonWriter writes an object with the columns of the result and its rows as objects:
+//
+//	{"meta": [{"name": "id", "type": "int32"}], "data": [{"id": 1}], "rows": 1}
+type jsonWriter struct {
+	w     *bufio.Writer
+	names [][]byte // the encoded column names
+	rows  int64
+}
+
+func newJSONWriter(w io.Writer, schema *arrow.Schema) (recordWriter, error) {
+	type column struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	jw := &jsonWriter{w: bufio.NewWriter(w)}
+	meta := make([]column, schema.NumFields())
+	for i, field := range schema.Fields() {
+		meta[i] = column{Name: field.Name, Type: field.Type.String()}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		jw.names = append(jw.names, name)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	jw.w.WriteString(`{"meta":`)
+	jw.w.Write(data)
+	jw.w.WriteString(`,"data":[`)
+	return jw, nil
+}
+
+func (jw *jsonWriter) Write(rec arrow.Record) error {
+	for i := 0; i < int(rec.NumRows()); i++ {
+		if jw.rows > 0 {
+			jw.w.WriteByte(',')
+		}
+		jw.w.WriteByte('{')
+		for j, col := range rec.Columns() {
+			if j > 0 {
+				jw.w.WriteByte(',')
+			}
+			v, err := json.Marshal(col.GetOneForMarshal(i))
+			if err != nil {
+				return err
+			}
+			jw.w.Write(jw.names[j])
+			jw.w.WriteByte(':')
+			jw.w.Write(v)
+		}
+		if err := jw.w.WriteByte('}'); err != nil {
+			return err
+		}
+		jw.rows++
+	}
+	return nil
+}
+
+func (jw *jsonWriter) Close() error {
+	fmt.Fprintf(jw.w, `],"rows":%d}`+"\n", jw.rows)
+	return jw.w.Flush()
+}
+
+// csvWriter writes a header line with the column names, then the rows, with NULL as an empty field.
+type csvWriter struct {
+	*csv.Writer
+}
+
+func newCSVWriter(w io.Writer, schema *arrow.Schema) (recordWriter, error) {
+	cw := csvWriter{csv.NewWriter(w, schema, csv.WithHeader(true), csv.WithNullWriter(""))}
+	// The header is written with the first record, so an empty result still has one.
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	empty := b.NewRecord()
+	defer empty.Release()
+	if err := cw.Writer.Write(empty); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw csvWriter) Close() error {
+	return cw.Flush()
+}
+
+func newParquetWriter(w io.Writer, schema *arrow.Schema) (recordWriter, error) {
+	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	return pqarrow.NewFileWriter(schema, w, props, pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema()))
+}
+
+func newArrowWriter(w io.Writer, schema *arrow.Schema) (recordWriter, error) {
+	return ipc.NewWriter(w, ipc.WithSchema(schema)), nil
+}
@@ -0,0 +1,360 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpserver implements an HTTP query interface in the style of ClickHouse's,
+// for clients that cannot keep a database connection open.
+//
+// Each request carries one statement in its body, written in the MySQL dialect, which is
+// translated to DuckDB SQL, or in DuckDB SQL as on the PostgreSQL port. The statement runs in
+// a session of its own for the authenticated MySQL account, and its result is returned as
+// JSON, CSV, Parquet or an Arrow IPC stream.
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/metrics"
+	"github.com/apecloud/myduckserver/pgserver"
+	"github.com/apecloud/myduckserver/plugin"
+	"github.com/apecloud/myduckserver/transpiler"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/vitess/go/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// maxQuerySize bounds the request body, which holds a single statement.
+const maxQuerySize = 16 << 20
+
+// The SQL dialects accepted by the "dialect" parameter.
+const (
+	DialectMySQL  = "mysql"
+	DialectDuckDB = "duckdb"
+)
+
+// Server serves queries over HTTP on top of the MySQL server's engine and session manager.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+
+	engine *sqle.Engine
+	sm     *server.SessionManager
+	ids    *mysql.Listener // the connection IDs are shared with the MySQL listener
+	pool   *backend.ConnectionPool
+}
+
+// NewServer listens on |addr| for queries. A nil |tlsConfig| serves plain HTTP.
+func NewServer(addr string, srv *server.Server, pool *backend.ConnectionPool, tlsConfig *tls.Config) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	s := &Server{
+		listener: l,
+		engine:   srv.Engine,
+		sm:       srv.SessionManager(),
+		ids:      srv.Listener.(*mysql.Listener),
+		pool:     pool,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", s.handleQuery)
+	s.srv = &http.Server{Handler: mux}
+	return s, nil
+}
+
+// Start serves queries until the server is closed.
+func (s *Server) Start() {
+	logrus.Infof("HTTP query interface listening on %s", s.listener.Addr())
+	if err := s.srv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorln("HTTP query interface stopped:", err)
+	}
+}
+
+// Close stops the server, waiting for in-flight queries until |ctx| is done.
+func (s *Server) Close(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// handleQuery runs the statement in the request body. The parameters are:
+//   - dialect: "mysql" (the default) or "duckdb";
+//   - database: the current database of the statement;
+//   - format: "json" (the default), "csv", "parquet" or "arrow", which overrides the Accept header.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	format, err := negotiateFormat(params.Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		writeError(w, http.StatusNotAcceptable, err)
+		return
+	}
+	dialect := params.Get("dialect")
+	if dialect == "" {
+		dialect = DialectMySQL
+	}
+	if dialect != DialectMySQL && dialect != DialectDuckDB {
+		writeError(w, http.StatusBadRequest, errors.New("unknown dialect "+dialect))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxQuerySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	query := strings.TrimSpace(string(body))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the request body must contain a statement"))
+		return
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="myduckserver"`)
+		writeError(w, http.StatusUnauthorized, errors.New("basic authentication required"))
+		return
+	}
+	sess, err := s.login(r, user, password)
+	if audit.Enabled() {
+		auditLogin(user, r.RemoteAddr, sess, err)
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="myduckserver"`)
+		writeError(w, http.StatusUnauthorized, errors.New("access denied for user '"+user+"'"))
+		return
+	}
+	defer s.closeSession(sess)
+
+	start := time.Now()
+	rows, err := s.runQuery(sess, params.Get("database"), dialect, query, format, w)
+	metrics.ObserveQuery(metrics.ProtocolHTTP, start, err)
+	if audit.Enabled() {
+		s.auditStatement(sess, query, start, rows, err)
+	}
+	if err != nil {
+		var streamErr *streamError
+		if errors.As(err, &streamErr) {
+			// The status has been sent: drop the connection so that the client sees a truncated response.
+			logrus.WithField(sql.ConnectionIdLogField, sess.conn.ConnectionID).Warnln("Failed to write the query result:", err)
+			panic(http.ErrAbortHandler)
+		}
+		writeError(w, errorStatus(err), err)
+	}
+}
+
+// runQuery authorizes and runs a statement of the session, then writes its result to |w|.
+func (s *Server) runQuery(sess *session, database, dialect, query string, format *format, w http.ResponseWriter) (int64, error) {
+	if database != "" {
+		if err := s.sm.SetDB(sess.conn, database); err != nil {
+			return 0, &requestError{err}
+		}
+	}
+	duckSQL := query
+	if dialect == DialectMySQL {
+		var err error
+		if duckSQL, err = transpiler.Translate(query); err != nil {
+			return 0, &requestError{catalog.ErrTranspiler.New(err)}
+		}
+	}
+
+	ctx, err := s.sm.NewContextWithQuery(sess.ctx, sess.conn, query)
+	if err != nil {
+		return 0, err
+	}
+	if err := pgserver.AuthorizeQuery(ctx, s.engine, duckSQL); err != nil {
+		return 0, &forbiddenError{err}
+	}
+	ctx, err = ctx.ProcessList.BeginQuery(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer ctx.ProcessList.EndQuery(ctx)
+
+	rdr, err := adapter.QueryArrow(ctx, duckSQL)
+	if err != nil {
+		return 0, &requestError{err}
+	}
+	defer rdr.Release()
+	return writeResult(w, format, rdr)
+}
+
+// writeResult writes the records of |rdr| in |format| and returns the number of rows written.
+func writeResult(w http.ResponseWriter, format *format, rdr array.RecordReader) (int64, error) {
+	w.Header().Set("Content-Type", format.contentType)
+	w.WriteHeader(http.StatusOK)
+	rw, err := format.newWriter(w, rdr.Schema())
+	if err != nil {
+		return 0, &streamError{err}
+	}
+	var rows int64
+	for rdr.Next() {
+		rec := rdr.Record()
+		if err := rw.Write(rec); err != nil {
+			return rows, &streamError{err}
+		}
+		rows += rec.NumRows()
+	}
+	if err := rdr.Err(); err != nil {
+		return rows, &streamError{err}
+	}
+	if err := rw.Close(); err != nil {
+		return rows, &streamError{err}
+	}
+	return rows, nil
+}
+
+// requestError is an error caused by the statement, such as a syntax error or an unknown table.
+type requestError struct{ error }
+
+func (e *requestError) Unwrap() error { return e.error }
+
+// forbiddenError is an error raised by the privilege checks.
+type forbiddenError struct{ error }
+
+func (e *forbiddenError) Unwrap() error { return e.error }
+
+// streamError is an error raised after the response status has been sent.
+type streamError struct{ error }
+
+func (e *streamError) Unwrap() error { return e.error }
+
+func errorStatus(err error) int {
+	var reqErr *requestError
+	var forbidden *forbiddenError
+	switch {
+	case errors.As(err, &reqErr):
+		return http.StatusBadRequest
+	case errors.As(err, &forbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+		logrus.Warnln("Failed to write HTTP query response:", err)
+	}
+}
+
+// login verifies the credentials of |user| and opens a session for the request.
+func (s *Server) login(r *http.Request, user, password string) (*session, error) {
+	host := "localhost"
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	conn := &mysql.Conn{
+		ConnectionID: s.ids.ConnectionID.Add(1),
+		User:         user,
+		UserData:     sql.MysqlConnectionUser{User: user, Host: host},
+	}
+	if db := s.engine.Analyzer.Catalog.MySQLDb; db.Enabled() {
+		account, err := plugin.VerifyPassword(db, user, host, password)
+		if err != nil {
+			return nil, err
+		}
+		// Privileges are granted to the matched account, which may have a wildcard host.
+		conn.UserData = sql.MysqlConnectionUser{User: account.User, Host: account.Host}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		addr = &net.TCPAddr{}
+	}
+	// A KILL closes the connection, which cancels the running statement.
+	conn.Conn = &requestConn{addr: addr, cancel: cancel}
+	s.sm.AddConn(conn)
+	return &session{conn: conn, ctx: ctx, cancel: cancel}, nil
+}
+
+// closeSession releases the DuckDB connection and the session of a request.
+func (s *Server) closeSession(sess *session) {
+	sess.cancel()
+	s.pool.CloseConn(sess.conn.ConnectionID)
+	s.engine.CloseSession(sess.conn.ConnectionID)
+	s.sm.RemoveConn(sess.conn)
+}
+
+// session is the session of a single request.
+type session struct {
+	conn   *mysql.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// requestConn stands in for the network connection of a session.
+// The session manager only asks for its address, and closes it to kill the connection.
+type requestConn struct {
+	net.Conn
+	addr   net.Addr
+	cancel context.CancelFunc
+}
+
+func (c *requestConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *requestConn) Close() error {
+	c.cancel()
+	return nil
+}
+
+func auditLogin(user, addr string, sess *session, err error) {
+	record := audit.Record{
+		Class:    audit.ClassConnection,
+		Event:    audit.EventLogin,
+		Protocol: metrics.ProtocolHTTP,
+		User:     user,
+		Host:     addr,
+		Error:    audit.ErrorString(err),
+	}
+	if err != nil {
+		record.Event = audit.EventLoginFailed
+	} else {
+		record.ConnectionID = sess.conn.ConnectionID
+	}
+	audit.Log(record)
+}
+
+func (s *Server) auditStatement(sess *session, query string, start time.Time, rows int64, err error) {
+	audit.Log(audit.Record{
+		Time:         start,
+		Class:        audit.ClassStatement,
+		Event:        audit.EventQuery,
+		ConnectionID: sess.conn.ConnectionID,
+		Protocol:     metrics.ProtocolHTTP,
+		User:         sess.conn.User,
+		Host:         sess.conn.RemoteAddr().String(),
+		Schema:       s.sm.GetCurrentDB(sess.conn),
+		Statement:    query,
+		DurationUs:   time.Since(start).Microseconds(),
+		Rows:         rows,
+		Error:        audit.ErrorString(err),
+	})
+}
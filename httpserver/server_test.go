@@ -0,0 +1,167 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/apecloud/myduckserver/testutil"
+)
+
+// startTestServer starts the HTTP query interface on the server of testutil.NewServer.
+func startTestServer(t *testing.T) string {
+	srv := testutil.NewServer(t)
+	s, err := NewServer("127.0.0.1:0", srv.Server, srv.Pool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(func() { s.Close(context.Background()) })
+	return "http://" + s.listener.Addr().String() + "/"
+}
+
+func post(t *testing.T, url, user, query string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if user != "" {
+		req.SetBasicAuth(user, "")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestFormats(t *testing.T) {
+	url := startTestServer(t)
+	query := "SELECT id, name FROM t ORDER BY id"
+
+	resp, body := post(t, url+"?dialect=duckdb&database=db1", "root", query, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s: %s", resp.Status, body)
+	}
+	var result struct {
+		Meta []struct{ Name, Type string }
+		Data []map[string]any
+		Rows int
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Meta) != 2 || result.Meta[1].Name != "name" || result.Rows != 3 || result.Data[1]["name"] != "b" || result.Data[2]["name"] != nil {
+		t.Errorf("unexpected JSON result: %s", body)
+	}
+
+	resp, body = post(t, url+"?dialect=duckdb&database=db1&format=csv", "root", query, nil)
+	if resp.Header.Get("Content-Type") != "text/csv" || string(body) != "id,name\n1,a\n2,b\n3,\n" {
+		t.Errorf("unexpected CSV result: %q", body)
+	}
+
+	header := http.Header{"Accept": {"application/vnd.apache.arrow.stream"}}
+	resp, body = post(t, url+"?dialect=duckdb&database=db1", "root", query, header)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s: %s", resp.Status, body)
+	}
+	rdr, err := ipc.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdr.Release()
+	var rows int64
+	for rdr.Next() {
+		rows += rdr.Record().NumRows()
+	}
+	if rows != 3 || rdr.Schema().Field(0).Name != "id" {
+		t.Errorf("unexpected Arrow result: %d rows of %v", rows, rdr.Schema())
+	}
+
+	resp, body = post(t, url+"?dialect=duckdb&format=parquet", "root", "SELECT * FROM db1.t WHERE id > 1", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s: %s", resp.Status, body)
+	}
+	pf, err := file.NewParquetReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tbl.Release()
+	if tbl.NumRows() != 2 || tbl.Column(1).Data().Chunk(0).(*array.String).Value(0) != "b" {
+		t.Errorf("unexpected Parquet result: %v", tbl)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	url := startTestServer(t)
+
+	resp, _ := post(t, url+"?dialect=duckdb", "", "SELECT 1", nil)
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("expected a request without credentials to be rejected, got %s", resp.Status)
+	}
+	if resp, _ := post(t, url+"?dialect=duckdb", "stranger", "SELECT 1", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an unknown user to be rejected, got %s", resp.Status)
+	}
+	if resp, body := post(t, url+"?dialect=duckdb", "nobody", "SELECT * FROM db1.t", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the query to be denied, got %s: %s", resp.Status, body)
+	}
+	if resp, _ := post(t, url+"?dialect=duckdb", "root", "SELECT * FROM db1.missing", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request for an unknown table, got %s", resp.Status)
+	}
+	if resp, _ := post(t, url+"?dialect=sqlite", "root", "SELECT 1", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a bad request for an unknown dialect, got %s", resp.Status)
+	}
+	header := http.Header{"Accept": {"text/html"}}
+	if resp, _ := post(t, url+"?dialect=duckdb", "root", "SELECT 1", header); resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("expected an unsupported media type to be rejected, got %s", resp.Status)
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		param, accept string
+		want          string // empty for an error
+	}{
+		{"", "", "json"},
+		{"", "*/*", "json"},
+		{"CSV", "application/json", "csv"},
+		{"", "text/html, text/csv;q=0.9", "csv"},
+		{"", "application/vnd.apache.parquet", "parquet"},
+		{"", "text/html", ""},
+		{"xml", "", ""},
+	}
+	for _, tt := range tests {
+		f, err := negotiateFormat(tt.param, tt.accept)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("negotiateFormat(%q, %q) = %s, want an error", tt.param, tt.accept, f.name)
+		case tt.want != "" && (err != nil || f.name != tt.want):
+			t.Errorf("negotiateFormat(%q, %q) = %v, %v, want %s", tt.param, tt.accept, f, err, tt.want)
+		}
+	}
+}
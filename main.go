@@ -18,14 +18,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 
+	"github.com/apecloud/myduckserver/admin"
 	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/binlogreplication"
 	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/configuration"
+	"github.com/apecloud/myduckserver/flightserver"
+	"github.com/apecloud/myduckserver/httpserver"
+	"github.com/apecloud/myduckserver/metrics"
 	"github.com/apecloud/myduckserver/myfunc"
 	"github.com/apecloud/myduckserver/pgserver"
 	"github.com/apecloud/myduckserver/plugin"
 	"github.com/apecloud/myduckserver/replica"
-	"github.com/apecloud/myduckserver/transpiler"
 	sqle "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/server"
 	"github.com/dolthub/go-mysql-server/sql"
@@ -42,63 +48,109 @@ import (
 // The included MySQL client is used in this example, however any MySQL-compatible client will work.
 
 var (
-	address       = "0.0.0.0"
-	port          = 3306
-	socket        string
-	postgresPort  = 5432
-	dataDirectory = "."
-	dbFileName    = "mysql.db"
-	logLevel      = int(logrus.InfoLevel)
-
-	replicaOptions replica.ReplicaOptions
+	cfg        = configuration.Default()
+	configFile string
+	dbFileName = "mysql.db"
 )
 
 func init() {
-	flag.StringVar(&address, "address", address, "The address to bind to.")
-	flag.IntVar(&port, "port", port, "The port to bind to.")
-	flag.StringVar(&socket, "socket", socket, "The Unix domain socket to bind to.")
-	flag.StringVar(&dataDirectory, "datadir", dataDirectory, "The directory to store the database.")
-	flag.IntVar(&logLevel, "loglevel", logLevel, "The log level to use.")
+	flag.StringVar(&configFile, "config", configFile, "The YAML or TOML configuration file to load. Flags that are explicitly set take precedence over it.")
+	registerConfigFlags(flag.CommandLine, &cfg)
+}
+
+// registerConfigFlags defines on |fs| the flags that set the fields of |c|.
+func registerConfigFlags(fs *flag.FlagSet, c *configuration.Config) {
+	fs.StringVar(&c.Server.Address, "address", c.Server.Address, "The address to bind to.")
+	fs.IntVar(&c.Server.Port, "port", c.Server.Port, "The port to bind to.")
+	fs.StringVar(&c.Server.Socket, "socket", c.Server.Socket, "The Unix domain socket to bind to.")
+	fs.StringVar(&c.Server.DataDir, "datadir", c.Server.DataDir, "The directory to store the database.")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "The maximum time to wait for a graceful shutdown on SIGTERM or SIGINT. The queries still running are canceled when it expires.")
+	fs.DurationVar(&c.Server.MaxExecutionTime, "max-execution-time", c.Server.MaxExecutionTime, "The default time limit of SELECT statements on the MySQL port, which sessions can change with max_execution_time. Disabled if 0.")
+	fs.BoolVar(&c.Server.ReadOnly, "read-only", c.Server.ReadOnly, "Reject writes from clients on both the MySQL and PostgreSQL ports, as with SET GLOBAL super_read_only = ON. Replication keeps applying changes.")
+	fs.Var(&c.Log.Level, "loglevel", "The log level to use, either a number or a name such as \"debug\".")
+
+	fs.IntVar(&c.Postgres.Port, "pg-port", c.Postgres.Port, "The port to bind to for PostgreSQL wire protocol.")
+	fs.DurationVar(&c.Postgres.StatementTimeout, "pg-statement-timeout", c.Postgres.StatementTimeout, "The default time limit of all statements on the PostgreSQL port, which sessions can change with statement_timeout. Disabled if 0.")
+	fs.IntVar(&c.FlightSQL.Port, "flight-sql-port", c.FlightSQL.Port, "The port to bind to for Arrow Flight SQL. Disabled if 0.")
+	fs.StringVar(&c.HTTP.Address, "http-address", c.HTTP.Address, "The address to serve the HTTP query interface on, e.g., \"0.0.0.0:8123\". Disabled if empty.")
 
-	flag.IntVar(&postgresPort, "pg-port", postgresPort, "The port to bind to for PostgreSQL wire protocol.")
+	fs.StringVar(&c.TLS.Cert, "tls-cert", c.TLS.Cert, "The PEM certificate file for TLS connections on both the MySQL and PostgreSQL ports.")
+	fs.StringVar(&c.TLS.Key, "tls-key", c.TLS.Key, "The PEM private key file for TLS connections.")
+	fs.StringVar(&c.TLS.CA, "tls-ca", c.TLS.CA, "The PEM CA bundle used to verify client certificates.")
+	fs.BoolVar(&c.TLS.Require, "require-secure-transport", c.TLS.Require, "Reject connections that do not use TLS.")
+	fs.BoolVar(&c.TLS.VerifyClientCert, "tls-verify-client-cert", c.TLS.VerifyClientCert, "Require clients to present a certificate signed by the CA given by -tls-ca.")
+
+	fs.StringVar(&c.Bootstrap.RootPassword, "root-password", c.Bootstrap.RootPassword, "The password of the root account created when the data directory is initialized. Prefer the "+configuration.EnvRootPassword+" environment variable or the config file.")
+	fs.StringVar(&c.Bootstrap.RootHost, "root-host", c.Bootstrap.RootHost, "The host of the root account created when the data directory is initialized, e.g., \"localhost\" to refuse remote logins.")
+	fs.Var(&c.Bootstrap.Users, "bootstrap-users", "Additional accounts with all privileges created when the data directory is initialized, as comma-separated \"name[@host]:password\" items.")
+
+	fs.StringVar(&c.Admin.Address, "admin-address", c.Admin.Address, "The address to serve the HTTP admin API on, e.g., \"127.0.0.1:7070\". Disabled if empty.")
+	fs.StringVar(&c.Admin.Token, "admin-token", c.Admin.Token, "The bearer token required by the HTTP admin API. Prefer setting it in the config file.")
+	fs.BoolVar(&c.Audit.Enabled, "audit", c.Audit.Enabled, "Record logins, statements and account changes in the audit log.")
+	fs.StringVar(&c.Audit.Dir, "audit-dir", c.Audit.Dir, "The directory of the audit log files, relative to the data directory if not absolute.")
+	fs.IntVar(&c.Transpiler.CacheSize, "translation-cache-size", c.Transpiler.CacheSize, "The number of MySQL-to-DuckDB translations to cache. Disabled if 0.")
+	fs.BoolVar(&c.Transpiler.CacheNormalize, "translation-cache-normalize", c.Transpiler.CacheNormalize, "Share a cached translation among the queries that differ only in their literals.")
+	fs.IntVar(&c.Transpiler.Workers, "translation-workers", c.Transpiler.Workers, "The maximum number of sqlglot processes translating MySQL queries concurrently. Defaults to the number of CPUs if 0.")
+	fs.DurationVar(&c.Transpiler.Timeout, "translation-timeout", c.Transpiler.Timeout, "The maximum time to translate a MySQL query, including the wait for an idle sqlglot process. Disabled if 0.")
+	fs.BoolVar(&c.Transpiler.Native, "native-translation", c.Transpiler.Native, "Translate the common MySQL queries in Go, leaving the others to sqlglot.")
+	fs.BoolVar(&c.Transpiler.NoPython, "no-python", c.Transpiler.NoPython, "Run without Python: MySQL queries that the native translator does not support fail instead of being translated by sqlglot.")
+	fs.StringVar(&c.Metrics.Address, "metrics-address", c.Metrics.Address, "The address to serve Prometheus metrics on, e.g., \":9090\". Disabled if empty.")
 
 	// The following options need to be set for MySQL Shell's utilities to work properly.
 
 	// https://dev.mysql.com/doc/refman/8.4/en/replication-options-replica.html#sysvar_report_host
-	flag.StringVar(&replicaOptions.ReportHost, "report-host", replicaOptions.ReportHost, "The host name or IP address of the replica to be reported to the source during replica registration.")
+	fs.StringVar(&c.Replication.ReportHost, "report-host", c.Replication.ReportHost, "The host name or IP address of the replica to be reported to the source during replica registration.")
 	// https://dev.mysql.com/doc/refman/8.4/en/replication-options-replica.html#sysvar_report_port
-	flag.IntVar(&replicaOptions.ReportPort, "report-port", replicaOptions.ReportPort, "The TCP/IP port number for connecting to the replica, to be reported to the source during replica registration.")
+	fs.IntVar(&c.Replication.ReportPort, "report-port", c.Replication.ReportPort, "The TCP/IP port number for connecting to the replica, to be reported to the source during replica registration.")
 	// https://dev.mysql.com/doc/refman/8.4/en/replication-options-replica.html#sysvar_report_user
-	flag.StringVar(&replicaOptions.ReportUser, "report-user", replicaOptions.ReportUser, "The account user name of the replica to be reported to the source during replica registration.")
+	fs.StringVar(&c.Replication.ReportUser, "report-user", c.Replication.ReportUser, "The account user name of the replica to be reported to the source during replica registration.")
 	// https://dev.mysql.com/doc/refman/8.4/en/replication-options-replica.html#sysvar_report_password
-	flag.StringVar(&replicaOptions.ReportPassword, "report-password", replicaOptions.ReportPassword, "The account password of the replica to be reported to the source during replica registration.")
+	fs.StringVar(&c.Replication.ReportPassword, "report-password", c.Replication.ReportPassword, "The account password of the replica to be reported to the source during replica registration.")
 }
 
 func ensureSQLTranslate() {
-	_, err := transpiler.TranslateWithSQLGlot("SELECT 1")
-	if err != nil {
-		panic(err)
+	if err := checkTranslation(cfg.Transpiler); err != nil {
+		logrus.Fatalln("Cannot translate MySQL queries:", err)
+	}
+	if cfg.Transpiler.NoPython {
+		logrus.Infoln("Running without Python; queries that the native translator does not support will fail")
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == restoreCommand {
+		runRestore(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
-	if replicaOptions.ReportPort == 0 {
-		replicaOptions.ReportPort = port
+	if err := loadConfig(); err != nil {
+		logrus.Fatalln("Failed to load the configuration:", err)
+	}
+
+	if cfg.Replication.ReportPort == 0 {
+		cfg.Replication.ReportPort = cfg.Server.Port
 	}
 
-	logrus.SetLevel(logrus.Level(logLevel))
+	applyReloadableConfig()
 
 	ensureSQLTranslate()
 
-	provider, err := catalog.NewDBProvider(dataDirectory, dbFileName)
+	provider, err := catalog.NewDBProvider(cfg.Server.DataDir, dbFileName)
 	if err != nil {
 		logrus.Fatalln("Failed to open the database:", err)
 	}
-	defer provider.Close()
+
+	if err := applyDuckDBConfig(provider.Storage()); err != nil {
+		logrus.Fatalln("Failed to configure DuckDB:", err)
+	}
+	if err := catalog.RegisterDuckDBOptions(provider.Storage()); err != nil {
+		logrus.Fatalln("Failed to register the DuckDB options:", err)
+	}
 
 	pool := backend.NewConnectionPool(provider.CatalogName(), provider.Connector(), provider.Storage())
+	metrics.RegisterOpenConnections(pool.NumConns)
 
 	engine := sqle.NewDefault(provider)
 
@@ -106,33 +158,118 @@ func main() {
 	engine.Analyzer.ExecBuilder = builder
 	engine.Analyzer.Catalog.RegisterFunction(sql.NewContext(context.Background()), myfunc.ExtraBuiltIns...)
 	engine.Analyzer.Catalog.MySQLDb.SetPlugins(plugin.AuthPlugins)
+	plugin.RegisterAuthServer()
 
 	if err := setPersister(provider, engine); err != nil {
 		logrus.Fatalln("Failed to set the persister:", err)
 	}
 
+	if cfg.Audit.Enabled {
+		if err := openAuditLog(provider.Storage()); err != nil {
+			logrus.Fatalln("Failed to open the audit log:", err)
+		}
+	}
+
+	if err := pgserver.RegisterStatActivity(pool, engine.ProcessList); err != nil {
+		logrus.Fatalln("Failed to register pg_stat_activity:", err)
+	}
+	infoSchema, err := backend.NewInformationSchema(engine.Analyzer.Catalog.InfoSchema, pool)
+	if err != nil {
+		logrus.Fatalln("Failed to extend information_schema:", err)
+	}
+	engine.Analyzer.Catalog.InfoSchema = infoSchema
+
+	backend.RegisterMaxExecutionTime(cfg.Server.MaxExecutionTime)
+	pgserver.RegisterStatementTimeout(cfg.Postgres.StatementTimeout)
+	backend.RegisterReplicaReadConsistency()
+
+	if cfg.Server.ReadOnly {
+		if err := backend.SetReadOnly(true); err != nil {
+			logrus.Fatalln("Failed to enable the read-only mode:", err)
+		}
+	}
+
+	replicaOptions := replica.ReplicaOptions{
+		ReportHost:     cfg.Replication.ReportHost,
+		ReportPort:     cfg.Replication.ReportPort,
+		ReportUser:     cfg.Replication.ReportUser,
+		ReportPassword: cfg.Replication.ReportPassword,
+	}
 	replica.RegisterReplicaOptions(&replicaOptions)
 	replica.RegisterReplicaController(provider, engine, pool, builder)
 
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		logrus.Fatalln("Failed to load the TLS configuration:", err)
+	}
+
 	config := server.Config{
-		Protocol: "tcp",
-		Address:  fmt.Sprintf("%s:%d", address, port),
-		Socket:   socket,
+		Protocol:               "tcp",
+		Address:                fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.Server.Port),
+		Socket:                 cfg.Server.Socket,
+		TLSConfig:              tlsConfig,
+		RequireSecureTransport: cfg.TLS.Require,
 	}
-	srv, err := server.NewServerWithHandler(config, engine, backend.NewSessionBuilder(provider, pool), nil, backend.WrapHandler(pool))
+	srv, err := server.NewServerWithHandler(config, engine, backend.NewSessionBuilder(provider, pool), nil, backend.WrapHandler(pool, engine))
 	if err != nil {
 		panic(err)
 	}
 
-	if postgresPort > 0 {
-		pgServer, err := pgserver.NewServer(srv, address, postgresPort)
+	var pgServer *pgserver.Server
+	if cfg.Postgres.Port > 0 {
+		pgServer, err = pgserver.NewServer(
+			srv, cfg.Server.Address, cfg.Postgres.Port,
+			pgserver.WithTLSConfig(tlsConfig),
+			pgserver.WithRequireSecureTransport(cfg.TLS.Require),
+		)
 		if err != nil {
 			panic(err)
 		}
 		go pgServer.Start()
 	}
 
-	if err = srv.Start(); err != nil {
-		panic(err)
+	var httpServers []httpServer
+	if cfg.FlightSQL.Port > 0 {
+		flightServer, err := flightserver.NewServer(fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.FlightSQL.Port), srv, provider, pool, tlsConfig)
+		if err != nil {
+			logrus.Fatalln("Failed to start the Flight SQL server:", err)
+		}
+		go flightServer.Start()
+		httpServers = append(httpServers, flightServer)
+	}
+	if cfg.HTTP.Address != "" {
+		queryServer, err := httpserver.NewServer(cfg.HTTP.Address, srv, pool, tlsConfig)
+		if err != nil {
+			logrus.Fatalln("Failed to start the HTTP query interface:", err)
+		}
+		go queryServer.Start()
+		httpServers = append(httpServers, queryServer)
 	}
+	if cfg.Metrics.Address != "" {
+		metricsServer, err := metrics.NewServer(cfg.Metrics.Address)
+		if err != nil {
+			logrus.Fatalln("Failed to start the metrics server:", err)
+		}
+		go metricsServer.Start()
+		httpServers = append(httpServers, metricsServer)
+	}
+	if cfg.Admin.Address != "" {
+		adminServer, err := admin.NewServer(cfg.Admin.Address, cfg.Admin.Token, provider, pool, binlogreplication.MyBinlogReplicaController)
+		if err != nil {
+			logrus.Fatalln("Failed to start the admin API:", err)
+		}
+		go adminServer.Start()
+		httpServers = append(httpServers, adminServer)
+	}
+
+	go handleReloadSignal()
+
+	go func() {
+		if err := srv.Start(); err != nil {
+			panic(err)
+		}
+	}()
+
+	waitForTermination()
+	shutdown(srv, pgServer, httpServers, provider, pool)
 }
@@ -0,0 +1,274 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the Prometheus metrics exported by the server.
+// It must not import other packages of this module, so that any of them can report metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "myduck"
+
+// Wire protocols used as the "protocol" label.
+const (
+	ProtocolMySQL     = "mysql"
+	ProtocolPostgres  = "postgres"
+	ProtocolFlightSQL = "flightsql"
+	ProtocolHTTP      = "http"
+)
+
+var (
+	queries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queries_total",
+		Help:      "Number of queries received, by protocol.",
+	}, []string{"protocol"})
+
+	queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "query_errors_total",
+		Help:      "Number of queries that failed, by protocol.",
+	}, []string{"protocol"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Query latency, by protocol.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10), // 0.5ms ~ 131s
+	}, []string{"protocol"})
+
+	deltaFlushes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delta",
+		Name:      "flushes_total",
+		Help:      "Number of non-empty delta buffer flushes, by flush reason.",
+	}, []string{"reason"})
+
+	deltaFlushedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delta",
+		Name:      "flushed_bytes_total",
+		Help:      "Size of the delta buffers flushed, by flush reason.",
+	}, []string{"reason"})
+
+	deltaFlushedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "delta",
+		Name:      "flushed_rows_total",
+		Help:      "Number of rows inserted or deleted by delta buffer flushes, by flush reason.",
+	}, []string{"reason", "op"})
+
+	deltaFlushDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "delta",
+		Name:      "flush_duration_seconds",
+		Help:      "Latency of non-empty delta buffer flushes, by flush reason.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8), // 1ms ~ 16s
+	}, []string{"reason"})
+
+	replicationLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "lag_seconds",
+		Help:      "Seconds between the source timestamp of the last committed binlog event and its commit on this replica.",
+	})
+
+	replicationAppliedGTIDs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "replication",
+		Name:      "applied_gtids",
+		Help:      "Number of GTIDs in the executed GTID set of this replica.",
+	})
+
+	translations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "translations_total",
+		Help:      "Number of queries translated by sqlglot.",
+	})
+
+	translationErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "translation_errors_total",
+		Help:      "Number of queries that sqlglot failed to translate.",
+	})
+
+	translationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "translation_duration_seconds",
+		Help:      "Latency of sqlglot translations.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8), // 0.1ms ~ 1.6s
+	})
+
+	nativeTranslations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transpiler",
+		Name:      "native_translations_total",
+		Help:      "Number of queries that the native translator translated (ok) or left to sqlglot (fallback).",
+	}, []string{"result"})
+
+	translationCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "cache_lookups_total",
+		Help:      "Number of translation cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+
+	translationWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "workers",
+		Help:      "Number of sqlglot processes.",
+	})
+
+	translationBusyWorkers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "busy_workers",
+		Help:      "Number of sqlglot processes that are translating a query or starting.",
+	})
+
+	translationWaitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "wait_duration_seconds",
+		Help:      "Time spent waiting for an idle sqlglot process.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8), // 0.1ms ~ 1.6s
+	})
+
+	translationTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "timeouts_total",
+		Help:      "Number of translations that timed out.",
+	})
+
+	translationWorkerExits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "worker_exits_total",
+		Help:      "Number of sqlglot processes that failed, by reason (crash or timeout).",
+	}, []string{"reason"})
+
+	translationWorkerRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sqlglot",
+		Name:      "restarts_total",
+		Help:      "Number of attempts to restart a failed sqlglot process, by result (ok or error).",
+	}, []string{"result"})
+)
+
+// ObserveQuery records a query received on |protocol| that started at |start| and finished with |err|.
+func ObserveQuery(protocol string, start time.Time, err error) {
+	queries.WithLabelValues(protocol).Inc()
+	queryDuration.WithLabelValues(protocol).Observe(time.Since(start).Seconds())
+	if err != nil {
+		queryErrors.WithLabelValues(protocol).Inc()
+	}
+}
+
+// ObserveDeltaFlush records a non-empty delta buffer flush.
+func ObserveDeltaFlush(reason string, start time.Time, size, insertions, deletions int64) {
+	deltaFlushes.WithLabelValues(reason).Inc()
+	deltaFlushedBytes.WithLabelValues(reason).Add(float64(size))
+	deltaFlushedRows.WithLabelValues(reason, "insert").Add(float64(insertions))
+	deltaFlushedRows.WithLabelValues(reason, "delete").Add(float64(deletions))
+	deltaFlushDuration.WithLabelValues(reason).Observe(time.Since(start).Seconds())
+}
+
+// SetReplicationLag records the replication lag.
+func SetReplicationLag(lag time.Duration) {
+	replicationLag.Set(max(lag.Seconds(), 0))
+}
+
+// SetReplicationAppliedGTIDs records the size of the executed GTID set.
+func SetReplicationAppliedGTIDs(n int64) {
+	replicationAppliedGTIDs.Set(float64(n))
+}
+
+// ObserveTranslation records a sqlglot translation that started at |start| and finished with |err|.
+func ObserveTranslation(start time.Time, err error) {
+	translations.Inc()
+	translationDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		translationErrors.Inc()
+	}
+}
+
+// ObserveNativeTranslation records a query that the native translator translated, or left to sqlglot if |err| is set.
+func ObserveNativeTranslation(err error) {
+	if err != nil {
+		nativeTranslations.WithLabelValues("fallback").Inc()
+	} else {
+		nativeTranslations.WithLabelValues("ok").Inc()
+	}
+}
+
+// ObserveTranslationCache records a lookup in the translation cache.
+func ObserveTranslationCache(hit bool) {
+	if hit {
+		translationCacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		translationCacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// SetTranslationWorkers records the number of sqlglot processes and of those that are busy.
+func SetTranslationWorkers(started, busy int) {
+	translationWorkers.Set(float64(started))
+	translationBusyWorkers.Set(float64(busy))
+}
+
+// ObserveTranslationWait records the wait for a sqlglot process that started at |start|.
+func ObserveTranslationWait(start time.Time) {
+	translationWaitDuration.Observe(time.Since(start).Seconds())
+}
+
+// ObserveTranslationTimeout records a translation that timed out.
+func ObserveTranslationTimeout() {
+	translationTimeouts.Inc()
+}
+
+// ObserveTranslationWorkerExit records a sqlglot process that crashed or was killed after a timeout.
+func ObserveTranslationWorkerExit(reason string) {
+	translationWorkerExits.WithLabelValues(reason).Inc()
+}
+
+// ObserveTranslationWorkerRestart records an attempt to restart a sqlglot process that finished with |err|.
+func ObserveTranslationWorkerRestart(err error) {
+	if err != nil {
+		translationWorkerRestarts.WithLabelValues("error").Inc()
+	} else {
+		translationWorkerRestarts.WithLabelValues("ok").Inc()
+	}
+}
+
+// RegisterOpenConnections reports the number of open backend connections returned by |count|.
+// It must be called at most once.
+func RegisterOpenConnections(count func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_connections",
+		Help:      "Number of DuckDB connections held by client sessions in the connection pool.",
+	}, func() float64 {
+		return float64(count())
+	})
+}
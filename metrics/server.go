@@ -0,0 +1,57 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Server serves the metrics over HTTP at /metrics.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// NewServer listens on |addr| for metrics scrapes.
+func NewServer(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &Server{
+		srv:      &http.Server{Handler: mux},
+		listener: l,
+	}, nil
+}
+
+// Start serves the metrics until the server is closed.
+func (s *Server) Start() {
+	logrus.Infof("Metrics server listening on %s", s.listener.Addr())
+	if err := s.srv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorln("Metrics server stopped:", err)
+	}
+}
+
+// Close stops the server, waiting for in-flight scrapes until |ctx| is done.
+func (s *Server) Close(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
@@ -15,31 +15,67 @@ package main
 
 import (
 	"context"
+	stdsql "database/sql"
+	"errors"
 	"os"
-	"path"
+	"path/filepath"
 
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/configuration"
+	"github.com/apecloud/myduckserver/plugin"
 	sqle "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/memory"
 	"github.com/dolthub/go-mysql-server/sql"
 	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/sirupsen/logrus"
 )
 
-const persistFile = "mysql.bin"
+// legacyPersistFile is where the "mysql" database was stored before it was moved into DuckDB.
+// It is migrated on the first start and then renamed with the migratedSuffix.
+const (
+	legacyPersistFile = "mysql.bin"
+	migratedSuffix    = ".migrated"
+)
+
+// mysqlDbKey is the key of the row of catalog.InternalTables.MySQLDb that holds the "mysql" database.
+const mysqlDbKey = "mysql"
 
-// MySQLPersister is an example struct which handles the persistence of the data in the "mysql" database.
+// MySQLPersister stores the "mysql" database, i.e., the accounts, the grants and the replication
+// source configuration, in an internal DuckDB table, so that it is as durable as the data.
 type MySQLPersister struct {
-	FilePath string
+	storage *stdsql.DB
 }
 
 var _ mysql_db.MySQLDbPersistence = (*MySQLPersister)(nil)
 
 // Persist implements the interface mysql_db.MySQLDbPersistence.
+// The data is written in the DuckDB transaction of the session, if one is open,
+// so that it is committed or rolled back along with the changes made in that transaction.
 func (m *MySQLPersister) Persist(ctx *sql.Context, data []byte) error {
-	return os.WriteFile(m.FilePath, data, 0644)
+	stmt := catalog.InternalTables.MySQLDb.UpsertStmt()
+	if _, ok := ctx.Session.(adapter.ConnectionHolder); !ok {
+		// The server is starting up and there is no client session.
+		_, err := m.storage.ExecContext(ctx, stmt, mysqlDbKey, data)
+		return err
+	}
+	if tx := adapter.TryGetTxn(ctx); tx != nil {
+		_, err := tx.ExecContext(ctx, stmt, mysqlDbKey, data)
+		return err
+	}
+	_, err := adapter.ExecCatalog(ctx, stmt, mysqlDbKey, data)
+	return err
+}
+
+// load returns the stored "mysql" database, or stdsql.ErrNoRows if it has never been written.
+func (m *MySQLPersister) load(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := m.storage.QueryRowContext(ctx, catalog.InternalTables.MySQLDb.SelectStmt(), mysqlDbKey).Scan(&data)
+	return data, err
 }
 
 // https://github.com/dolthub/go-mysql-server/blob/main/_example/users_example.go
-func setPersister(provider sql.DatabaseProvider, engine *sqle.Engine) error {
+func setPersister(provider *catalog.DatabaseProvider, engine *sqle.Engine) error {
 	session := memory.NewSession(sql.NewBaseSession(), provider)
 	ctx := sql.NewContext(context.Background(), sql.WithSession(session))
 	ctx.SetCurrentDatabase("mysql")
@@ -50,33 +86,96 @@ func setPersister(provider sql.DatabaseProvider, engine *sqle.Engine) error {
 	// to explicitly show how one can manually enable (or disable) the database.
 	mysqlDb.SetEnabled(true)
 
-	// The persister here simply stands-in for your provided persistence function. The database calls this whenever it
-	// needs to save any changes to any of the "mysql" database's tables. The memory session persists in memory,
-	// but can be swapped out with the lines below
-	persister := &MySQLPersister{FilePath: path.Join(dataDirectory, persistFile)}
+	// The database calls the persister whenever it needs to save any changes to any of the "mysql" database's tables.
+	persister := &MySQLPersister{storage: provider.Storage()}
 	mysqlDb.SetPersister(persister)
 
-	if _, err := os.Stat(persister.FilePath); err == nil {
-		data, err := os.ReadFile(persister.FilePath)
-		if err != nil {
-			return err
-		}
-
-		if err := mysqlDb.LoadData(ctx, data); err != nil {
-			return err
-		}
+	data, err := persister.load(ctx)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return migrateLegacyFile(ctx, mysqlDb, filepath.Join(provider.DataDir(), legacyPersistFile))
+	} else if err != nil {
+		return err
 	}
+	return mysqlDb.LoadData(ctx, data)
+}
 
-	addAccount := func(account string, address string) {
+// migrateLegacyFile moves the "mysql" database from the file at |path| into DuckDB.
+// If there is no such file either, this is the first start on the data directory.
+func migrateLegacyFile(ctx *sql.Context, mysqlDb *mysql_db.MySQLDb, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The grant tables have never been written.
+		return bootstrapAccounts(ctx, mysqlDb, cfg.Bootstrap)
+	} else if err != nil {
+		return err
+	}
+	if err := mysqlDb.LoadData(ctx, data); err != nil {
+		return err
+	}
+	// The file is only renamed once its content has been stored, so the migration is retried after a crash.
+	if hasAdminAccount(mysqlDb) {
 		ed := mysqlDb.Editor()
-		defer ed.Close()
-		mysqlDb.AddSuperUser(ed, account, address, "")
+		err = mysqlDb.Persist(ctx, ed)
+		ed.Close()
+	} else {
+		// The root account used to be created in memory on every start and was never written to the file.
+		err = bootstrapAccounts(ctx, mysqlDb, configuration.BootstrapConfig{RootHost: cfg.Bootstrap.RootHost, RootPassword: cfg.Bootstrap.RootPassword})
 	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(path, path+migratedSuffix); err != nil {
+		return err
+	}
+	logrus.Infof("Migrated the grant tables from %s into the database", path)
+	return nil
+}
 
-	// Modify it to "%" to allow accepting connections outside when myduckserver runs in Docker
-	// TODO should add a config to decide this or some better way to support this
-	// addAccount("root", "localhost")
-	addAccount("root", "%")
+// hasAdminAccount reports whether an account of |mysqlDb| has all the global privileges.
+func hasAdminAccount(mysqlDb *mysql_db.MySQLDb) bool {
+	all := mysql_db.NewPrivilegeSetWithAllPrivileges().ToSlice()
+	rd := mysqlDb.Reader()
+	defer rd.Close()
+	found := false
+	rd.VisitUsers(func(u *mysql_db.User) {
+		if !u.IsRole && u.PrivilegeSet.Has(all...) {
+			found = true
+		}
+	})
+	return found
+}
 
-	return nil
+// bootstrapAccounts creates the root account and the additional accounts of |bc|, all with every privilege,
+// and persists them right away so that later starts load them instead.
+func bootstrapAccounts(ctx *sql.Context, mysqlDb *mysql_db.MySQLDb, bc configuration.BootstrapConfig) error {
+	ed := mysqlDb.Editor()
+	defer ed.Close()
+
+	users := append([]configuration.BootstrapUser{{Name: "root", Host: bc.RootHost, Password: bc.RootPassword}}, bc.Users...)
+	for _, u := range users {
+		if u.Host == "" {
+			u.Host = "%"
+		}
+		mysqlDb.AddSuperUser(ed, u.Name, u.Host, u.Password)
+		key := mysql_db.UserPrimaryKey{Host: u.Host, User: u.Name}
+		existing, _ := ed.GetUser(key)
+		user := *existing
+		// Super users are not loaded from the persisted data, so the account is turned into
+		// a regular one that keeps all the privileges.
+		user.IsSuperUser = false
+		if u.Password != "" {
+			// The verifier lets the account log in over the PostgreSQL protocol.
+			v, err := plugin.NewScramSha256Verifier(u.Password)
+			if err != nil {
+				return err
+			}
+			if err := plugin.SetScramSha256Verifier(&user, v); err != nil {
+				return err
+			}
+		}
+		ed.RemoveUser(key)
+		ed.PutUser(&user)
+		logrus.Infof("Created the account '%s'@'%s'", u.Name, u.Host)
+	}
+	return mysqlDb.Persist(ctx, ed)
 }
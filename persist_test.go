@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/myduckserver/catalog"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+)
+
+// openTestEngine opens a data directory and loads its grant tables.
+func openTestEngine(t *testing.T, dir string) (*catalog.DatabaseProvider, *mysql_db.MySQLDb) {
+	provider, err := catalog.NewDBProvider(dir, "mysql.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := sqle.NewDefault(provider)
+	if err := setPersister(provider, engine); err != nil {
+		provider.Close()
+		t.Fatal(err)
+	}
+	return provider, engine.Analyzer.Catalog.MySQLDb
+}
+
+func hasUser(db *mysql_db.MySQLDb, user, host string) bool {
+	rd := db.Reader()
+	defer rd.Close()
+	_, ok := rd.GetUser(mysql_db.UserPrimaryKey{Host: host, User: user})
+	return ok
+}
+
+func TestPersistGrantTables(t *testing.T) {
+	dir := t.TempDir()
+
+	provider, db := openTestEngine(t, dir)
+	if !hasUser(db, "root", "%") {
+		t.Error("the root account was not created")
+	}
+	ed := db.Editor()
+	ed.PutUser(newTestUser("app", "%"))
+	if err := db.Persist(sql.NewEmptyContext(), ed); err != nil {
+		t.Fatal(err)
+	}
+	ed.Close()
+	provider.Close()
+
+	provider, db = openTestEngine(t, dir)
+	defer provider.Close()
+	if !hasUser(db, "root", "%") || !hasUser(db, "app", "%") {
+		t.Error("the accounts were not loaded from the database")
+	}
+	if _, err := os.Stat(filepath.Join(dir, legacyPersistFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s, got %v", legacyPersistFile, err)
+	}
+}
+
+func TestMigrateLegacyFile(t *testing.T) {
+	// Write a legacy file with an account other than root.
+	legacy := mysql_db.CreateEmptyMySQLDb()
+	var data []byte
+	legacy.SetPersister(persisterFunc(func(_ *sql.Context, b []byte) error {
+		data = b
+		return nil
+	}))
+	ed := legacy.Editor()
+	ed.PutUser(newTestUser("legacy", "localhost"))
+	if err := legacy.Persist(sql.NewEmptyContext(), ed); err != nil {
+		t.Fatal(err)
+	}
+	ed.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, legacyPersistFile)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, db := openTestEngine(t, dir)
+	if !hasUser(db, "legacy", "localhost") {
+		t.Error("the accounts were not migrated")
+	}
+	// The legacy file has no account with all privileges, so the root account is created.
+	if !hasUser(db, "root", "%") {
+		t.Error("the root account was not created")
+	}
+	provider.Close()
+	if _, err := os.Stat(path + migratedSuffix); err != nil {
+		t.Errorf("the legacy file was not renamed: %v", err)
+	}
+
+	// The migrated accounts are loaded from the database from now on.
+	provider, db = openTestEngine(t, dir)
+	defer provider.Close()
+	if !hasUser(db, "legacy", "localhost") || !hasUser(db, "root", "%") {
+		t.Error("the migrated accounts were not loaded from the database")
+	}
+}
+
+func TestMigrateLegacyFileWithAdmin(t *testing.T) {
+	// Write a legacy file with an account that has all privileges.
+	legacy := mysql_db.CreateEmptyMySQLDb()
+	var data []byte
+	legacy.SetPersister(persisterFunc(func(_ *sql.Context, b []byte) error {
+		data = b
+		return nil
+	}))
+	admin := newTestUser("admin", "%")
+	admin.PrivilegeSet = mysql_db.NewPrivilegeSetWithAllPrivileges()
+	ed := legacy.Editor()
+	ed.PutUser(admin)
+	if err := legacy.Persist(sql.NewEmptyContext(), ed); err != nil {
+		t.Fatal(err)
+	}
+	ed.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, legacyPersistFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, db := openTestEngine(t, dir)
+	defer provider.Close()
+	if !hasUser(db, "admin", "%") || hasUser(db, "root", "%") {
+		t.Error("expected only the migrated administrator account")
+	}
+}
+
+func newTestUser(user, host string) *mysql_db.User {
+	return &mysql_db.User{
+		User:         user,
+		Host:         host,
+		PrivilegeSet: mysql_db.NewPrivilegeSet(),
+		Plugin:       "mysql_native_password",
+	}
+}
+
+type persisterFunc func(ctx *sql.Context, data []byte) error
+
+func (f persisterFunc) Persist(ctx *sql.Context, data []byte) error {
+	return f(ctx, data)
+}
@@ -0,0 +1,46 @@
+package pgserver
+
+import (
+	"time"
+
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/metrics"
+)
+
+// auditLogin records the outcome of a login on the PostgreSQL port.
+func (h *ConnectionHandler) auditLogin(err error) {
+	event := audit.EventLogin
+	if err != nil {
+		event = audit.EventLoginFailed
+	}
+	audit.Log(audit.Record{
+		Class:        audit.ClassConnection,
+		Event:        event,
+		ConnectionID: h.mysqlConn.ConnectionID,
+		Protocol:     metrics.ProtocolPostgres,
+		User:         h.mysqlConn.User,
+		Host:         h.Conn().RemoteAddr().String(),
+		Error:        audit.ErrorString(err),
+	})
+}
+
+// auditStatement records a statement received in a Query or Execute message.
+// The number of rows is taken from |statementRows|, which is reset here.
+func (h *ConnectionHandler) auditStatement(event string, query string, start time.Time, err error) {
+	rows := h.statementRows
+	h.statementRows = 0
+	audit.Log(audit.Record{
+		Time:         start,
+		Class:        audit.ClassStatement,
+		Event:        event,
+		ConnectionID: h.mysqlConn.ConnectionID,
+		Protocol:     metrics.ProtocolPostgres,
+		User:         h.mysqlConn.User,
+		Host:         h.Conn().RemoteAddr().String(),
+		Schema:       h.duckHandler.sm.GetCurrentDB(h.mysqlConn),
+		Statement:    query,
+		DurationUs:   time.Since(start).Microseconds(),
+		Rows:         int64(rows),
+		Error:        audit.ErrorString(err),
+	})
+}
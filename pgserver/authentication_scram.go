@@ -16,17 +16,18 @@ package pgserver
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"net"
-	"os"
 	"strings"
 
-	"github.com/dolthub/doltgresql/server/auth"
+	"github.com/apecloud/myduckserver/plugin"
 	"github.com/dolthub/doltgresql/server/auth/rfc5802"
 
 	"github.com/dolthub/go-mysql-server/sql"
 	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/sirupsen/logrus"
 )
 
 // SCRAM authentication is defined in RFC-5802:
@@ -38,29 +39,6 @@ const (
 	SASLMechanism_SCRAM_SHA_256_PLUS = "SCRAM-SHA-256-PLUS"
 )
 
-// EnableAuthentication handles whether authentication is enabled. If enabled, it verifies that the given user exists,
-// and checks that the encrypted password is derivable from the stored encrypted password. As the feature is still in
-// development, it is disabled by default. It may be enabled by supplying the environment variable
-// "DOLTGRES_ENABLE_AUTHENTICATION", or by simply setting this boolean to true.
-var EnableAuthentication = false
-
-func init() {
-	if _, ok := os.LookupEnv("DOLTGRES_ENABLE_AUTHENTICATION"); ok {
-		EnableAuthentication = true
-	}
-
-	auth.DropRole("doltgres")
-
-	var err error
-	mysql := auth.CreateDefaultRole("mysql")
-	mysql.CanLogin = true
-	mysql.Password, err = auth.NewScramSha256Password("")
-	if err != nil {
-		panic(err)
-	}
-	auth.SetRole(mysql)
-}
-
 // SASLBindingFlag are the flags for gs2-cbind-flag, used in SASL authentication.
 type SASLBindingFlag string
 
@@ -96,33 +74,64 @@ type SASLResponse struct {
 	RawData     []byte // The bytes that were received in the message
 }
 
-// handleAuthentication handles authentication for the given user
+// handleAuthentication authenticates the user of the startup message against the MySQL accounts,
+// so that both protocols share the same users and grants.
+//
+// An account without a password logs in without one, as on the MySQL port. Otherwise the password is
+// verified with SCRAM-SHA-256 against the verifier derived when the password was set by CREATE USER
+// or ALTER USER.
 func (h *ConnectionHandler) handleAuthentication(startupMessage *pgproto3.StartupMessage) error {
-	var username string
+	username := startupMessage.Parameters["user"]
+	if len(username) == 0 {
+		_ = h.send(&pgproto3.ErrorResponse{
+			Severity: "FATAL",
+			Code:     "28000", // invalid_authorization_specification
+			Message:  "no PostgreSQL user name specified in startup packet",
+		})
+		return fmt.Errorf("no user name specified in startup packet")
+	}
 	var host string
-	var ok bool
-	if username, ok = startupMessage.Parameters["user"]; ok && len(username) > 0 {
-		if h.Conn().RemoteAddr().Network() == "unix" {
+	if h.Conn().RemoteAddr().Network() == "unix" {
+		host = "localhost"
+	} else {
+		host, _, _ = net.SplitHostPort(h.Conn().RemoteAddr().String())
+		if len(host) == 0 {
 			host = "localhost"
-		} else {
-			host, _, _ = net.SplitHostPort(h.Conn().RemoteAddr().String())
-			if len(host) == 0 {
-				host = "localhost"
-			}
 		}
-	} else {
-		username = "doltgres" // TODO: should we use this, or the default "postgres" since programs may default to it?
-		host = "localhost"
 	}
 	h.mysqlConn.User = username
 	h.mysqlConn.UserData = sql.MysqlConnectionUser{
 		User: username,
 		Host: host,
 	}
-	// Since this is all still in development, we'll check if authentication is enabled.
-	if !EnableAuthentication {
+
+	db := h.duckHandler.e.Analyzer.Catalog.MySQLDb
+	if !db.Enabled() {
 		return h.send(&pgproto3.AuthenticationOk{})
 	}
+	rd := db.Reader()
+	user := db.GetUser(rd, username, host, false)
+	rd.Close()
+
+	// Even though we can determine whether the account exists at this point, we delay the actual error for additional security.
+	var verifier *plugin.ScramSha256Verifier
+	if user != nil && !user.Locked {
+		// Privileges are granted to the matched account, which may have a wildcard host.
+		h.mysqlConn.UserData = sql.MysqlConnectionUser{
+			User: user.User,
+			Host: user.Host,
+		}
+		if len(user.Password) == 0 {
+			return h.send(&pgproto3.AuthenticationOk{})
+		}
+		var err error
+		if verifier, err = plugin.GetScramSha256Verifier(user); err != nil {
+			logrus.WithError(err).Warnf("Failed to read the SCRAM verifier of user %q", username)
+		} else if verifier == nil {
+			logrus.Warnf("User %q has no SCRAM verifier; set its password with ALTER USER to log in over PostgreSQL", username)
+		}
+	}
+
 	// We only support one mechanism for now.
 	if err := h.send(&pgproto3.AuthenticationSASL{
 		AuthMechanisms: []string{
@@ -134,8 +143,6 @@ func (h *ConnectionHandler) handleAuthentication(startupMessage *pgproto3.Startu
 	if err := h.backend.SetAuthType(pgproto3.AuthTypeSASL); err != nil {
 		return err
 	}
-	// Even though we can determine whether the role exists at this point, we delay the actual error for additional security.
-	role := auth.GetRole(username)
 	var saslInitial SASLInitial
 	var saslContinue SASLContinue
 	var saslResponse SASLResponse
@@ -155,18 +162,20 @@ func (h *ConnectionHandler) handleAuthentication(startupMessage *pgproto3.Startu
 				})
 				return err
 			}
-			var salt string
-			if role.Password != nil {
-				salt = role.Password.Salt.ToBase64()
-			} else {
-				// We do this to get a stable salt. An unstable salt could be used to determine whether a username exists.
-				salt = rfc5802.H(rfc5802.OctetString(username))[:16].ToBase64()
+			nonce := make(rfc5802.OctetString, 16)
+			if _, err = rand.Read(nonce); err != nil {
+				return err
 			}
 			saslContinue = SASLContinue{
-				Nonce:      saslInitial.Nonce + auth.GenerateRandomOctetString(16).ToBase64(),
-				Salt:       salt,
+				Nonce: saslInitial.Nonce + nonce.ToBase64(),
+				// We use a stable salt if there is no verifier. An unstable salt could be used to determine whether a user exists.
+				Salt:       rfc5802.H(rfc5802.OctetString(username))[:16].ToBase64(),
 				Iterations: 4096,
 			}
+			if verifier != nil {
+				saslContinue.Salt = verifier.Salt.ToBase64()
+				saslContinue.Iterations = verifier.Iterations
+			}
 			if err = h.send(saslContinue.Encode()); err != nil {
 				return err
 			}
@@ -183,7 +192,7 @@ func (h *ConnectionHandler) handleAuthentication(startupMessage *pgproto3.Startu
 				})
 				return err
 			}
-			serverSignature, err := verifySASLClientProof(role, saslInitial, saslContinue, saslResponse)
+			serverSignature, err := verifySASLClientProof(username, verifier, saslInitial, saslContinue, saslResponse)
 			if err != nil {
 				_ = h.send(&pgproto3.ErrorResponse{
 					Severity: "FATAL",
@@ -312,23 +321,22 @@ func readSASLResponse(gs2EncodedHeader string, nonce string, r *pgproto3.SASLRes
 // verifySASLClientProof verifies that the proof given by the client in valid. Returns the base64-encoded
 // ServerSignature, which verifies (to the client) that the server has proper access to the client's authentication
 // information.
-func verifySASLClientProof(user auth.Role, saslInitial SASLInitial, saslContinue SASLContinue, saslResponse SASLResponse) (string, error) {
-	if !user.CanLogin || user.Password == nil {
-		return "", fmt.Errorf(`password authentication failed for user "%s"`, user.Name)
+func verifySASLClientProof(username string, verifier *plugin.ScramSha256Verifier, saslInitial SASLInitial, saslContinue SASLContinue, saslResponse SASLResponse) (string, error) {
+	if verifier == nil {
+		return "", fmt.Errorf(`password authentication failed for user "%s"`, username)
 	}
-	// TODO: check the "valid until" time
 	clientProof := rfc5802.Base64ToOctetString(saslResponse.ClientProof)
 	authMessage := fmt.Sprintf("%s,%s,%s", saslInitial.MessageBare(), saslContinue.Encode().Data, saslResponse.MessageWithoutProof())
-	clientSignature := rfc5802.ClientSignature(user.Password.StoredKey, authMessage)
+	clientSignature := rfc5802.ClientSignature(verifier.StoredKey, authMessage)
 	if len(clientProof) != len(clientSignature) {
-		return "", fmt.Errorf(`password authentication failed for user "%s"`, user.Name)
+		return "", fmt.Errorf(`password authentication failed for user "%s"`, username)
 	}
 	clientKey := clientSignature.Xor(clientProof)
 	storedKey := rfc5802.StoredKey(clientKey)
-	if !storedKey.Equals(user.Password.StoredKey) {
-		return "", fmt.Errorf(`password authentication failed for user "%s"`, user.Name)
+	if !storedKey.Equals(verifier.StoredKey) {
+		return "", fmt.Errorf(`password authentication failed for user "%s"`, username)
 	}
-	serverSignature := rfc5802.ServerSignature(user.Password.ServerKey, authMessage)
+	serverSignature := rfc5802.ServerSignature(verifier.ServerKey, authMessage)
 	return serverSignature.ToBase64(), nil
 }
 
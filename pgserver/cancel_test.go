@@ -0,0 +1,25 @@
+package pgserver
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+func TestValidCancelRequest(t *testing.T) {
+	backendKeys.Store(uint32(7), uint32(1234))
+	defer backendKeys.Delete(uint32(7))
+
+	for _, tt := range []struct {
+		req  pgproto3.CancelRequest
+		want bool
+	}{
+		{pgproto3.CancelRequest{ProcessID: 7, SecretKey: 1234}, true},
+		{pgproto3.CancelRequest{ProcessID: 7, SecretKey: 4321}, false},
+		{pgproto3.CancelRequest{ProcessID: 8, SecretKey: 1234}, false},
+	} {
+		if got := validCancelRequest(&tt.req); got != tt.want {
+			t.Errorf("validCancelRequest(%+v) = %v; want %v", tt.req, got, tt.want)
+		}
+	}
+}
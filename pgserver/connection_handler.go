@@ -18,15 +18,20 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/apecloud/myduckserver/audit"
 	"github.com/apecloud/myduckserver/backend"
 	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/parser"
 	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
@@ -52,6 +57,11 @@ type ConnectionHandler struct {
 	// copyFromStdinState is set when this connection is in the COPY FROM STDIN mode, meaning it is waiting on
 	// COPY DATA messages from the client to import data into tables.
 	copyFromStdinState *copyFromStdinState
+	// statementRows is the number of rows returned or affected by the last statement, for the audit log.
+	statementRows int32
+
+	tlsConfig              *tls.Config
+	requireSecureTransport bool
 }
 
 // Set this env var to disable panic handling in the connection, which is useful when debugging a panic
@@ -67,7 +77,7 @@ func init() {
 }
 
 // NewConnectionHandler returns a new ConnectionHandler for the connection provided
-func NewConnectionHandler(conn net.Conn, handler mysql.Handler, server *server.Server) *ConnectionHandler {
+func NewConnectionHandler(conn net.Conn, handler mysql.Handler, server *server.Server, tlsConfig *tls.Config, requireSecureTransport bool) *ConnectionHandler {
 	mysqlConn := &mysql.Conn{
 		Conn:        conn,
 		PrepareData: make(map[uint32]*mysql.PrepareData),
@@ -97,6 +107,9 @@ func NewConnectionHandler(conn net.Conn, handler mysql.Handler, server *server.S
 		duckHandler:        duckHandler,
 		backend:            pgproto3.NewBackend(conn, conn),
 		pgTypeMap:          pgtype.NewMap(),
+
+		tlsConfig:              tlsConfig,
+		requireSecureTransport: requireSecureTransport,
 	}
 }
 
@@ -138,6 +151,7 @@ func (h *ConnectionHandler) HandleConnection() {
 		}()
 	}
 	h.duckHandler.NewConnection(h.mysqlConn)
+	defer backendKeys.Delete(h.mysqlConn.ConnectionID)
 
 	if proceed, err := h.handleStartup(); err != nil || !proceed {
 		returnErr = err
@@ -183,7 +197,19 @@ func (h *ConnectionHandler) handleStartup() (bool, error) {
 
 	switch sm := startupMessage.(type) {
 	case *pgproto3.StartupMessage:
-		if err = h.handleAuthentication(sm); err != nil {
+		if _, secure := h.Conn().(*tls.Conn); h.requireSecureTransport && !secure {
+			_ = h.send(&pgproto3.ErrorResponse{
+				Severity: "FATAL",
+				Code:     "28000", // invalid_authorization_specification
+				Message:  "connections using insecure transport are prohibited; SSL is required",
+			})
+			return false, nil
+		}
+		err = h.handleAuthentication(sm)
+		if audit.Enabled() {
+			h.auditLogin(err)
+		}
+		if err != nil {
 			return false, err
 		}
 		if err = h.sendClientStartupMessages(); err != nil {
@@ -192,11 +218,14 @@ func (h *ConnectionHandler) handleStartup() (bool, error) {
 		if err = h.chooseInitialDatabase(sm); err != nil {
 			return false, err
 		}
+		if err = h.connectionReady(); err != nil {
+			return false, err
+		}
 		return true, h.send(&pgproto3.ReadyForQuery{
 			TxStatus: byte(ReadyForQueryTransactionIndicator_Idle),
 		})
 	case *pgproto3.SSLRequest:
-		hasCertificate := len(certificate.Certificate) > 0
+		hasCertificate := h.tlsConfig != nil
 		var performSSL = []byte("N")
 		if hasCertificate {
 			performSSL = []byte("S")
@@ -209,11 +238,13 @@ func (h *ConnectionHandler) handleStartup() (bool, error) {
 		// This involves swapping out our underlying net connection for a new one.
 		// We can't start in SSL mode, as the client does not attempt the handshake until after our response.
 		if hasCertificate {
-			h.setConn(tls.Server(h.Conn(), &tls.Config{
-				Certificates: []tls.Certificate{certificate},
-			}))
+			h.setConn(tls.Server(h.Conn(), h.tlsConfig))
 		}
 		return h.handleStartup()
+	case *pgproto3.CancelRequest:
+		// The request comes on a connection of its own, which is closed without a response.
+		h.handleCancelRequest(sm)
+		return false, nil
 	case *pgproto3.GSSEncRequest:
 		// we don't support GSSAPI
 		_, err = h.Conn().Write([]byte("N"))
@@ -240,12 +271,50 @@ func (h *ConnectionHandler) sendClientStartupMessages() error {
 	}); err != nil {
 		return err
 	}
+	// The client identifies the connection by these keys in a CancelRequest.
+	var key [4]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return err
+	}
+	secretKey := binary.BigEndian.Uint32(key[:])
+	backendKeys.Store(h.mysqlConn.ConnectionID, secretKey)
 	return h.send(&pgproto3.BackendKeyData{
-		ProcessID: processID,
-		SecretKey: 0, // TODO: this should represent an ID that can uniquely identify this connection, so that CancelRequest will work
+		ProcessID: h.mysqlConn.ConnectionID,
+		SecretKey: secretKey,
 	})
 }
 
+// handleCancelRequest interrupts the query running on the connection that the request identifies.
+// As in PostgreSQL, requests with a wrong secret key are ignored.
+func (h *ConnectionHandler) handleCancelRequest(req *pgproto3.CancelRequest) {
+	if !validCancelRequest(req) {
+		logrus.WithField(sql.ConnectionIdLogField, req.ProcessID).Warn("Ignoring a CancelRequest with a wrong key")
+		return
+	}
+	logrus.WithField(sql.ConnectionIdLogField, req.ProcessID).Info("Cancelling the query on CancelRequest")
+	h.duckHandler.e.ProcessList.Kill(req.ProcessID)
+}
+
+// validCancelRequest reports whether |req| carries the secret key sent to the connection that it identifies.
+func validCancelRequest(req *pgproto3.CancelRequest) bool {
+	key, ok := backendKeys.Load(req.ProcessID)
+	return ok && key.(uint32) == req.SecretKey
+}
+
+// connectionReady shows the session in the process list with its user, once the connection is established,
+// and creates pg_stat_activity for it.
+func (h *ConnectionHandler) connectionReady() error {
+	ctx, err := h.duckHandler.NewContext(context.Background(), h.mysqlConn, "")
+	if err != nil {
+		return err
+	}
+	if err := createStatActivity(ctx); err != nil {
+		return err
+	}
+	h.duckHandler.e.ProcessList.ConnectionReady(ctx.Session)
+	return nil
+}
+
 // chooseInitialDatabase attempts to choose the initial database for the connection,
 // if one is specified in the startup message provided
 func (h *ConnectionHandler) chooseInitialDatabase(startupMessage *pgproto3.StartupMessage) error {
@@ -384,6 +453,11 @@ func (h *ConnectionHandler) handleMessage(msg pgproto3.Message) (stop, endOfMess
 // expected as part of this query, in which case the server will send a READY FOR QUERY message back to the client so
 // that it can send its next query.
 func (h *ConnectionHandler) handleQuery(message *pgproto3.Query) (endOfMessages bool, err error) {
+	if audit.Enabled() {
+		start := time.Now()
+		defer func() { h.auditStatement(audit.EventQuery, message.String, start, err) }()
+	}
+
 	handled, err := h.handledPSQLCommands(message.String)
 	if handled || err != nil {
 		return true, err
@@ -396,6 +470,11 @@ func (h *ConnectionHandler) handleQuery(message *pgproto3.Query) (endOfMessages
 		return true, err
 	}
 
+	// SHOW PROCESSLIST is not PostgreSQL syntax, but lists the sessions of both ports as on the MySQL port.
+	if full, ok := backend.ParseShowProcessList(message.String); ok {
+		return true, h.showProcessList(message.String, full)
+	}
+
 	query, err := h.convertQuery(message.String)
 	if err != nil {
 		return true, err
@@ -425,6 +504,14 @@ func (h *ConnectionHandler) handleQueryOutsideEngine(query ConvertedQuery) (hand
 		return true, true, h.deallocatePreparedStatement(stmt.Name.String(), h.preparedStatements, query, h.Conn())
 	case *tree.Discard:
 		return true, true, h.discardAll(query)
+	case *tree.SetVar:
+		if stmt.Name == "statement_timeout" && !stmt.Local {
+			return true, true, h.setStatementTimeout(query, stmt)
+		}
+	case *tree.ShowVar:
+		if stmt.Name == "statement_timeout" {
+			return true, true, h.showStatementTimeout(query)
+		}
 	case *tree.CopyFrom:
 		// When copying data from STDIN, the data is sent to the server as CopyData messages
 		// We send endOfMessages=false since the server will be in COPY DATA mode and won't
@@ -551,7 +638,7 @@ func (h *ConnectionHandler) handleBind(message *pgproto3.Bind) error {
 }
 
 // handleExecute handles an execute message, returning any error that occurs
-func (h *ConnectionHandler) handleExecute(message *pgproto3.Execute) error {
+func (h *ConnectionHandler) handleExecute(message *pgproto3.Execute) (err error) {
 	h.waitForSync = true
 
 	// TODO: implement the RowMax
@@ -562,6 +649,10 @@ func (h *ConnectionHandler) handleExecute(message *pgproto3.Execute) error {
 
 	logrus.Tracef("executing portal %s with contents %v", message.Portal, portalData)
 	query := portalData.Query
+	if audit.Enabled() {
+		start := time.Now()
+		defer func() { h.auditStatement(audit.EventExecute, query.String, start, err) }()
+	}
 
 	if portalData.IsEmptyQuery {
 		return h.send(&pgproto3.EmptyQueryResponse{})
@@ -578,6 +669,7 @@ func (h *ConnectionHandler) handleExecute(message *pgproto3.Execute) error {
 
 	callback := h.spoolRowsCallback(query.StatementTag, &rowsAffected, true)
 	err = h.duckHandler.ComExecuteBound(context.Background(), h.mysqlConn, query.String, portalData.BoundPlan, callback)
+	h.statementRows = rowsAffected
 	if err != nil {
 		return err
 	}
@@ -814,6 +906,7 @@ func (h *ConnectionHandler) query(query ConvertedQuery) error {
 
 	callback := h.spoolRowsCallback(query.StatementTag, &rowsAffected, false)
 	err := h.duckHandler.ComQuery(context.Background(), h.mysqlConn, query.String, query.AST, callback)
+	h.statementRows = rowsAffected
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "syntax error at position") {
 			return fmt.Errorf("This statement is not yet supported")
@@ -909,14 +1002,14 @@ func (h *ConnectionHandler) handledPSQLCommands(statement string) (bool, error)
 	// Command: \d
 	if statement == "select n.nspname as \"schema\",\n  c.relname as \"name\",\n  case c.relkind when 'r' then 'table' when 'v' then 'view' when 'm' then 'materialized view' when 'i' then 'index' when 's' then 'sequence' when 't' then 'toast table' when 'f' then 'foreign table' when 'p' then 'partitioned table' when 'i' then 'partitioned index' end as \"type\",\n  pg_catalog.pg_get_userbyid(c.relowner) as \"owner\"\nfrom pg_catalog.pg_class c\n     left join pg_catalog.pg_namespace n on n.oid = c.relnamespace\n     left join pg_catalog.pg_am am on am.oid = c.relam\nwhere c.relkind in ('r','p','v','m','s','f','')\n      and n.nspname <> 'pg_catalog'\n      and n.nspname !~ '^pg_toast'\n      and n.nspname <> 'information_schema'\n  and pg_catalog.pg_table_is_visible(c.oid)\norder by 1,2;" {
 		return true, h.query(ConvertedQuery{
-			String:       `SELECT table_schema AS "Schema", TABLE_NAME AS "Name", IF(TABLE_TYPE = 'VIEW', 'view', 'table') AS "Type", 'postgres' AS "Owner" FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA <> 'pg_catalog' AND TABLE_SCHEMA <> 'information_schema' AND TABLE_TYPE = 'BASE TABLE' OR TABLE_TYPE = 'VIEW' ORDER BY 2;`,
+			String:       `SELECT table_schema AS "Schema", TABLE_NAME AS "Name", CASE WHEN TABLE_TYPE = 'VIEW' THEN 'view' ELSE 'table' END AS "Type", 'postgres' AS "Owner" FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA <> 'pg_catalog' AND TABLE_SCHEMA <> 'information_schema' AND TABLE_TYPE = 'BASE TABLE' OR TABLE_TYPE = 'VIEW' ORDER BY 2;`,
 			StatementTag: "SELECT",
 		})
 	}
 	// Alternate \d for psql 14
 	if statement == "select n.nspname as \"schema\",\n  c.relname as \"name\",\n  case c.relkind when 'r' then 'table' when 'v' then 'view' when 'm' then 'materialized view' when 'i' then 'index' when 's' then 'sequence' when 's' then 'special' when 't' then 'toast table' when 'f' then 'foreign table' when 'p' then 'partitioned table' when 'i' then 'partitioned index' end as \"type\",\n  pg_catalog.pg_get_userbyid(c.relowner) as \"owner\"\nfrom pg_catalog.pg_class c\n     left join pg_catalog.pg_namespace n on n.oid = c.relnamespace\n     left join pg_catalog.pg_am am on am.oid = c.relam\nwhere c.relkind in ('r','p','v','m','s','f','')\n      and n.nspname <> 'pg_catalog'\n      and n.nspname !~ '^pg_toast'\n      and n.nspname <> 'information_schema'\n  and pg_catalog.pg_table_is_visible(c.oid)\norder by 1,2;" {
 		return true, h.query(ConvertedQuery{
-			String:       `SELECT table_schema AS "Schema", TABLE_NAME AS "Name", IF(TABLE_TYPE = 'VIEW', 'view', 'table') AS "Type", 'postgres' AS "Owner" FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA <> 'pg_catalog' AND TABLE_SCHEMA <> 'information_schema' AND TABLE_TYPE = 'BASE TABLE' OR TABLE_TYPE = 'VIEW' ORDER BY 2;`,
+			String:       `SELECT table_schema AS "Schema", TABLE_NAME AS "Name", CASE WHEN TABLE_TYPE = 'VIEW' THEN 'view' ELSE 'table' END AS "Type", 'postgres' AS "Owner" FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA <> 'pg_catalog' AND TABLE_SCHEMA <> 'information_schema' AND TABLE_TYPE = 'BASE TABLE' OR TABLE_TYPE = 'VIEW' ORDER BY 2;`,
 			StatementTag: "SELECT",
 		})
 	}
@@ -995,10 +1088,17 @@ func (h *ConnectionHandler) endOfMessages(err error) {
 // sendError sends the given error to the client. This should generally never be called directly.
 func (h *ConnectionHandler) sendError(err error) {
 	fmt.Println(err.Error())
+	code, message := "XX000", err.Error() // internal_error for now
+	switch {
+	case errors.Is(err, backend.ErrQueryInterrupted):
+		code, message = "57014", "canceling statement due to user request" // query_canceled
+	case errors.Is(err, backend.ErrQueryTimeout):
+		code, message = "57014", "canceling statement due to statement timeout"
+	}
 	if sendErr := h.send(&pgproto3.ErrorResponse{
 		Severity: string(ErrorResponseSeverity_Error),
-		Code:     "XX000", // internal_error for now
-		Message:  err.Error(),
+		Code:     code,
+		Message:  message,
 	}); sendErr != nil {
 		// If we're unable to send anything to the connection, then there's something wrong with the connection and
 		// we should terminate it. This will be caught in HandleConnection's defer block.
@@ -1059,6 +1159,13 @@ func (h *ConnectionHandler) handleCopyFromStdinQuery(query ConvertedQuery, copyF
 	}
 	sqlCtx.SetLogger(sqlCtx.GetLogger().WithField("query", query.String))
 
+	if err := h.duckHandler.checkWritable(sqlCtx, query.String); err != nil {
+		return err
+	}
+	if err := h.duckHandler.checkPrivileges(sqlCtx, query.String); err != nil {
+		return err
+	}
+
 	if err := ValidateCopyFrom(copyFrom, sqlCtx); err != nil {
 		return err
 	}
@@ -157,6 +157,7 @@ func (loader *CsvDataLoader) buildSQL() string {
 
 func (loader *CsvDataLoader) executeCopy(sql string) {
 	defer close(loader.rowCount)
+	loader.ctx.Session.(*backend.Session).RecordQuery(sql)
 	result, err := adapter.Exec(loader.ctx, sql)
 	if err != nil {
 		loader.ctx.GetLogger().Error(err)
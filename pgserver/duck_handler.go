@@ -27,6 +27,7 @@ import (
 
 	"github.com/apecloud/myduckserver/adapter"
 	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/metrics"
 	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
 	sqle "github.com/dolthub/go-mysql-server"
 	"github.com/dolthub/go-mysql-server/server"
@@ -104,9 +105,11 @@ func (h *DuckHandler) ComExecuteBound(ctx context.Context, conn *mysql.Conn, que
 		return fmt.Errorf("boundQuery must be a sql.Node, but got %T", boundQuery)
 	}
 
+	start := time.Now()
 	err := h.doQuery(ctx, conn, query, nil, analyzedPlan, h.executeBoundPlan, callback)
+	metrics.ObserveQuery(metrics.ProtocolPostgres, start, err)
 	if err != nil {
-		err = sql.CastSQLError(err)
+		err = sql.CastSQLError(backend.QueryInterrupted(err))
 	}
 
 	return err
@@ -148,9 +151,11 @@ func (h *DuckHandler) ComPrepareParsed(ctx context.Context, c *mysql.Conn, query
 
 // ComQuery implements the Handler interface.
 func (h *DuckHandler) ComQuery(ctx context.Context, c *mysql.Conn, query string, parsed tree.Statement, callback func(*Result) error) error {
+	start := time.Now()
 	err := h.doQuery(ctx, c, query, parsed, nil, h.executeQuery, callback)
+	metrics.ObserveQuery(metrics.ProtocolPostgres, start, err)
 	if err != nil {
-		err = sql.CastSQLError(err)
+		err = sql.CastSQLError(backend.QueryInterrupted(err))
 	}
 	return err
 }
@@ -225,16 +230,21 @@ func (h *DuckHandler) doQuery(ctx context.Context, c *mysql.Conn, query string,
 	sqlCtx.GetLogger().Debugf("Starting query")
 	sqlCtx.GetLogger().Tracef("beginning execution")
 
-	oCtx := ctx
-
 	// TODO: it would be nice to put this logic in the engine, not the handler, but we don't want the process to be
 	//  marked done until we're done spooling rows over the wire
-	ctx, err = sqlCtx.ProcessList.BeginQuery(sqlCtx, query)
-	defer func() {
-		if err != nil && ctx != nil {
-			sqlCtx.ProcessList.EndQuery(sqlCtx)
-		}
-	}()
+	// The query runs with the context returned by BeginQuery, which KILL QUERY and CancelRequest cancel.
+	sqlCtx, err = sqlCtx.ProcessList.BeginQuery(sqlCtx, query)
+	if err != nil {
+		return err
+	}
+	defer sqlCtx.ProcessList.EndQuery(sqlCtx)
+
+	// statement_timeout bounds the whole statement, including the transfer of its result.
+	if timeout := statementTimeout(sqlCtx); timeout > 0 {
+		timeoutCtx, cancel := context.WithTimeout(sqlCtx.Context, timeout)
+		defer cancel()
+		sqlCtx = sqlCtx.WithContext(timeoutCtx)
+	}
 
 	schema, rowIter, qFlags, err := queryExec(sqlCtx, query, parsed, analyzedPlan)
 	if err != nil {
@@ -270,9 +280,6 @@ func (h *DuckHandler) doQuery(ctx context.Context, c *mysql.Conn, query string,
 		return err
 	}
 
-	// errGroup context is now canceled
-	ctx = oCtx
-
 	sqlCtx.GetLogger().Debugf("Query finished in %d ms", time.Since(start).Milliseconds())
 
 	// processedAtLeastOneBatch means we already called callback() at least
@@ -302,6 +309,13 @@ func (h *DuckHandler) executeQuery(ctx *sql.Context, query string, parsed tree.S
 		return nil, nil, nil, err
 	}
 
+	if err := h.checkWritable(ctx, query); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := h.checkPrivileges(ctx, query); err != nil {
+		return nil, nil, nil, err
+	}
+
 	err = h.beginTransaction(ctx)
 	if err != nil {
 		return nil, nil, nil, err
@@ -322,6 +336,7 @@ func (h *DuckHandler) executeQuery(ctx *sql.Context, query string, parsed tree.S
 	// }
 
 	// TODO(fan): For DML statements, we should call Exec
+	ctx.Session.(*backend.Session).RecordQuery(query)
 	rows, err := adapter.Query(ctx, query)
 	if err != nil {
 		return nil, nil, nil, err
@@ -16,9 +16,10 @@ package pgserver
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
-	"os"
+	"sync"
 
 	"github.com/dolthub/go-mysql-server/server"
 	"github.com/dolthub/vitess/go/mysql"
@@ -27,8 +28,9 @@ import (
 
 var (
 	connectionIDCounter uint32
-	processID           = uint32(os.Getpid())
-	certificate         tls.Certificate //TODO: move this into the mysql.ListenerConfig
+	// backendKeys holds the secret keys sent to the clients in BackendKeyData by their connection IDs,
+	// which stand for the process IDs of PostgreSQL.
+	backendKeys sync.Map // map[uint32]uint32
 )
 
 // Listener listens for connections to process PostgreSQL requests into Dolt requests.
@@ -36,6 +38,11 @@ type Listener struct {
 	listener net.Listener
 	cfg      mysql.ListenerConfig
 	server   *server.Server
+
+	// tlsConfig is used to upgrade connections that send an SSLRequest. If nil, SSL is refused.
+	tlsConfig *tls.Config
+	// requireSecureTransport rejects connections that have not been upgraded to TLS.
+	requireSecureTransport bool
 }
 
 var _ server.ProtocolListener = (*Listener)(nil)
@@ -44,7 +51,23 @@ type ListenerOpt func(*Listener)
 
 func WithCertificate(cert tls.Certificate) ListenerOpt {
 	return func(l *Listener) {
-		certificate = cert
+		l.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+}
+
+// WithTLSConfig enables SSL with the given configuration, which may also require client certificates.
+func WithTLSConfig(cfg *tls.Config) ListenerOpt {
+	return func(l *Listener) {
+		l.tlsConfig = cfg
+	}
+}
+
+// WithRequireSecureTransport rejects connections that do not use SSL. It requires a TLS configuration.
+func WithRequireSecureTransport(require bool) ListenerOpt {
+	return func(l *Listener) {
+		l.requireSecureTransport = require
 	}
 }
 
@@ -64,6 +87,10 @@ func NewListenerWithOpts(listenerCfg mysql.ListenerConfig, server *server.Server
 		opt(l)
 	}
 
+	if l.requireSecureTransport && l.tlsConfig == nil {
+		return nil, errors.New("secure transport is required, but no TLS certificate is configured")
+	}
+
 	return l, nil
 }
 
@@ -72,7 +99,7 @@ func (l *Listener) Accept() {
 	for {
 		conn, err := l.listener.Accept()
 		if err != nil {
-			if err.Error() == "use of closed network connection" {
+			if errors.Is(err, net.ErrClosed) {
 				break
 			}
 			fmt.Printf("Unable to accept connection:\n%v\n", err)
@@ -85,7 +112,7 @@ func (l *Listener) Accept() {
 			conn = netutil.NewConnWithTimeouts(conn, l.cfg.ConnReadTimeout, l.cfg.ConnWriteTimeout)
 		}
 
-		connectionHandler := NewConnectionHandler(conn, l.cfg.Handler, l.server)
+		connectionHandler := NewConnectionHandler(conn, l.cfg.Handler, l.server, l.tlsConfig, l.requireSecureTransport)
 		go connectionHandler.HandleConnection()
 	}
 }
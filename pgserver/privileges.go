@@ -0,0 +1,442 @@
+package pgserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/parser"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+// AuthorizeQuery checks a query in DuckDB syntax against the read-only mode and the grants of the
+// session's user, as done for the queries received on this port. It serves the other listeners
+// that pass queries to DuckDB as-is.
+func AuthorizeQuery(ctx *sql.Context, e *sqle.Engine, query string) error {
+	h := &DuckHandler{e: e}
+	if err := h.checkWritable(ctx, query); err != nil {
+		return err
+	}
+	return h.checkPrivileges(ctx, query)
+}
+
+// checkPrivileges verifies that the user holds the MySQL privileges required by a query.
+// Queries on this port are not planned by go-mysql-server, so the tables they reference are
+// resolved from the parsed statement instead, with DuckDB schemas standing for MySQL databases.
+// A query that cannot be analyzed (e.g., one in DuckDB-only syntax) requires the SUPER privilege.
+func (h *DuckHandler) checkPrivileges(ctx *sql.Context, query string) error {
+	db := h.e.Analyzer.Catalog.MySQLDb
+	if !db.Enabled() {
+		return nil
+	}
+	ops, ok := requiredPrivileges(query, ctx.GetCurrentDatabase())
+	if !ok {
+		ops = []sql.PrivilegedOperation{sql.NewPrivilegedOperation(sql.PrivilegeCheckSubject{}, sql.PrivilegeType_Super)}
+	}
+	if db.UserHasPrivileges(ctx, ops...) {
+		return nil
+	}
+
+	client := ctx.Session.Client()
+	user := fmt.Sprintf("'%s'@'%s'", client.User, client.Address)
+	for _, op := range ops {
+		if db.UserHasPrivileges(ctx, op) {
+			continue
+		}
+		if op.Table != "" {
+			return sql.ErrTableAccessDeniedForUser.New(user, op.Table)
+		}
+		if op.Database != "" {
+			return sql.ErrDatabaseAccessDeniedForUser.New(user, op.Database)
+		}
+		break
+	}
+	return sql.ErrPrivilegeCheckFailed.New(user)
+}
+
+// requiredPrivileges returns the privileges required by the statements of |query|,
+// resolving unqualified names in |database|. It returns false if the query cannot be analyzed.
+func requiredPrivileges(query string, database string) ([]sql.PrivilegedOperation, bool) {
+	stmts, err := parser.Parse(query)
+	if err != nil {
+		return nil, false
+	}
+	c := &privilegeCollector{database: database}
+	for _, stmt := range stmts {
+		c.statement(stmt.AST)
+	}
+	if c.unsupported {
+		return nil, false
+	}
+	return c.ops, true
+}
+
+// pgCatalogTables are the tables of DuckDB's pg_catalog schema, which are found without qualification.
+var pgCatalogTables = map[string]bool{
+	"pg_am": true, "pg_attrdef": true, "pg_attribute": true, "pg_class": true, "pg_constraint": true,
+	"pg_database": true, "pg_depend": true, "pg_description": true, "pg_enum": true, "pg_index": true,
+	"pg_indexes": true, "pg_namespace": true, "pg_prepared_statements": true, "pg_proc": true,
+	"pg_sequence": true, "pg_sequences": true, "pg_settings": true, "pg_tables": true,
+	"pg_tablespace": true, "pg_type": true, "pg_views": true,
+}
+
+// fileTableFunctions are the table functions that read files (or URLs) in addition to those named read_*.
+var fileTableFunctions = map[string]bool{
+	"glob": true, "sniff_csv": true, "parquet_scan": true, "parquet_metadata": true, "parquet_schema": true,
+	"parquet_file_metadata": true, "parquet_kv_metadata": true, "iceberg_scan": true, "delta_scan": true,
+}
+
+// privilegeCollector walks a statement and collects the privileges it requires on the tables it references.
+type privilegeCollector struct {
+	database    string
+	ops         []sql.PrivilegedOperation
+	ctes        []string // the common table expressions in scope, which shadow tables of the same name
+	unsupported bool
+}
+
+func (c *privilegeCollector) statement(stmt tree.Statement) {
+	switch s := stmt.(type) {
+	case *tree.Select, tree.SelectStatement:
+		c.query(s)
+	case *tree.Insert:
+		c.with(s.With, func() {
+			privs := []sql.PrivilegeType{sql.PrivilegeType_Insert}
+			if s.OnConflict != nil && !s.OnConflict.DoNothing {
+				privs = append(privs, sql.PrivilegeType_Update)
+			}
+			c.returning(s.Returning, &privs)
+			c.target(s.Table, privs...)
+			if s.Rows != nil {
+				c.query(s.Rows)
+			}
+			if s.OnConflict != nil {
+				for _, e := range s.OnConflict.Exprs {
+					c.expr(e.Expr)
+				}
+				c.where(s.OnConflict.Where)
+			}
+		})
+	case *tree.Update:
+		c.with(s.With, func() {
+			privs := []sql.PrivilegeType{sql.PrivilegeType_Update}
+			c.returning(s.Returning, &privs)
+			c.target(s.Table, privs...)
+			c.tableExprs(s.From)
+			for _, e := range s.Exprs {
+				c.expr(e.Expr)
+			}
+			c.where(s.Where)
+		})
+	case *tree.Delete:
+		c.with(s.With, func() {
+			privs := []sql.PrivilegeType{sql.PrivilegeType_Delete}
+			c.returning(s.Returning, &privs)
+			c.target(s.Table, privs...)
+			c.tableExprs(s.Using)
+			c.where(s.Where)
+		})
+	case *tree.CreateTable:
+		if s.Persistence == tree.PersistenceTemporary {
+			c.require(c.database, "", sql.PrivilegeType_CreateTempTable)
+		} else if s.AsSource != nil {
+			c.table(&s.Table, sql.PrivilegeType_Create, sql.PrivilegeType_Insert)
+		} else {
+			c.table(&s.Table, sql.PrivilegeType_Create)
+		}
+		if s.AsSource != nil {
+			c.query(s.AsSource)
+		}
+	case *tree.CreateView:
+		if s.Replace {
+			c.table(&s.Name, sql.PrivilegeType_CreateView, sql.PrivilegeType_Drop)
+		} else {
+			c.table(&s.Name, sql.PrivilegeType_CreateView)
+		}
+		c.query(s.AsSource)
+	case *tree.DropTable:
+		for i := range s.Names {
+			c.table(&s.Names[i], sql.PrivilegeType_Drop)
+		}
+	case *tree.DropView:
+		for i := range s.Names {
+			c.table(&s.Names[i], sql.PrivilegeType_Drop)
+		}
+	case *tree.Truncate:
+		for i := range s.Tables {
+			c.table(&s.Tables[i], sql.PrivilegeType_Drop)
+		}
+	case *tree.AlterTable:
+		name := s.Table.ToTableName()
+		c.table(&name, sql.PrivilegeType_Alter)
+	case *tree.RenameTable:
+		// As in MySQL, renaming requires ALTER and DROP on the old table, and CREATE and INSERT on the new one,
+		// which is created in the schema of the old table unless qualified.
+		name, newName := s.Name.ToTableName(), s.NewName.ToTableName()
+		if !newName.ExplicitSchema {
+			newName.ObjectNamePrefix = name.ObjectNamePrefix
+		}
+		c.table(&name, sql.PrivilegeType_Alter, sql.PrivilegeType_Drop)
+		c.table(&newName, sql.PrivilegeType_Create, sql.PrivilegeType_Insert)
+	case *tree.CreateIndex:
+		c.table(&s.Table, sql.PrivilegeType_Index)
+	case *tree.DropIndex:
+		for _, index := range s.IndexList {
+			if index.Table.ObjectName == "" {
+				c.require(c.schema(&index.Table), "", sql.PrivilegeType_Index)
+			} else {
+				c.table(&index.Table, sql.PrivilegeType_Index)
+			}
+		}
+	case *tree.CreateSchema:
+		c.require(string(s.Schema.SchemaName), "", sql.PrivilegeType_Create)
+	case *tree.DropSchema:
+		for _, name := range s.Names {
+			c.require(string(name.SchemaName), "", sql.PrivilegeType_Drop)
+		}
+	case *tree.CopyFrom:
+		c.table(&s.Table, sql.PrivilegeType_Insert)
+		if !s.Stdin {
+			c.require("", "", sql.PrivilegeType_File)
+		}
+	case *tree.CopyTo:
+		if s.Statement != nil {
+			c.statement(s.Statement)
+		} else {
+			c.table(&s.Table, sql.PrivilegeType_Select)
+		}
+	case *tree.Explain:
+		c.statement(s.Statement)
+	case *tree.ExplainAnalyze:
+		c.statement(s.Statement)
+	case *tree.Prepare:
+		c.statement(s.Statement)
+	case *tree.SetVar:
+		// As SET GLOBAL on the MySQL port, changing a setting of the whole server requires SUPER.
+		if changesGlobalSetting(s) {
+			c.require("", "", sql.PrivilegeType_Super)
+		}
+	case *tree.BeginTransaction, *tree.CommitTransaction, *tree.RollbackTransaction,
+		*tree.Savepoint, *tree.ReleaseSavepoint, *tree.RollbackToSavepoint, *tree.SetTransaction,
+		*tree.ShowVar, *tree.Execute, *tree.Deallocate, *tree.Discard:
+		// These statements do not access any table.
+	default:
+		c.unsupported = true
+	}
+}
+
+// query collects the privileges required by a SELECT, VALUES or set operation.
+func (c *privilegeCollector) query(stmt tree.Statement) {
+	switch s := stmt.(type) {
+	case *tree.Select:
+		c.with(s.With, func() {
+			c.query(s.Select)
+			for _, order := range s.OrderBy {
+				c.expr(order.Expr)
+			}
+			if s.Limit != nil {
+				c.expr(s.Limit.Offset)
+				c.expr(s.Limit.Count)
+			}
+		})
+	case *tree.ParenSelect:
+		c.query(s.Select)
+	case *tree.SelectClause:
+		c.tableExprs(s.From.Tables)
+		for _, e := range s.Exprs {
+			c.expr(e.Expr)
+		}
+		for _, e := range s.DistinctOn {
+			c.expr(e)
+		}
+		for _, e := range s.GroupBy {
+			c.expr(e)
+		}
+		c.where(s.Where)
+		c.where(s.Having)
+	case *tree.UnionClause:
+		c.query(s.Left)
+		c.query(s.Right)
+	case *tree.ValuesClause:
+		for _, row := range s.Rows {
+			for _, e := range row {
+				c.expr(e)
+			}
+		}
+	default:
+		c.unsupported = true
+	}
+}
+
+// with brings the common table expressions of |w| into scope for |fn|.
+func (c *privilegeCollector) with(w *tree.With, fn func()) {
+	n := len(c.ctes)
+	if w != nil {
+		for _, cte := range w.CTEList {
+			// A non-recursive CTE cannot refer to itself, so a reference to its name in its own body is to a table.
+			if w.Recursive {
+				c.ctes = append(c.ctes, string(cte.Name.Alias))
+			}
+			c.statement(cte.Stmt)
+			if !w.Recursive {
+				c.ctes = append(c.ctes, string(cte.Name.Alias))
+			}
+		}
+	}
+	fn()
+	c.ctes = c.ctes[:n]
+}
+
+func (c *privilegeCollector) tableExprs(exprs tree.TableExprs) {
+	for _, e := range exprs {
+		c.tableExpr(e)
+	}
+}
+
+func (c *privilegeCollector) tableExpr(expr tree.TableExpr) {
+	switch e := expr.(type) {
+	case *tree.TableName:
+		c.table(e, sql.PrivilegeType_Select)
+	case *tree.AliasedTableExpr:
+		c.tableExpr(e.Expr)
+	case *tree.ParenTableExpr:
+		c.tableExpr(e.Expr)
+	case *tree.JoinTableExpr:
+		c.tableExpr(e.Left)
+		c.tableExpr(e.Right)
+		if cond, ok := e.Cond.(*tree.OnJoinCond); ok {
+			c.expr(cond.Expr)
+		}
+	case *tree.Subquery:
+		c.query(e.Select)
+	case *tree.StatementSource:
+		c.statement(e.Statement)
+	case *tree.RowsFromExpr:
+		for _, item := range e.Items {
+			if f, ok := item.(*tree.FuncExpr); ok {
+				c.tableFunction(f)
+			} else {
+				c.expr(item)
+			}
+		}
+	default:
+		c.unsupported = true
+	}
+}
+
+func (c *privilegeCollector) tableFunction(f *tree.FuncExpr) {
+	name, ok := f.Func.FunctionReference.(*tree.UnresolvedName)
+	if !ok || name.NumParts != 1 {
+		c.unsupported = true
+		return
+	}
+	switch fn := strings.ToLower(name.Parts[0]); {
+	case strings.HasPrefix(fn, "read_") || fileTableFunctions[fn]:
+		c.require("", "", sql.PrivilegeType_File)
+	case fn == "query" || fn == "query_table":
+		// These run a query given as a string, which cannot be analyzed here.
+		c.require("", "", sql.PrivilegeType_Super)
+	case fn == statActivityFunction:
+		c.require("", "", sql.PrivilegeType_Process)
+	}
+	for _, e := range f.Exprs {
+		c.expr(e)
+	}
+}
+
+// target collects the table written by an INSERT, UPDATE or DELETE statement.
+func (c *privilegeCollector) target(expr tree.TableExpr, privs ...sql.PrivilegeType) {
+	if aliased, ok := expr.(*tree.AliasedTableExpr); ok {
+		expr = aliased.Expr
+	}
+	name, ok := expr.(*tree.TableName)
+	if !ok {
+		c.unsupported = true
+		return
+	}
+	c.table(name, privs...)
+}
+
+// returning adds the SELECT privilege for a RETURNING clause, which reads the target table.
+func (c *privilegeCollector) returning(r tree.ReturningClause, privs *[]sql.PrivilegeType) {
+	exprs, ok := r.(*tree.ReturningExprs)
+	if !ok {
+		return
+	}
+	*privs = append(*privs, sql.PrivilegeType_Select)
+	for _, e := range *exprs {
+		c.expr(e.Expr)
+	}
+}
+
+func (c *privilegeCollector) where(w *tree.Where) {
+	if w != nil {
+		c.expr(w.Expr)
+	}
+}
+
+func (c *privilegeCollector) expr(e tree.Expr) {
+	if e != nil {
+		tree.WalkExprConst(subqueryVisitor{c}, e)
+	}
+}
+
+// subqueryVisitor collects the privileges required by the subqueries of an expression.
+type subqueryVisitor struct {
+	c *privilegeCollector
+}
+
+func (v subqueryVisitor) VisitPre(expr tree.Expr) (bool, tree.Expr) {
+	if s, ok := expr.(*tree.Subquery); ok {
+		v.c.query(s.Select)
+		return false, expr
+	}
+	return true, expr
+}
+
+func (v subqueryVisitor) VisitPost(expr tree.Expr) tree.Expr {
+	return expr
+}
+
+// schema returns the schema of |name|, i.e., the MySQL database it belongs to.
+func (c *privilegeCollector) schema(name *tree.TableName) string {
+	if name.ExplicitSchema {
+		return string(name.SchemaName)
+	}
+	return c.database
+}
+
+func (c *privilegeCollector) table(name *tree.TableName, privs ...sql.PrivilegeType) {
+	table := string(name.ObjectName)
+	// pg_stat_activity lists the sessions of all users, as SHOW PROCESSLIST does with the PROCESS privilege.
+	// The view is temporary, so it is also temp.pg_stat_activity and temp.main.pg_stat_activity.
+	if table == "pg_stat_activity" && (!name.ExplicitSchema || strings.EqualFold(string(name.SchemaName), "temp") ||
+		name.ExplicitCatalog && strings.EqualFold(string(name.CatalogName), "temp")) {
+		c.require("", "", sql.PrivilegeType_Process)
+		return
+	}
+	if name.ExplicitSchema {
+		switch strings.ToLower(string(name.SchemaName)) {
+		case "information_schema", "pg_catalog", "temp":
+			return
+		}
+	} else {
+		for _, cte := range c.ctes {
+			if cte == table {
+				return
+			}
+		}
+		if pgCatalogTables[table] {
+			return
+		}
+	}
+	// DuckDB reads a file in place of a table whose name looks like a path, e.g., "data.csv".
+	if strings.ContainsAny(table, "./\\:") {
+		c.require("", "", sql.PrivilegeType_File)
+	}
+	c.require(c.schema(name), table, privs...)
+}
+
+func (c *privilegeCollector) require(database, table string, privs ...sql.PrivilegeType) {
+	c.ops = append(c.ops, sql.NewPrivilegedOperation(sql.PrivilegeCheckSubject{Database: database, Table: table}, privs...))
+}
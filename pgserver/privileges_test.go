@@ -0,0 +1,156 @@
+package pgserver
+
+import (
+	"context"
+	stdsql "database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apecloud/myduckserver/testutil"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+func TestRequiredPrivileges(t *testing.T) {
+	type op struct {
+		database, table string
+		privs           []sql.PrivilegeType
+	}
+	var (
+		sel     = []sql.PrivilegeType{sql.PrivilegeType_Select}
+		insert  = []sql.PrivilegeType{sql.PrivilegeType_Insert}
+		drop    = []sql.PrivilegeType{sql.PrivilegeType_Drop}
+		file    = []sql.PrivilegeType{sql.PrivilegeType_File}
+		process = []sql.PrivilegeType{sql.PrivilegeType_Process}
+		super   = []sql.PrivilegeType{sql.PrivilegeType_Super}
+	)
+	tests := []struct {
+		query    string
+		expected []op // nil if the query cannot be analyzed
+	}{
+		{"SELECT 1", []op{}},
+		{"SELECT * FROM t", []op{{"db", "t", sel}}},
+		{"SELECT * FROM other.t JOIN db.u ON t.id = u.id", []op{{"other", "t", sel}, {"db", "u", sel}}},
+		{"SELECT * FROM t WHERE id IN (SELECT id FROM other.u)", []op{{"db", "t", sel}, {"other", "u", sel}}},
+		{"SELECT (SELECT max(id) FROM u) FROM (SELECT * FROM t) s", []op{{"db", "t", sel}, {"db", "u", sel}}},
+		{"SELECT * FROM t UNION SELECT * FROM u", []op{{"db", "t", sel}, {"db", "u", sel}}},
+		{"WITH c AS (SELECT * FROM t) SELECT * FROM c", []op{{"db", "t", sel}}},
+		{"WITH t AS (SELECT * FROM t) SELECT * FROM t", []op{{"db", "t", sel}}},
+		{"SELECT * FROM (WITH c AS (SELECT 1) SELECT * FROM c) s, c", []op{{"db", "c", sel}}},
+		{"SELECT * FROM information_schema.tables, pg_catalog.pg_class, pg_namespace", []op{}},
+		{"INSERT INTO t SELECT * FROM u", []op{{"db", "t", insert}, {"db", "u", sel}}},
+		{"INSERT INTO t VALUES (1) ON CONFLICT (id) DO UPDATE SET v = 2 RETURNING id",
+			[]op{{"db", "t", []sql.PrivilegeType{sql.PrivilegeType_Insert, sql.PrivilegeType_Update, sql.PrivilegeType_Select}}}},
+		{"UPDATE t SET v = u.v FROM u WHERE t.id = u.id", []op{{"db", "t", []sql.PrivilegeType{sql.PrivilegeType_Update}}, {"db", "u", sel}}},
+		{"DELETE FROM other.t WHERE id IN (SELECT id FROM u)", []op{{"other", "t", []sql.PrivilegeType{sql.PrivilegeType_Delete}}, {"db", "u", sel}}},
+		{"CREATE TABLE t AS SELECT * FROM u", []op{{"db", "t", []sql.PrivilegeType{sql.PrivilegeType_Create, sql.PrivilegeType_Insert}}, {"db", "u", sel}}},
+		{"CREATE VIEW v AS SELECT * FROM u", []op{{"db", "v", []sql.PrivilegeType{sql.PrivilegeType_CreateView}}, {"db", "u", sel}}},
+		{"DROP TABLE t, other.u", []op{{"db", "t", drop}, {"other", "u", drop}}},
+		{"TRUNCATE t", []op{{"db", "t", drop}}},
+		{"ALTER TABLE other.t ADD COLUMN c INT", []op{{"other", "t", []sql.PrivilegeType{sql.PrivilegeType_Alter}}}},
+		{"ALTER TABLE other.t RENAME TO u", []op{
+			{"other", "t", []sql.PrivilegeType{sql.PrivilegeType_Alter, sql.PrivilegeType_Drop}},
+			{"other", "u", []sql.PrivilegeType{sql.PrivilegeType_Create, sql.PrivilegeType_Insert}},
+		}},
+		{"CREATE SCHEMA s", []op{{"s", "", []sql.PrivilegeType{sql.PrivilegeType_Create}}}},
+		{"DROP SCHEMA s", []op{{"s", "", drop}}},
+		{"COPY t FROM STDIN", []op{{"db", "t", insert}}},
+		{"EXPLAIN SELECT * FROM t", []op{{"db", "t", sel}}},
+		{"SELECT * FROM read_csv('/etc/passwd')", []op{{"", "", file}}},
+		{`SELECT * FROM "data.csv"`, []op{{"", "", file}, {"db", "data.csv", sel}}},
+		{"SELECT * FROM generate_series(1, 10)", []op{}},
+		{"SELECT * FROM pg_stat_activity", []op{{"", "", process}}},
+		{"SELECT * FROM temp.pg_stat_activity, myduck_stat_activity()", []op{{"", "", process}, {"", "", process}}},
+		{"SELECT * FROM temp.main.pg_stat_activity", []op{{"", "", process}}},
+		{"BEGIN; SET search_path = db; COMMIT", []op{}},
+		{"SET application_name = 'psql'; SET TIME ZONE 'UTC'; RESET search_path", []op{}},
+		{"SET threads = 1", []op{{"", "", super}}},
+		{"SET SESSION memory_limit = '1GB'", []op{{"", "", super}}},
+		{"SET enable_external_access = false", []op{{"", "", super}}},
+		{"RESET threads", []op{{"", "", super}}},
+		{"RESET ALL", []op{{"", "", super}}},
+		{"SET GLOBAL threads = 1", nil},
+		{"GRANT SELECT ON t TO alice", nil},
+		{"ATTACH 'other.db'", nil},
+	}
+	for _, tt := range tests {
+		ops, ok := requiredPrivileges(tt.query, "db")
+		if tt.expected == nil {
+			if ok {
+				t.Errorf("%s: expected the query not to be analyzed, got %v", tt.query, ops)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: the query was not analyzed", tt.query)
+			continue
+		}
+		got := make([]op, 0, len(ops))
+		for _, o := range ops {
+			got = append(got, op{o.Database, o.Table, o.StaticPrivileges})
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+// TestSetGlobalSettingRequiresSuper checks that a user without SUPER cannot change the settings
+// that DuckDB applies to the whole server, while the settings of the session remain open to all.
+func TestSetGlobalSettingRequiresSuper(t *testing.T) {
+	srv := testutil.NewServer(t)
+	if err := RegisterStatActivity(srv.Pool, srv.Engine.ProcessList); err != nil {
+		t.Fatal(err)
+	}
+	pg, err := NewServer(srv.Server, "127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go pg.Start()
+	t.Cleanup(pg.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var threads int
+	if err := srv.Provider.Storage().QueryRowContext(ctx, "SELECT current_setting('threads')").Scan(&threads); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sessions start without a database, which the user nobody has no access to.
+	connect := func(user string) *stdsql.Conn {
+		db, err := stdsql.Open("postgres", fmt.Sprintf("postgres://%s@%s?sslmode=disable", user, pg.Listener.Addr()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	nobody := connect("nobody")
+	for _, stmt := range []string{"SET threads = 1", "SET memory_limit = '1GB'", "RESET threads"} {
+		if _, err := nobody.ExecContext(ctx, stmt); err == nil {
+			t.Errorf("%s: expected the statement to be denied without SUPER", stmt)
+		}
+	}
+	if _, err := nobody.ExecContext(ctx, "SET search_path = 'main'"); err != nil {
+		t.Errorf("SET search_path: %v", err)
+	}
+	var got int
+	if err := srv.Provider.Storage().QueryRowContext(ctx, "SELECT current_setting('threads')").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != threads {
+		t.Errorf("threads = %d, want %d", got, threads)
+	}
+
+	root := connect("root")
+	if _, err := root.ExecContext(ctx, fmt.Sprintf("SET threads = %d", threads)); err != nil {
+		t.Errorf("SET threads as root: %v", err)
+	}
+}
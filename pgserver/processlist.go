@@ -0,0 +1,173 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgserver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/apecloud/myduckserver/adapter"
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/marcboeker/go-duckdb"
+)
+
+// statActivityFunction is the table function behind the pg_stat_activity view.
+const statActivityFunction = "myduck_stat_activity"
+
+// statActivityView is pg_stat_activity. The table function has no NULLs, which duckdb.SetRowValue cannot set,
+// so the view turns the empty values into NULLs. The view is temporary, as the table function exists only
+// in the server, so a view stored in the database file would break it for other DuckDB clients.
+// The filter keeps a column projected, as go-duckdb fails to scan a table function without any, e.g., for count(*).
+const statActivityView = `CREATE OR REPLACE TEMP VIEW pg_stat_activity AS
+SELECT pid, NULLIF(datname, '') AS datname, usename, client_hostname,
+	CASE WHEN state = 'active' THEN state_change END AS query_start, state_change, state,
+	NULLIF(query, '') AS query, 'client backend' AS backend_type,
+	NULLIF(duckdb_query, '') AS duckdb_query, NULLIF(progress, -1) AS progress
+FROM ` + statActivityFunction + `()
+WHERE pid IS NOT NULL`
+
+// RegisterStatActivity registers the table function behind pg_stat_activity, which lists the sessions of both ports.
+// The view itself is created on the DuckDB connection of each session of the PostgreSQL port by createStatActivity.
+func RegisterStatActivity(pool *backend.ConnectionPool, processList sql.ProcessList) error {
+	conn, err := pool.DB.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = duckdb.RegisterTableUDF(conn, statActivityFunction, duckdb.RowTableFunction{
+		BindArguments: func(map[string]any, ...any) (duckdb.RowTableSource, error) {
+			return &statActivity{processes: pool.Processes(processList)}, nil
+		},
+	})
+	return err
+}
+
+// createStatActivity creates pg_stat_activity on the DuckDB connection of the session of |ctx|.
+func createStatActivity(ctx *sql.Context) error {
+	conn, err := adapter.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, statActivityView)
+	return err
+}
+
+// statActivity is a snapshot of the sessions taken when a query on pg_stat_activity is bound.
+type statActivity struct {
+	processes []backend.ProcessInfo
+	next      int
+}
+
+var statActivityColumns = []struct {
+	name string
+	typ  duckdb.Type
+}{
+	{"pid", duckdb.TYPE_INTEGER},
+	{"datname", duckdb.TYPE_VARCHAR},
+	{"usename", duckdb.TYPE_VARCHAR},
+	{"client_hostname", duckdb.TYPE_VARCHAR},
+	{"state_change", duckdb.TYPE_TIMESTAMP},
+	{"state", duckdb.TYPE_VARCHAR},
+	{"query", duckdb.TYPE_VARCHAR},
+	{"duckdb_query", duckdb.TYPE_VARCHAR},
+	{"progress", duckdb.TYPE_DOUBLE},
+}
+
+func (a *statActivity) ColumnInfos() []duckdb.ColumnInfo {
+	infos := make([]duckdb.ColumnInfo, len(statActivityColumns))
+	for i, c := range statActivityColumns {
+		t, _ := duckdb.NewTypeInfo(c.typ)
+		infos[i] = duckdb.ColumnInfo{Name: c.name, T: t}
+	}
+	return infos
+}
+
+func (a *statActivity) Cardinality() *duckdb.CardinalityInfo {
+	return &duckdb.CardinalityInfo{Cardinality: uint(len(a.processes)), Exact: true}
+}
+
+func (a *statActivity) Init() {}
+
+func (a *statActivity) FillRow(row duckdb.Row) (bool, error) {
+	if a.next >= len(a.processes) {
+		return false, nil
+	}
+	proc := a.processes[a.next]
+	a.next++
+
+	state := "idle"
+	if proc.Command == sql.ProcessCommandQuery {
+		state = "active"
+	}
+	values := []any{
+		int32(proc.Connection), proc.Database, proc.User, proc.Host,
+		proc.StartedAt, state, proc.Query, proc.DuckSQL, proc.DuckProgress,
+	}
+	for i, v := range values {
+		// Unlike duckdb.SetRowValue, Row.SetRowValue ignores the projection of the columns.
+		if err := duckdb.SetRowValue(row, i, v); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// processListTypes are the DuckDB types of backend.ProcessListColumns.
+var processListTypes = []string{"BIGINT", "VARCHAR", "VARCHAR", "VARCHAR", "VARCHAR", "BIGINT", "VARCHAR", "VARCHAR", "VARCHAR", "DOUBLE"}
+
+// showProcessList handles SHOW [FULL] PROCESSLIST, which lists the sessions of both ports as on the MySQL port.
+func (h *ConnectionHandler) showProcessList(statement string, full bool) error {
+	ctx, err := h.duckHandler.NewContext(context.Background(), h.mysqlConn, statement)
+	if err != nil {
+		return err
+	}
+	processes := backend.VisibleProcesses(ctx, h.duckHandler.e.Analyzer.Catalog.MySQLDb,
+		ctx.Session.(*backend.Session).Processes(h.duckHandler.e.ProcessList))
+
+	rows := make([]string, len(processes))
+	for i, proc := range processes {
+		values := proc.ProcessListRow(full)
+		literals := make([]string, len(values))
+		for j, v := range values {
+			literals[j] = processListLiteral(v, processListTypes[j])
+		}
+		rows[i] = "(" + strings.Join(literals, ", ") + ")"
+	}
+	columns := make([]string, len(backend.ProcessListColumns))
+	for i, name := range backend.ProcessListColumns {
+		columns[i] = `"` + name + `"`
+	}
+	return h.query(ConvertedQuery{
+		String:       "SELECT * FROM (VALUES " + strings.Join(rows, ", ") + ") AS processlist(" + strings.Join(columns, ", ") + ")",
+		StatementTag: "SHOW",
+	})
+}
+
+// processListLiteral returns the literal of a value of SHOW PROCESSLIST, cast to |typ|.
+func processListLiteral(v any, typ string) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10) + "::" + typ
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) + "::" + typ
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'::" + typ
+	default:
+		return "NULL::" + typ
+	}
+}
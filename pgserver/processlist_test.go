@@ -0,0 +1,20 @@
+package pgserver
+
+import "testing"
+
+func TestProcessListLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		typ   string
+		want  string
+	}{
+		{int64(42), "BIGINT", "42::BIGINT"},
+		{12.5, "DOUBLE", "12.5::DOUBLE"},
+		{"SELECT 'a'", "VARCHAR", "'SELECT ''a'''::VARCHAR"},
+		{nil, "VARCHAR", "NULL::VARCHAR"},
+	} {
+		if got := processListLiteral(tt.value, tt.typ); got != tt.want {
+			t.Errorf("processListLiteral(%v, %q) = %q; want %q", tt.value, tt.typ, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pgserver
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/parser"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
+	"github.com/dolthub/go-mysql-server/sql"
+)
+
+// checkWritable rejects a query that may write while the server is read-only.
+// Queries are passed to DuckDB as-is on this port, so they are classified by
+// parsing them rather than through the plan as on the MySQL port.
+func (h *DuckHandler) checkWritable(ctx *sql.Context, query string) error {
+	// Load the privileges of the user into the session for the SUPER check.
+	h.e.Analyzer.Catalog.MySQLDb.UserActivePrivilegeSet(ctx)
+	if err := backend.CheckWritable(ctx); err != nil && !isReadOnlyQuery(query) {
+		return err
+	}
+	return nil
+}
+
+// readOnlyKeywords are the leading keywords of statements that cannot write,
+// used for queries in DuckDB syntax that the PostgreSQL parser does not accept.
+var readOnlyKeywords = map[string]bool{
+	"SELECT": true, "WITH": true, "FROM": true, "VALUES": true, "TABLE": true,
+	"SHOW": true, "DESCRIBE": true, "DESC": true, "SUMMARIZE": true, "EXPLAIN": true,
+	"SET": true, "RESET": true, "USE": true,
+	"BEGIN": true, "START": true, "COMMIT": true, "END": true, "ROLLBACK": true, "ABORT": true,
+}
+
+// sessionSettings are the settings that SET and RESET change for the session only.
+// DuckDB applies the others, such as threads and memory_limit, to the whole server even without GLOBAL.
+var sessionSettings = map[string]bool{
+	// PostgreSQL settings sent by clients
+	"application_name": true, "bytea_output": true, "client_encoding": true, "client_min_messages": true,
+	"datestyle": true, "extra_float_digits": true, "intervalstyle": true, "standard_conforming_strings": true,
+	"statement_timeout": true, "timezone": true,
+	// DuckDB settings of the connection
+	"search_path": true, "schema": true, "enable_progress_bar": true, "errors_as_json": true,
+	"explain_output": true, "ieee_floating_point_ops": true, "integer_division": true,
+	"order_by_non_integer_literal": true, "preserve_identifier_case": true,
+	"scalar_subquery_error_on_multiple_rows": true,
+}
+
+// changesGlobalSetting reports whether a SET or RESET statement changes a setting of the whole server.
+func changesGlobalSetting(stmt *tree.SetVar) bool {
+	return stmt.ResetAll || !sessionSettings[strings.ToLower(stmt.Name)]
+}
+
+// setStatementRegex matches the SET and RESET statements of a query, capturing their scope and setting.
+var setStatementRegex = regexp.MustCompile(`(?i)(?:^|;)\s*(?:RESET|SET)\s+(?:(GLOBAL|SESSION|LOCAL|VARIABLE)\s+)?(\w+)`)
+
+// setsGlobalSetting reports whether |query|, which the PostgreSQL parser does not accept, has a SET or RESET
+// statement that changes a setting of the whole server. SET VARIABLE defines a variable of the session.
+func setsGlobalSetting(query string) bool {
+	for _, m := range setStatementRegex.FindAllStringSubmatch(query, -1) {
+		scope := strings.ToUpper(m[1])
+		if scope == "GLOBAL" || scope != "VARIABLE" && !sessionSettings[strings.ToLower(m[2])] {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadOnlyQuery(query string) bool {
+	stmts, err := parser.Parse(query)
+	if err != nil {
+		if setsGlobalSetting(query) {
+			return false
+		}
+		fields := strings.FieldsFunc(query, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '(' || r == ';'
+		})
+		if len(fields) == 0 {
+			return true
+		}
+		keyword := strings.ToUpper(fields[0])
+		if keyword == "EXPLAIN" && len(fields) > 1 && strings.ToUpper(fields[1]) == "ANALYZE" {
+			return false
+		}
+		return readOnlyKeywords[keyword]
+	}
+	for _, stmt := range stmts {
+		ast := stmt.AST
+		if explain, ok := ast.(*tree.ExplainAnalyze); ok {
+			ast = explain.Statement
+		}
+		if set, ok := ast.(*tree.SetVar); ok && changesGlobalSetting(set) {
+			return false
+		}
+		if tree.CanWriteData(ast) || tree.CanModifySchema(ast) {
+			return false
+		}
+	}
+	return true
+}
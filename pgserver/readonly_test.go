@@ -0,0 +1,60 @@
+package pgserver
+
+import "testing"
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+	}{
+		{"SELECT 1", true},
+		{"select * from t where a = 1", true},
+		{"WITH c AS (SELECT 1) SELECT * FROM c", true},
+		{"FROM t", true},
+		{"SUMMARIZE t", true},
+		{"DESCRIBE t", true},
+		{"SHOW TABLES", true},
+		{"EXPLAIN SELECT 1", true},
+		{"EXPLAIN ANALYZE SELECT 1", true},
+		{"BEGIN", true},
+		{"COMMIT", true},
+		{"SET search_path = public", true},
+		{"SET SESSION search_path = public", true},
+		{"SET TIME ZONE 'UTC'", true},
+		{"SET application_name = 'psql'", true},
+		{"RESET search_path", true},
+		{"SET VARIABLE x = 1", true},
+		{"", true},
+
+		{"INSERT INTO t VALUES (1)", false},
+		{"UPDATE t SET a = 1", false},
+		{"DELETE FROM t", false},
+		{"CREATE TABLE t (a INT)", false},
+		{"DROP TABLE t", false},
+		{"ALTER TABLE t ADD COLUMN b INT", false},
+		{"TRUNCATE t", false},
+		{"EXPLAIN ANALYZE INSERT INTO t VALUES (1)", false},
+		{"SELECT 1; DELETE FROM t", false},
+		{"CREATE OR REPLACE TABLE t AS FROM u", false},
+		{"INSERT OR REPLACE INTO t VALUES (1)", false},
+		{"ATTACH 'x.db'", false},
+		{"CHECKPOINT", false},
+		{"SET GLOBAL threads = 1", false},
+		{"set global memory_limit = '1GB'", false},
+		{"RESET GLOBAL threads", false},
+		{"SELECT 1; SET GLOBAL threads = 1", false},
+		{"SET threads = 1", false},
+		{"SET threads TO 1", false},
+		{"set memory_limit = '1GB'", false},
+		{"SET enable_external_access = false", false},
+		{"RESET threads", false},
+		{"RESET ALL", false},
+		{"SELECT 1; SET threads = 1", false},
+		{"SET VARIABLE x = 1; SET memory_limit = '1GB'", false},
+	}
+	for _, tt := range tests {
+		if got := isReadOnlyQuery(tt.query); got != tt.readOnly {
+			t.Errorf("isReadOnlyQuery(%q) = %v, want %v", tt.query, got, tt.readOnly)
+		}
+	}
+}
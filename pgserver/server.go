@@ -11,19 +11,20 @@ type Server struct {
 	Listener server.ProtocolListener
 }
 
-func NewServer(srv *server.Server, host string, port int) (*Server, error) {
+func NewServer(srv *server.Server, host string, port int, opts ...ListenerOpt) (*Server, error) {
 	addr := fmt.Sprintf("%s:%d", host, port)
 	l, err := server.NewListener("tcp", addr, "")
 	if err != nil {
 		panic(err)
 	}
-	listener, err := NewListener(
+	listener, err := NewListenerWithOpts(
 		mysql.ListenerConfig{
 			Protocol: "tcp",
 			Address:  addr,
 			Listener: l,
 		},
 		srv,
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -34,3 +35,8 @@ func NewServer(srv *server.Server, host string, port int) (*Server, error) {
 func (s *Server) Start() {
 	s.Listener.Accept()
 }
+
+// Close stops accepting new connections.
+func (s *Server) Close() {
+	s.Listener.Close()
+}
@@ -0,0 +1,161 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/types"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// statementTimeoutVar keeps statement_timeout in milliseconds. The MySQL port ignores it.
+const statementTimeoutVar = "myduck_statement_timeout"
+
+// RegisterStatementTimeout registers the system variable that keeps statement_timeout, the time limit
+// of the statements of the PostgreSQL port, with |defaultTimeout| as its global value. 0 means no limit.
+func RegisterStatementTimeout(defaultTimeout time.Duration) {
+	sql.SystemVariables.AddSystemVariables([]sql.SystemVariable{
+		&sql.MysqlSystemVariable{
+			Name:              statementTimeoutVar,
+			Scope:             sql.GetMysqlScope(sql.SystemVariableScope_Both),
+			Dynamic:           true,
+			SetVarHintApplies: false,
+			Type:              types.NewSystemIntType(statementTimeoutVar, 0, math.MaxInt32, false),
+			Default:           defaultTimeout.Milliseconds(),
+		},
+	})
+}
+
+// statementTimeout returns the statement_timeout of the session of |ctx|, or 0 if its statements are not limited.
+func statementTimeout(ctx *sql.Context) time.Duration {
+	v, err := ctx.GetSessionVariable(ctx, statementTimeoutVar)
+	if err != nil {
+		return 0
+	}
+	ms, _ := v.(int64)
+	return time.Duration(ms) * time.Millisecond
+}
+
+// setStatementTimeout handles SET statement_timeout and RESET statement_timeout.
+func (h *ConnectionHandler) setStatementTimeout(query ConvertedQuery, stmt *tree.SetVar) error {
+	ctx, err := h.duckHandler.NewContext(context.Background(), h.mysqlConn, query.String)
+	if err != nil {
+		return err
+	}
+
+	var ms any
+	if stmt.Reset || len(stmt.Values) == 1 && isDefaultVal(stmt.Values[0]) {
+		_, ms, _ = sql.SystemVariables.GetGlobal(statementTimeoutVar)
+	} else {
+		if len(stmt.Values) != 1 {
+			return fmt.Errorf("SET statement_timeout takes only one argument")
+		}
+		var value string
+		switch v := stmt.Values[0].(type) {
+		case *tree.NumVal:
+			value = v.String()
+		case *tree.StrVal:
+			value = v.RawString()
+		default:
+			return fmt.Errorf(`invalid value for parameter "statement_timeout": %s`, tree.AsString(v))
+		}
+		timeout, err := parseStatementTimeout(value)
+		if err != nil {
+			return err
+		}
+		ms = timeout.Milliseconds()
+	}
+	if err := ctx.SetSessionVariable(ctx, statementTimeoutVar, ms); err != nil {
+		return err
+	}
+	return h.send(&pgproto3.CommandComplete{
+		CommandTag: []byte(query.StatementTag),
+	})
+}
+
+func isDefaultVal(expr tree.Expr) bool {
+	_, ok := expr.(tree.DefaultVal)
+	return ok
+}
+
+// showStatementTimeout handles SHOW statement_timeout.
+func (h *ConnectionHandler) showStatementTimeout(query ConvertedQuery) error {
+	ctx, err := h.duckHandler.NewContext(context.Background(), h.mysqlConn, query.String)
+	if err != nil {
+		return err
+	}
+	return h.query(ConvertedQuery{
+		String:       fmt.Sprintf("SELECT '%s' AS statement_timeout", formatStatementTimeout(statementTimeout(ctx))),
+		StatementTag: "SHOW",
+	})
+}
+
+var statementTimeoutPattern = regexp.MustCompile(`^\s*(\d+(?:\.\d*)?)\s*(us|ms|s|min|h|d)?\s*$`)
+
+var statementTimeoutUnits = map[string]time.Duration{
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// parseStatementTimeout parses a value of statement_timeout, which is in milliseconds unless a unit is given.
+// As in PostgreSQL, it is rounded to milliseconds and cannot exceed INT_MAX milliseconds.
+func parseStatementTimeout(value string) (time.Duration, error) {
+	m := statementTimeoutPattern.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf(`invalid value for parameter "statement_timeout": "%s"`, value)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid value for parameter "statement_timeout": "%s"`, value)
+	}
+	unit := time.Millisecond
+	if m[2] != "" {
+		unit = statementTimeoutUnits[m[2]]
+	}
+	ms := math.Round(n * float64(unit) / float64(time.Millisecond))
+	if ms > math.MaxInt32 {
+		return 0, fmt.Errorf(`%s ms is outside the valid range for parameter "statement_timeout" (0 .. %d)`, strconv.FormatFloat(ms, 'f', -1, 64), math.MaxInt32)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// formatStatementTimeout formats statement_timeout as SHOW does in PostgreSQL, in the largest unit
+// that represents it exactly.
+func formatStatementTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "0"
+	}
+	for _, unit := range []struct {
+		name string
+		d    time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"min", time.Minute}, {"s", time.Second}} {
+		if timeout%unit.d == 0 {
+			return strconv.FormatInt(int64(timeout/unit.d), 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(timeout.Milliseconds(), 10) + "ms"
+}
@@ -0,0 +1,136 @@
+package pgserver
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/testutil"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestParseStatementTimeout(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"0":       0,
+		"5000":    5 * time.Second,
+		"1.5":     2 * time.Millisecond,
+		"250ms":   250 * time.Millisecond,
+		" 30 s ":  30 * time.Second,
+		"2min":    2 * time.Minute,
+		"1h":      time.Hour,
+		"1d":      24 * time.Hour,
+		"1500us":  2 * time.Millisecond,
+		"0.5s":    500 * time.Millisecond,
+		"2147483": 2147483 * time.Millisecond,
+	} {
+		got, err := parseStatementTimeout(value)
+		if err != nil || got != want {
+			t.Errorf("parseStatementTimeout(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "-1", "5 sec", "1e3", "25d"} {
+		if got, err := parseStatementTimeout(value); err == nil {
+			t.Errorf("parseStatementTimeout(%q) = %v; want an error", value, got)
+		}
+	}
+}
+
+func TestFormatStatementTimeout(t *testing.T) {
+	for timeout, want := range map[time.Duration]string{
+		0:                       "0",
+		1500 * time.Millisecond: "1500ms",
+		30 * time.Second:        "30s",
+		90 * time.Minute:        "90min",
+		2 * time.Hour:           "2h",
+		48 * time.Hour:          "2d",
+	} {
+		if got := formatStatementTimeout(timeout); got != want {
+			t.Errorf("formatStatementTimeout(%v) = %q; want %q", timeout, got, want)
+		}
+	}
+}
+
+// slowQuery runs for minutes in DuckDB unless it is interrupted.
+const slowQuery = "SELECT SUM(a.n * b.n) FROM db1.big AS a, db1.big AS b"
+
+// TestStatementTimeoutEnforced checks that statement_timeout interrupts the statements of the PostgreSQL port,
+// and that max_execution_time, which bounds the SELECT statements of the MySQL port, does not apply to them.
+func TestStatementTimeoutEnforced(t *testing.T) {
+	backend.RegisterMaxExecutionTime(0)
+	RegisterStatementTimeout(0)
+
+	srv := testutil.NewServer(t)
+	if _, err := srv.Provider.Storage().Exec("CREATE TABLE db1.big AS SELECT range AS n FROM range(200000)"); err != nil {
+		t.Fatal(err)
+	}
+	// The sessions of the PostgreSQL port create pg_stat_activity when they log in.
+	if err := RegisterStatActivity(srv.Pool, srv.Engine.ProcessList); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	pg, err := NewServer(srv.Server, "127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go pg.Start()
+	t.Cleanup(pg.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	my, err := stdsql.Open("mysql", "root@tcp("+srv.Listener.Addr().String()+")/db1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer my.Close()
+	myConn, err := my.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer myConn.Close()
+	if _, err := myConn.ExecContext(ctx, "SET max_execution_time = 200"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = myConn.ExecContext(ctx, slowQuery)
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) || myErr.Number != 3024 {
+		t.Errorf("expected ER_QUERY_TIMEOUT on the MySQL port, got %v", err)
+	}
+	// The global max_execution_time is the default of the new sessions of the MySQL port only.
+	if _, err := myConn.ExecContext(ctx, "SET GLOBAL max_execution_time = 1"); err != nil {
+		t.Fatal(err)
+	}
+	defer myConn.ExecContext(context.Background(), "SET GLOBAL max_execution_time = 0")
+
+	db, err := stdsql.Open("postgres", fmt.Sprintf("postgres://root@%s/db1?sslmode=disable", pg.Listener.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var n int64
+	if err := conn.QueryRowContext(ctx, "SELECT SUM(a.n) FROM db1.big AS a, db1.big AS b WHERE b.n < 100").Scan(&n); err != nil {
+		t.Errorf("max_execution_time applies to the PostgreSQL port: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = '200ms'"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = conn.ExecContext(ctx, slowQuery)
+	var pgErr *pq.Error
+	if !errors.As(err, &pgErr) {
+		t.Errorf("expected the statement to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("the statement was interrupted after %v", elapsed)
+	}
+}
@@ -1,16 +1,168 @@
 package plugin
 
-import "github.com/dolthub/go-mysql-server/sql/mysql_db"
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"sync"
 
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+// AuthPlugins are the authentication plugins other than mysql_native_password,
+// which go-mysql-server verifies by itself against the SHA1 hash of the account.
+// They check the passwords received in clear text, e.g., over HTTP basic authentication
+// or in the full authentication of caching_sha2_password; the MySQL port goes through AuthServer.
 var AuthPlugins = map[string]mysql_db.PlaintextAuthPlugin{
-	"caching_sha2_password": &NoopPlaintextPlugin{},
+	mysql.CachingSha2Password: cachingSha2,
+}
+
+var cachingSha2 = &CachingSha2Plugin{}
+
+// CachingSha2Plugin verifies the passwords of caching_sha2_password accounts
+// against their SHA256-crypt authentication strings.
+//
+// A successful verification is cached in memory until the password of the account changes,
+// as in MySQL: subsequent logins of the account skip the expensive SHA256-crypt computation,
+// and the fast authentication of AuthServer checks the scramble of the client against the cache.
+type CachingSha2Plugin struct {
+	cache sync.Map // "user@host" -> cachedCredential
 }
 
-// NoopPlaintextPlugin is used to authenticate plaintext user plugins
-type NoopPlaintextPlugin struct{}
+var _ mysql_db.PlaintextAuthPlugin = &CachingSha2Plugin{}
+
+type cachedCredential struct {
+	authString string   // the authentication string that the password was verified against
+	digest     [32]byte // SHA256(SHA256(password))
+}
 
-var _ mysql_db.PlaintextAuthPlugin = &NoopPlaintextPlugin{}
+// Authenticate implements mysql_db.PlaintextAuthPlugin.
+func (p *CachingSha2Plugin) Authenticate(db *mysql_db.MySQLDb, user string, userEntry *mysql_db.User, pass string) (bool, error) {
+	authString := userEntry.Password
+	if authString == "" {
+		return pass == "", nil
+	}
+	if pass == "" {
+		return false, nil
+	}
+
+	digest := doubleSha256(pass)
+	if cached, ok := p.cached(userEntry); ok {
+		return subtle.ConstantTimeCompare(cached[:], digest[:]) == 1, nil
+	}
+
+	var ok bool
+	if isCachingSha2AuthString(authString) {
+		var err error
+		if ok, err = checkCachingSha2Password(pass, authString); err != nil {
+			return false, err
+		}
+	} else {
+		// Accounts created before the passwords were hashed store them in clear text.
+		// ALTER USER ... IDENTIFIED WITH caching_sha2_password BY ... replaces them with a hash.
+		ok = subtle.ConstantTimeCompare([]byte(pass), []byte(authString)) == 1
+	}
+	if ok {
+		p.cache.Store(cacheKey(userEntry), cachedCredential{authString: authString, digest: digest})
+	}
+	return ok, nil
+}
+
+// cached returns SHA256(SHA256(password)) of the account if its current password has been verified.
+func (p *CachingSha2Plugin) cached(userEntry *mysql_db.User) ([32]byte, bool) {
+	key := cacheKey(userEntry)
+	v, ok := p.cache.Load(key)
+	if !ok {
+		return [32]byte{}, false
+	}
+	cached := v.(cachedCredential)
+	if cached.authString != userEntry.Password {
+		p.cache.Delete(key)
+		return [32]byte{}, false
+	}
+	return cached.digest, true
+}
+
+// checkScramble reports whether |scramble| is the fast-authentication response of caching_sha2_password
+// to |nonce| for the cached password of the account, i.e., SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce).
+// It returns false if the password of the account is not cached.
+func (p *CachingSha2Plugin) checkScramble(userEntry *mysql_db.User, nonce, scramble []byte) bool {
+	digest, ok := p.cached(userEntry)
+	if !ok || len(scramble) != sha256.Size {
+		return false
+	}
+	h := sha256.New()
+	h.Write(digest[:])
+	h.Write(nonce)
+	stage1 := h.Sum(nil)
+	for i := range stage1 {
+		stage1[i] ^= scramble[i]
+	}
+	computed := sha256.Sum256(stage1)
+	return subtle.ConstantTimeCompare(computed[:], digest[:]) == 1
+}
+
+func cacheKey(userEntry *mysql_db.User) string {
+	return userEntry.User + "@" + userEntry.Host
+}
+
+func doubleSha256(s string) [32]byte {
+	h := sha256.Sum256([]byte(s))
+	return sha256.Sum256(h[:])
+}
+
+func isCachingSha2AuthString(s string) bool {
+	return len(s) == len(cachingSha2Prefix)+4+cachingSha2SaltLength+cachingSha2DigestLen && s[:len(cachingSha2Prefix)] == cachingSha2Prefix
+}
+
+// HashPasswords returns |n| with the clear-text passwords of caching_sha2_password accounts
+// in CREATE USER and ALTER USER replaced by their authentication strings.
+// go-mysql-server would otherwise store them as given.
+// Other nodes are returned unchanged.
+func HashPasswords(n sql.Node) (sql.Node, error) {
+	switch n := n.(type) {
+	case *plan.CreateUser:
+		users := slices.Clone(n.Users)
+		for i := range users {
+			if err := hashPassword(&users[i]); err != nil {
+				return nil, err
+			}
+		}
+		nn := *n
+		nn.Users = users
+		return &nn, nil
+	case *plan.AlterUser:
+		nn := *n
+		if err := hashPassword(&nn.User); err != nil {
+			return nil, err
+		}
+		return &nn, nil
+	default:
+		return n, nil
+	}
+}
 
-func (p *NoopPlaintextPlugin) Authenticate(db *mysql_db.MySQLDb, user string, userEntry *mysql_db.User, pass string) (bool, error) {
-	return true, nil
+func hashPassword(user *plan.AuthenticatedUser) error {
+	if user.Auth1 == nil || user.Auth1.Plugin() != mysql.CachingSha2Password {
+		return nil
+	}
+	if user.Identity != "" {
+		// IDENTIFIED WITH caching_sha2_password AS '<authentication string>'
+		if !isCachingSha2AuthString(user.Identity) {
+			return fmt.Errorf("invalid %s authentication string", mysql.CachingSha2Password)
+		}
+		user.Auth1 = plan.NewOtherAuthentication(user.Identity, mysql.CachingSha2Password)
+		user.Identity = ""
+		return nil
+	}
+	authString, err := HashCachingSha2Password(user.Auth1.Password())
+	if err != nil {
+		return err
+	}
+	user.Auth1 = plan.NewOtherAuthentication(authString, mysql.CachingSha2Password)
+	return nil
 }
@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+// The first bytes of the packets of the caching_sha2_password exchange.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+const (
+	authMoreData              = 0x01
+	requestPublicKey          = 0x02
+	fastAuthSuccess           = 0x03
+	performFullAuthentication = 0x04
+)
+
+// AuthServer authenticates the clients of the MySQL port against the accounts of a MySQLDb.
+// caching_sha2_password accounts go through the exchange of MySQL:
+//
+//   - The fast authentication checks the scramble of the password with a nonce against
+//     the password cached by a previous login of the account.
+//   - Otherwise, the full authentication receives the password in clear text over TLS or a Unix socket,
+//     or encrypted with the RSA key of the server, and checks it against the authentication string.
+//
+// The other accounts are authenticated by the MySQLDb.
+type AuthServer struct {
+	*mysql_db.MySQLDb
+	plugin *CachingSha2Plugin
+	nonces sync.Map // remote address -> the nonce sent to the client
+
+	// allowClearText allows the other accounts to send their passwords in clear text without TLS.
+	allowClearText bool
+
+	keyOnce sync.Once
+	key     *rsa.PrivateKey
+	pubKey  []byte // the public key in PEM
+	keyErr  error
+}
+
+var _ mysql.AuthServer = &AuthServer{}
+
+// NewAuthServer returns an AuthServer for the accounts of |db|.
+func NewAuthServer(db *mysql_db.MySQLDb, allowClearText bool) *AuthServer {
+	return &AuthServer{MySQLDb: db, plugin: cachingSha2, allowClearText: allowClearText}
+}
+
+var registerOnce sync.Once
+
+// RegisterAuthServer makes the MySQL listeners authenticate their clients with AuthServer.
+func RegisterAuthServer() {
+	registerOnce.Do(func() {
+		f := server.DefaultProtocolListenerFunc
+		server.DefaultProtocolListenerFunc = func(cfg mysql.ListenerConfig) (server.ProtocolListener, error) {
+			if db, ok := cfg.AuthServer.(*mysql_db.MySQLDb); ok {
+				cfg.AuthServer = NewAuthServer(db, cfg.AllowClearTextWithoutTLS)
+				// The RSA exchange of caching_sha2_password needs no TLS, so Negotiate checks the clear-text passwords instead.
+				cfg.AllowClearTextWithoutTLS = true
+			}
+			return f(cfg)
+		}
+	})
+}
+
+// AuthMethod implements mysql.AuthServer.
+func (s *AuthServer) AuthMethod(user, addr string) (string, error) {
+	method, err := s.MySQLDb.AuthMethod(user, addr)
+	if err != nil || method == mysql.MysqlNativePassword {
+		return method, err
+	}
+	if account := s.account(user, addr); account == nil || account.Plugin != mysql.CachingSha2Password {
+		return method, nil
+	}
+
+	nonce, err := mysql.NewSalt()
+	if err != nil {
+		return "", err
+	}
+	// The remote address of a Unix socket is not unique; such clients go through the full authentication.
+	if !isLocal(addr) {
+		s.nonces.Store(addr, nonce)
+	}
+	// The listener sends the name of the method, terminated by a NUL, as the auth switch request.
+	// It has no way to send the nonce that follows the name, so the nonce is made a part of it.
+	return mysql.CachingSha2Password + "\x00" + string(nonce), nil
+}
+
+// Negotiate implements mysql.AuthServer.
+func (s *AuthServer) Negotiate(c *mysql.Conn, user string, addr net.Addr) (mysql.Getter, error) {
+	v, _ := s.nonces.LoadAndDelete(addr.String())
+	nonce, _ := v.([]byte)
+
+	account := s.account(user, addr.String())
+	if account == nil || account.Plugin != mysql.CachingSha2Password {
+		if !s.allowClearText && !isSecure(c, addr) {
+			return nil, mysql.NewSQLError(mysql.CRServerHandshakeErr, mysql.SSUnknownSQLState, "Cannot use clear text authentication over non-SSL connections.")
+		}
+		return s.MySQLDb.Negotiate(c, user, addr)
+	}
+	denied := mysql.NewSQLError(mysql.ERAccessDeniedError, mysql.SSAccessDeniedError, "Access denied for user '%v'", user)
+	if account.Locked {
+		return nil, denied
+	}
+	connUser := sql.MysqlConnectionUser{User: account.User, Host: account.Host}
+
+	scramble, err := c.ReadPacket(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	// Clients send an empty scramble, or a single NUL, for an empty password.
+	if len(bytes.TrimRight(scramble, "\x00")) == 0 || account.Password == "" {
+		if len(bytes.TrimRight(scramble, "\x00")) == 0 && account.Password == "" {
+			return connUser, nil
+		}
+		return nil, denied
+	}
+
+	// go-sql-driver/mysql scrambles the password with the NUL that terminates the nonce.
+	if nonce != nil && (s.plugin.checkScramble(account, nonce, scramble) || s.plugin.checkScramble(account, append(nonce, 0), scramble)) {
+		if err := writeAuthMoreData(c, []byte{fastAuthSuccess}); err != nil {
+			return nil, err
+		}
+		return connUser, nil
+	}
+
+	if err := writeAuthMoreData(c, []byte{performFullAuthentication}); err != nil {
+		return nil, err
+	}
+	password, err := s.readPassword(c, addr, nonce)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.plugin.Authenticate(s.MySQLDb, user, account, password)
+	if err != nil {
+		return nil, mysql.NewSQLError(mysql.ERAccessDeniedError, mysql.SSAccessDeniedError, "Access denied for user '%v': %v", user, err)
+	}
+	if !ok {
+		return nil, denied
+	}
+	return connUser, nil
+}
+
+// readPassword reads the password of the full authentication, which is sent in clear text over a secure connection,
+// and otherwise XORed with the nonce and encrypted with the public key of the server, which the client may ask for.
+func (s *AuthServer) readPassword(c *mysql.Conn, addr net.Addr, nonce []byte) (string, error) {
+	data, err := c.ReadPacket(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if isSecure(c, addr) {
+		return string(bytes.TrimSuffix(data, []byte{0})), nil
+	}
+
+	key, pubKey, err := s.rsaKey()
+	if err != nil {
+		return "", err
+	}
+	if nonce == nil {
+		return "", errors.New("no nonce was sent to the client")
+	}
+	if len(data) == 1 && data[0] == requestPublicKey {
+		if err := writeAuthMoreData(c, pubKey); err != nil {
+			return "", err
+		}
+		if data, err = c.ReadPacket(context.Background()); err != nil {
+			return "", err
+		}
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), nil, key, data, nil)
+	if err != nil {
+		return "", mysql.NewSQLError(mysql.ERAccessDeniedError, mysql.SSAccessDeniedError, "Access denied for user '%v'", c.User)
+	}
+	for i := range plain {
+		plain[i] ^= nonce[i%len(nonce)]
+	}
+	return string(bytes.TrimSuffix(plain, []byte{0})), nil
+}
+
+// rsaKey returns the RSA key of the server, which is generated on first use as MySQL does by default.
+func (s *AuthServer) rsaKey() (*rsa.PrivateKey, []byte, error) {
+	s.keyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			s.keyErr = err
+			return
+		}
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			s.keyErr = err
+			return
+		}
+		s.key, s.pubKey = key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	})
+	return s.key, s.pubKey, s.keyErr
+}
+
+func (s *AuthServer) account(user, addr string) *mysql_db.User {
+	host := "localhost"
+	if !isLocal(addr) {
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			host = h
+		} else {
+			host = addr
+		}
+	}
+	rd := s.MySQLDb.Reader()
+	defer rd.Close()
+	return s.MySQLDb.GetUser(rd, user, host, false)
+}
+
+// isLocal reports whether |addr| is the remote address of a Unix socket, as the MySQLDb tells.
+func isLocal(addr string) bool {
+	return addr == "" || addr == "@"
+}
+
+// isSecure reports whether a password may be sent in clear text over the connection, as in MySQL.
+func isSecure(c *mysql.Conn, addr net.Addr) bool {
+	return c.Capabilities&mysql.CapabilityClientSSL != 0 || addr.Network() == "unix"
+}
+
+// sequenceOffset is the offset of the packet sequence number in mysql.Conn, or 0 if it is not found.
+var sequenceOffset = func() uintptr {
+	f, ok := reflect.TypeOf(mysql.Conn{}).FieldByName("sequence")
+	if !ok || f.Type.Kind() != reflect.Uint8 {
+		return 0
+	}
+	return f.Offset
+}()
+
+// writeAuthMoreData writes an AuthMoreData packet with |data| to the client during the authentication.
+// vitess does not export a way to write a packet, so it is written to c.Conn, which is not buffered
+// during the authentication, with the sequence number of the connection, which is then advanced as vitess does.
+func writeAuthMoreData(c *mysql.Conn, data []byte) error {
+	if sequenceOffset == 0 {
+		return errors.New("caching_sha2_password is not supported by the MySQL protocol layer")
+	}
+	sequence := (*uint8)(unsafe.Add(unsafe.Pointer(c), sequenceOffset))
+	length := len(data) + 1
+	packet := make([]byte, 0, 4+length)
+	packet = append(packet, byte(length), byte(length>>8), byte(length>>16), *sequence, authMoreData)
+	packet = append(packet, data...)
+	if _, err := c.Conn.Write(packet); err != nil {
+		return err
+	}
+	*sequence++
+	return nil
+}
@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	stdsql "database/sql"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/vitess/go/mysql"
+	driver "github.com/go-sql-driver/mysql"
+)
+
+func TestCheckScramble(t *testing.T) {
+	authString, err := HashCachingSha2Password("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &mysql_db.User{User: "alice", Host: "%", Plugin: mysql.CachingSha2Password, Password: authString}
+	nonce, err := mysql.NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plugin := &CachingSha2Plugin{}
+	if plugin.checkScramble(user, nonce, mysql.ScrambleCachingSha2Password(nonce, []byte("secret"))) {
+		t.Error("the scramble is accepted before the password is cached")
+	}
+	if ok, err := plugin.Authenticate(nil, user.User, user, "secret"); !ok || err != nil {
+		t.Fatalf("Authenticate() = %v, %v", ok, err)
+	}
+	if !plugin.checkScramble(user, nonce, mysql.ScrambleCachingSha2Password(nonce, []byte("secret"))) {
+		t.Error("the scramble of the correct password is rejected")
+	}
+	if plugin.checkScramble(user, nonce, mysql.ScrambleCachingSha2Password(nonce, []byte("wrong"))) {
+		t.Error("the scramble of a wrong password is accepted")
+	}
+	other, _ := mysql.NewSalt()
+	if plugin.checkScramble(user, other, mysql.ScrambleCachingSha2Password(nonce, []byte("secret"))) {
+		t.Error("the scramble with another nonce is accepted")
+	}
+
+	// Changing the password invalidates the cache.
+	if user.Password, err = HashCachingSha2Password("another"); err != nil {
+		t.Fatal(err)
+	}
+	if plugin.checkScramble(user, nonce, mysql.ScrambleCachingSha2Password(nonce, []byte("secret"))) {
+		t.Error("the scramble of the old password is accepted")
+	}
+}
+
+func TestAuthServer(t *testing.T) {
+	auth, addr := startAuthServer(t)
+
+	tests := []struct {
+		user, password string
+		ok             bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "wrong", false},
+		{"alice", "", false},
+		{"bob", "", true},
+		{"bob", "secret", false},
+	}
+
+	// The full authentication receives the password in clear text over TLS,
+	// and encrypted with the public key of the server otherwise.
+	for _, tls := range []string{"true", "false"} {
+		for _, tt := range tests {
+			t.Run("full/tls="+tls+"/"+tt.user+"/"+tt.password, func(t *testing.T) {
+				auth.plugin = &CachingSha2Plugin{}
+				checkLogin(t, addr, tls, tt.user, tt.password, tt.ok, "Access denied")
+			})
+		}
+	}
+
+	// With the password cached, the fast authentication succeeds without the RSA key or TLS.
+	auth.plugin = &CachingSha2Plugin{}
+	checkLogin(t, addr, "true", "alice", "secret", true, "")
+	auth.keyOnce.Do(func() {})
+	auth.key, auth.pubKey, auth.keyErr = nil, nil, errors.New("RSA is disabled")
+	for _, tt := range tests {
+		t.Run("fast/"+tt.user+"/"+tt.password, func(t *testing.T) {
+			// A wrong password fails the fast authentication and falls back to the full authentication.
+			checkLogin(t, addr, "false", tt.user, tt.password, tt.ok, "")
+		})
+	}
+	// Without the cache, the full authentication is performed, which fails without the RSA key.
+	auth.plugin = &CachingSha2Plugin{}
+	checkLogin(t, addr, "false", "alice", "secret", false, "")
+}
+
+// checkLogin checks that |user| can log in with |password| if |ok|, and otherwise fails with an error containing |reason|.
+func checkLogin(t *testing.T, addr, tls, user, password string, ok bool, reason string) {
+	t.Helper()
+	cfg := driver.NewConfig()
+	cfg.User, cfg.Passwd, cfg.Net, cfg.Addr = user, password, "tcp", addr
+	if tls == "true" {
+		cfg.TLSConfig = "skip-verify"
+	}
+	connector, err := driver.NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := stdsql.OpenDB(connector)
+	defer db.Close()
+
+	err = db.Ping()
+	switch {
+	case ok && err != nil:
+		t.Errorf("%s failed to log in with %q: %v", user, password, err)
+	case !ok && err == nil:
+		t.Errorf("%s logged in with %q", user, password)
+	case !ok && !strings.Contains(err.Error(), reason):
+		t.Errorf("%s failed to log in with %q: %v; expected %q", user, password, err, reason)
+	}
+}
+
+// startAuthServer starts a MySQL server with the caching_sha2_password accounts alice, with the password "secret",
+// and bob, without password.
+func startAuthServer(t *testing.T) (*AuthServer, string) {
+	provider := memory.NewDBProvider()
+	engine := sqle.NewDefault(provider)
+	db := engine.Analyzer.Catalog.MySQLDb
+	db.SetPlugins(AuthPlugins)
+	authString, err := HashCachingSha2Password("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := db.Editor()
+	ed.PutUser(&mysql_db.User{User: "alice", Host: "%", PrivilegeSet: mysql_db.NewPrivilegeSet(), Plugin: mysql.CachingSha2Password, Password: authString})
+	ed.PutUser(&mysql_db.User{User: "bob", Host: "%", PrivilegeSet: mysql_db.NewPrivilegeSet(), Plugin: mysql.CachingSha2Password})
+	ed.Close()
+	db.SetEnabled(true)
+
+	auth := NewAuthServer(db, false)
+	listen := server.DefaultProtocolListenerFunc
+	server.DefaultProtocolListenerFunc = func(cfg mysql.ListenerConfig) (server.ProtocolListener, error) {
+		cfg.AuthServer = auth
+		cfg.AllowClearTextWithoutTLS = true
+		return listen(cfg)
+	}
+	defer func() { server.DefaultProtocolListenerFunc = listen }()
+
+	cfg := server.Config{Protocol: "tcp", Address: "127.0.0.1:0", TLSConfig: newTLSConfig(t)}
+	sessionBuilder := func(ctx context.Context, c *mysql.Conn, addr string) (sql.Session, error) {
+		user, _ := c.UserData.(sql.MysqlConnectionUser)
+		client := sql.Client{Address: user.Host, User: user.User, Capabilities: c.Capabilities}
+		return memory.NewSession(sql.NewBaseSessionWithClientServer(addr, client, c.ConnectionID), provider), nil
+	}
+	srv, err := server.NewServer(cfg, engine, sessionBuilder, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	t.Cleanup(func() { srv.Close() })
+	return auth, srv.Listener.Addr().String()
+}
+
+func newTLSConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+func TestSha256Crypt(t *testing.T) {
+	// Test vectors from https://www.akkadia.org/drepper/SHA-crypt.txt
+	tests := []struct {
+		password, salt string
+		rounds         int
+		expected       string
+	}{
+		{"Hello world!", "saltstring", 5000, "5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"Hello world!", "saltstringsaltst", 10000, "3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		{"we have a short salt string but not a short password", "short", 77777, "JiO1O3ZpDAxGJeaDIuqCoEFysAe1mZNJRs3pw0KQRd/"},
+		{"a very much longer text to encrypt.  This one even stretches over morethan one line.", "anotherlongsalts", 1400, "Rx.j8H.h8HjEDGomFU8bDkXm3XIUnzyxf12oP84Bnq1"},
+		{"the minimum number is still observed", "roundstoolow", 1000, "yfvwcWrQ8l/K0DAWyuPMDNHpIVlTQebY9l/gL972bIC"},
+	}
+	for _, tt := range tests {
+		if got := sha256Crypt([]byte(tt.password), []byte(tt.salt), tt.rounds); got != tt.expected {
+			t.Errorf("sha256Crypt(%q, %q, %d) = %q, expected %q", tt.password, tt.salt, tt.rounds, got, tt.expected)
+		}
+	}
+}
+
+func TestCachingSha2Plugin(t *testing.T) {
+	authString, err := HashCachingSha2Password("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isCachingSha2AuthString(authString) {
+		t.Fatalf("unexpected authentication string %q", authString)
+	}
+
+	plugin := &CachingSha2Plugin{}
+	withPassword := &mysql_db.User{User: "alice", Host: "%", Plugin: mysql.CachingSha2Password, Password: authString}
+	withoutPassword := &mysql_db.User{User: "bob", Host: "%", Plugin: mysql.CachingSha2Password}
+	legacy := &mysql_db.User{User: "carol", Host: "%", Plugin: mysql.CachingSha2Password, Password: "secret"}
+
+	tests := []struct {
+		name     string
+		user     *mysql_db.User
+		password string
+		expected bool
+	}{
+		{"correct password", withPassword, "secret", true},
+		{"correct password from the cache", withPassword, "secret", true},
+		{"wrong password", withPassword, "Secret", false},
+		{"empty password", withPassword, "", false},
+		{"no password set", withoutPassword, "", true},
+		{"password given but none set", withoutPassword, "secret", false},
+		{"clear-text authentication string", legacy, "secret", true},
+		{"clear-text authentication string, wrong password", legacy, "wrong", false},
+	}
+	for _, tt := range tests {
+		ok, err := plugin.Authenticate(nil, tt.user.User, tt.user, tt.password)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if ok != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, ok)
+		}
+	}
+
+	// A changed password invalidates the cached credential.
+	changed, err := HashCachingSha2Password("changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withPassword.Password = changed
+	if ok, _ := plugin.Authenticate(nil, "alice", withPassword, "secret"); ok {
+		t.Error("the old password is still accepted after the password changed")
+	}
+	if ok, _ := plugin.Authenticate(nil, "alice", withPassword, "changed"); !ok {
+		t.Error("the new password is rejected")
+	}
+}
+
+func TestNativePassword(t *testing.T) {
+	db := mysql_db.CreateEmptyMySQLDb()
+	db.SetPlugins(AuthPlugins)
+	ed := db.Editor()
+	db.AddSuperUser(ed, "alice", "%", "secret")
+	db.AddSuperUser(ed, "bob", "%", "")
+	ed.Close()
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3306}
+	salt, err := mysql.NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		expected bool
+	}{
+		{"correct password", "alice", "secret", true},
+		{"wrong password", "alice", "Secret", false},
+		{"empty password", "alice", "", false},
+		{"no password set", "bob", "", true},
+		{"password given but none set", "bob", "secret", false},
+	}
+	for _, tt := range tests {
+		if method, err := db.AuthMethod(tt.user, addr.String()); err != nil || method != mysql.MysqlNativePassword {
+			t.Fatalf("%s: unexpected auth method %q (%v)", tt.name, method, err)
+		}
+		scramble := mysql.ScrambleMysqlNativePassword(salt, []byte(tt.password))
+		_, err := db.ValidateHash(salt, tt.user, scramble, addr)
+		if ok := err == nil; ok != tt.expected {
+			t.Errorf("%s: expected %v, got error %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestHashPasswords(t *testing.T) {
+	n := &plan.CreateUser{Users: []plan.AuthenticatedUser{
+		{UserName: plan.UserName{Name: "alice"}, Auth1: plan.NewOtherAuthentication("secret", mysql.CachingSha2Password)},
+		{UserName: plan.UserName{Name: "bob"}, Auth1: plan.NewDefaultAuthentication("secret")},
+	}}
+	hashed, err := HashPasswords(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := hashed.(*plan.CreateUser).Users
+	if authString := users[0].Auth1.Password(); !isCachingSha2AuthString(authString) {
+		t.Errorf("unexpected authentication string %q", authString)
+	} else if ok, _ := checkCachingSha2Password("secret", authString); !ok {
+		t.Error("the authentication string does not match the password")
+	}
+	if users[1].Auth1.Password() != plan.NewDefaultAuthentication("secret").Password() {
+		t.Error("the mysql_native_password account was changed")
+	}
+	if n.Users[0].Auth1.Password() != "secret" {
+		t.Error("the original node was modified")
+	}
+
+	invalid := &plan.AlterUser{User: plan.AuthenticatedUser{
+		Auth1:    plan.NewOtherAuthentication("", mysql.CachingSha2Password),
+		Identity: "not a hash",
+	}}
+	if _, err := HashPasswords(invalid); err == nil {
+		t.Error("expected an error for an invalid authentication string")
+	}
+}
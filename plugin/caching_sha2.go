@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The authentication string of a caching_sha2_password account has the same format as in MySQL:
+// "$A$" + 3-digit iteration count in thousands + "$" + 20-byte salt + 43-byte SHA256-crypt digest.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+const (
+	cachingSha2Prefix     = "$A$"
+	cachingSha2Iterations = 5 // in thousands, the default of MySQL
+	cachingSha2SaltLength = 20
+	cachingSha2DigestLen  = 43
+)
+
+// cryptAlphabet is the base64 alphabet of crypt(3).
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// HashCachingSha2Password returns the authentication string of |password| for caching_sha2_password.
+// An empty password has an empty authentication string, as in MySQL.
+func HashCachingSha2Password(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	salt := make([]byte, cachingSha2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	// The salt must not contain '$' or NUL; map it onto the crypt alphabet.
+	for i, b := range salt {
+		salt[i] = cryptAlphabet[b&0x3f]
+	}
+	return fmt.Sprintf("%s%03d$%s%s", cachingSha2Prefix, cachingSha2Iterations, salt,
+		sha256Crypt([]byte(password), salt, cachingSha2Iterations*1000)), nil
+}
+
+// checkCachingSha2Password verifies |password| against the authentication string |authString|.
+func checkCachingSha2Password(password, authString string) (bool, error) {
+	rest, ok := strings.CutPrefix(authString, cachingSha2Prefix)
+	if !ok || len(rest) != 4+cachingSha2SaltLength+cachingSha2DigestLen || rest[3] != '$' {
+		return false, fmt.Errorf("invalid caching_sha2_password authentication string")
+	}
+	iterations, err := strconv.Atoi(rest[:3])
+	if err != nil || iterations <= 0 {
+		return false, fmt.Errorf("invalid iteration count in caching_sha2_password authentication string")
+	}
+	salt := rest[4 : 4+cachingSha2SaltLength]
+	digest := rest[4+cachingSha2SaltLength:]
+	computed := sha256Crypt([]byte(password), []byte(salt), iterations*1000)
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(digest)) == 1, nil
+}
+
+// sha256Crypt implements the SHA-256 variant of Ulrich Drepper's SHA-crypt and returns the encoded digest.
+// Unlike crypt(3), the salt is not truncated to 16 bytes, which is what MySQL relies on.
+// https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha256Crypt(password, salt []byte, rounds int) string {
+	// Digest B: password + salt + password.
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	b := h.Sum(nil)
+
+	// Digest A.
+	h.Reset()
+	h.Write(password)
+	h.Write(salt)
+	n := len(password)
+	for ; n > sha256.Size; n -= sha256.Size {
+		h.Write(b)
+	}
+	h.Write(b[:n])
+	for n = len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	// Byte sequence P.
+	h.Reset()
+	for range len(password) {
+		h.Write(password)
+	}
+	p := repeatToLength(h.Sum(nil), len(password))
+
+	// Byte sequence S.
+	h.Reset()
+	for range 16 + int(a[0]) {
+		h.Write(salt)
+	}
+	s := repeatToLength(h.Sum(nil), len(salt))
+
+	c := a
+	for i := range rounds {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(c[:0])
+	}
+
+	var out strings.Builder
+	out.Grow(cachingSha2DigestLen)
+	encode := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for range n {
+			out.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	encode(c[0], c[10], c[20], 4)
+	encode(c[21], c[1], c[11], 4)
+	encode(c[12], c[22], c[2], 4)
+	encode(c[3], c[13], c[23], 4)
+	encode(c[24], c[4], c[14], 4)
+	encode(c[15], c[25], c[5], 4)
+	encode(c[6], c[16], c[26], 4)
+	encode(c[27], c[7], c[17], 4)
+	encode(c[18], c[28], c[8], 4)
+	encode(c[9], c[19], c[29], 4)
+	encode(0, c[31], c[30], 3)
+	return out.String()
+}
+
+func repeatToLength(digest []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out)+len(digest) <= n {
+		out = append(out, digest...)
+	}
+	return append(out, digest[:n-len(out)]...)
+}
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+// ErrAccessDenied is returned by VerifyPassword for an unknown or locked account or a wrong password,
+// which are not told apart as in MySQL.
+var ErrAccessDenied = errors.New("access denied")
+
+// VerifyPassword checks a password received in clear text, e.g., over HTTP basic authentication,
+// against the account that |user| connecting from |host| is matched to. It returns the account,
+// whose host may be a wildcard and is the one that privileges are granted to.
+func VerifyPassword(db *mysql_db.MySQLDb, user, host, password string) (*mysql_db.User, error) {
+	rd := db.Reader()
+	account := db.GetUser(rd, user, host, false)
+	rd.Close()
+	if account == nil || account.Locked {
+		return nil, ErrAccessDenied
+	}
+
+	var ok bool
+	switch account.Plugin {
+	case "", mysql.MysqlNativePassword:
+		ok = checkNativePassword(password, account.Password)
+	default:
+		p, found := AuthPlugins[account.Plugin]
+		if !found {
+			return nil, fmt.Errorf("authentication plugin %q is not supported", account.Plugin)
+		}
+		var err error
+		if ok, err = p.Authenticate(db, user, account, password); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, ErrAccessDenied
+	}
+	return account, nil
+}
+
+// checkNativePassword compares a password with a mysql_native_password hash, "*" followed by SHA1(SHA1(password)) in hex.
+func checkNativePassword(password, hash string) bool {
+	if hash == "" {
+		return password == ""
+	}
+	s1 := sha1.Sum([]byte(password))
+	s2 := sha1.Sum(s1[:])
+	expected := "*" + strings.ToUpper(hex.EncodeToString(s2[:]))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToUpper(hash))) == 1
+}
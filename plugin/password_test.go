@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	sha2, err := HashCachingSha2Password("sha2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := mysql_db.CreateEmptyMySQLDb()
+	db.SetPlugins(AuthPlugins)
+	ed := db.Editor()
+	db.AddSuperUser(ed, "alice", "%", "native")
+	for _, u := range []*mysql_db.User{
+		{User: "bob", Host: "localhost", Plugin: mysql.CachingSha2Password, Password: sha2},
+		{User: "carol", Host: "%", Plugin: mysql.MysqlNativePassword},
+		{User: "dave", Host: "%", Plugin: mysql.MysqlNativePassword, Locked: true},
+	} {
+		u.PrivilegeSet = mysql_db.NewPrivilegeSet()
+		ed.PutUser(u)
+	}
+	ed.Close()
+
+	tests := []struct {
+		user, host, password string
+		ok                   bool
+	}{
+		{"alice", "10.0.0.1", "native", true},
+		{"alice", "10.0.0.1", "Native", false},
+		{"bob", "localhost", "sha2", true},
+		{"bob", "10.0.0.1", "sha2", false},
+		{"carol", "localhost", "", true},
+		{"carol", "localhost", "x", false},
+		{"dave", "localhost", "", false},
+		{"eve", "localhost", "", false},
+	}
+	for _, tt := range tests {
+		account, err := VerifyPassword(db, tt.user, tt.host, tt.password)
+		if tt.ok {
+			if err != nil || account.User != tt.user {
+				t.Errorf("%s@%s: expected success, got %v", tt.user, tt.host, err)
+			}
+		} else if !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("%s@%s with %q: expected access denied, got %v", tt.user, tt.host, tt.password, err)
+		}
+	}
+}
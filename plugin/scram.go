@@ -0,0 +1,243 @@
+package plugin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dolthub/doltgresql/server/auth/rfc5802"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+)
+
+// The SCRAM-SHA-256 verifier of an account is kept in its user attributes (the User_attributes column
+// of mysql.user) under this key, so that it follows the account through RENAME USER and DROP USER.
+const scramAttribute = "scram_sha_256"
+
+const (
+	scramIterations = 4096 // the default of PostgreSQL
+	scramSaltLength = 16
+)
+
+// ScramSha256Verifier is what the server stores to verify a password with SCRAM-SHA-256 (RFC 5802, RFC 7677)
+// without knowing the password.
+type ScramSha256Verifier struct {
+	Iterations uint32
+	Salt       rfc5802.OctetString
+	StoredKey  rfc5802.OctetString
+	ServerKey  rfc5802.OctetString
+}
+
+// NewScramSha256Verifier derives the verifier of |password| with a random salt.
+func NewScramSha256Verifier(password string) (*ScramSha256Verifier, error) {
+	salt := make(rfc5802.OctetString, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	saltedPassword, err := rfc5802.SaltedPassword(password, salt, scramIterations)
+	if err != nil {
+		return nil, err
+	}
+	return &ScramSha256Verifier{
+		Iterations: scramIterations,
+		Salt:       salt,
+		StoredKey:  rfc5802.StoredKey(rfc5802.ClientKey(saltedPassword)),
+		ServerKey:  rfc5802.ServerKey(saltedPassword),
+	}, nil
+}
+
+// String returns the verifier in the format of pg_authid.rolpassword:
+// SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+func (v *ScramSha256Verifier) String() string {
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s",
+		v.Iterations, v.Salt.ToBase64(), v.StoredKey.ToBase64(), v.ServerKey.ToBase64())
+}
+
+// ParseScramSha256Verifier parses a verifier in the format returned by String.
+func ParseScramSha256Verifier(s string) (*ScramSha256Verifier, error) {
+	invalid := fmt.Errorf("invalid SCRAM-SHA-256 verifier")
+	rest, ok := strings.CutPrefix(s, "SCRAM-SHA-256$")
+	if !ok {
+		return nil, invalid
+	}
+	params, keys, ok := strings.Cut(rest, "$")
+	if !ok {
+		return nil, invalid
+	}
+	iterations, salt, ok := strings.Cut(params, ":")
+	if !ok {
+		return nil, invalid
+	}
+	storedKey, serverKey, ok := strings.Cut(keys, ":")
+	if !ok {
+		return nil, invalid
+	}
+	n, err := strconv.ParseUint(iterations, 10, 32)
+	if err != nil || n == 0 {
+		return nil, invalid
+	}
+	v := &ScramSha256Verifier{Iterations: uint32(n)}
+	for _, field := range []struct {
+		dst *rfc5802.OctetString
+		src string
+	}{{&v.Salt, salt}, {&v.StoredKey, storedKey}, {&v.ServerKey, serverKey}} {
+		b, err := base64.StdEncoding.DecodeString(field.src)
+		if err != nil || len(b) == 0 {
+			return nil, invalid
+		}
+		*field.dst = b
+	}
+	return v, nil
+}
+
+// GetScramSha256Verifier returns the SCRAM-SHA-256 verifier of the account,
+// or nil if none has been derived since its password was set.
+func GetScramSha256Verifier(user *mysql_db.User) (*ScramSha256Verifier, error) {
+	if user.Attributes == nil {
+		return nil, nil
+	}
+	attributes := make(map[string]any)
+	if err := json.Unmarshal([]byte(*user.Attributes), &attributes); err != nil {
+		return nil, err
+	}
+	s, ok := attributes[scramAttribute].(string)
+	if !ok {
+		return nil, nil
+	}
+	return ParseScramSha256Verifier(s)
+}
+
+// SetScramSha256Verifier stores the verifier of the account. A nil verifier removes it.
+func SetScramSha256Verifier(user *mysql_db.User, v *ScramSha256Verifier) error {
+	attributes := make(map[string]any)
+	if user.Attributes != nil {
+		if err := json.Unmarshal([]byte(*user.Attributes), &attributes); err != nil {
+			return err
+		}
+	}
+	if v != nil {
+		attributes[scramAttribute] = v.String()
+	} else {
+		delete(attributes, scramAttribute)
+	}
+	if len(attributes) == 0 {
+		user.Attributes = nil
+		return nil
+	}
+	b, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	s := string(b)
+	user.Attributes = &s
+	return nil
+}
+
+// PasswordVerifiers are the SCRAM-SHA-256 verifiers of the passwords set by a CREATE USER or ALTER USER statement.
+type PasswordVerifiers struct {
+	db        *mysql_db.MySQLDb
+	verifiers map[mysql_db.UserPrimaryKey]*ScramSha256Verifier
+}
+
+// DerivePasswordVerifiers derives the SCRAM-SHA-256 verifiers of the passwords set by |n|.
+// It must be called before the passwords are hashed by HashPasswords, and the verifiers stored
+// with PasswordVerifiers.Store once |n| has been executed.
+//
+// An account whose password is given only as a hash (IDENTIFIED ... AS) or that has no password
+// gets no verifier, and cannot log in over the PostgreSQL protocol with a password.
+func DerivePasswordVerifiers(n sql.Node) (PasswordVerifiers, error) {
+	var users []plan.AuthenticatedUser
+	var db *mysql_db.MySQLDb
+	switch n := n.(type) {
+	case *plan.CreateUser:
+		db, _ = n.MySQLDb.(*mysql_db.MySQLDb)
+		users = n.Users
+	case *plan.AlterUser:
+		db, _ = n.MySQLDb.(*mysql_db.MySQLDb)
+		users = []plan.AuthenticatedUser{n.User}
+	}
+	pv := PasswordVerifiers{db: db, verifiers: make(map[mysql_db.UserPrimaryKey]*ScramSha256Verifier)}
+	if db == nil {
+		return pv, nil
+	}
+
+	var existing map[mysql_db.UserPrimaryKey]bool
+	if _, ok := n.(*plan.CreateUser); ok {
+		// CREATE USER IF NOT EXISTS leaves the existing accounts untouched.
+		existing = make(map[mysql_db.UserPrimaryKey]bool)
+		rd := db.Reader()
+		for _, user := range users {
+			key := userPrimaryKey(user)
+			_, existing[key] = rd.GetUser(key)
+		}
+		rd.Close()
+	}
+
+	for _, user := range users {
+		key := userPrimaryKey(user)
+		if existing[key] {
+			continue
+		}
+		password := clearTextPassword(user)
+		if password == "" {
+			pv.verifiers[key] = nil
+			continue
+		}
+		v, err := NewScramSha256Verifier(password)
+		if err != nil {
+			return pv, err
+		}
+		pv.verifiers[key] = v
+	}
+	return pv, nil
+}
+
+// Store saves the verifiers in the user attributes of the accounts.
+func (pv PasswordVerifiers) Store(ctx *sql.Context) error {
+	if pv.db == nil || len(pv.verifiers) == 0 {
+		return nil
+	}
+	ed := pv.db.Editor()
+	defer ed.Close()
+	for key, v := range pv.verifiers {
+		existing, ok := ed.GetUser(key)
+		if !ok {
+			continue
+		}
+		user := *existing
+		if err := SetScramSha256Verifier(&user, v); err != nil {
+			return err
+		}
+		ed.RemoveUser(key)
+		ed.PutUser(&user)
+	}
+	return pv.db.Persist(ctx, ed)
+}
+
+func userPrimaryKey(user plan.AuthenticatedUser) mysql_db.UserPrimaryKey {
+	host := user.UserName.Host
+	if host == "" {
+		host = "%"
+	}
+	return mysql_db.UserPrimaryKey{Host: host, User: user.UserName.Name}
+}
+
+// clearTextPassword returns the password given in IDENTIFIED BY, if any.
+func clearTextPassword(user plan.AuthenticatedUser) string {
+	if user.Identity != "" {
+		return ""
+	}
+	switch auth := user.Auth1.(type) {
+	case plan.AuthenticationMysqlNativePassword:
+		// Password() returns the hash.
+		return string(auth)
+	case nil:
+		return ""
+	default:
+		return auth.Password()
+	}
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dolthub/doltgresql/server/auth/rfc5802"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+	"github.com/dolthub/go-mysql-server/sql/plan"
+	"github.com/dolthub/vitess/go/mysql"
+)
+
+func TestScramSha256Verifier(t *testing.T) {
+	v, err := NewScramSha256Verifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseScramSha256Verifier(v.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != v.String() {
+		t.Errorf("expected %q, got %q", v.String(), parsed.String())
+	}
+
+	// The client derives the same StoredKey from the password and the salt.
+	saltedPassword, err := rfc5802.SaltedPassword("secret", parsed.Salt, parsed.Iterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rfc5802.StoredKey(rfc5802.ClientKey(saltedPassword)).Equals(parsed.StoredKey) {
+		t.Error("the StoredKey does not match the password")
+	}
+
+	for _, s := range []string{"", "secret", "SCRAM-SHA-256$4096:c2FsdA==", "SCRAM-SHA-256$x:c2FsdA==$a2V5:a2V5", "SCRAM-SHA-256$4096:$a2V5:a2V5"} {
+		if _, err := ParseScramSha256Verifier(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+func TestScramSha256VerifierAttribute(t *testing.T) {
+	attributes := `{"metadata": {"comment": "analyst"}}`
+	user := &mysql_db.User{User: "alice", Host: "%", Attributes: &attributes}
+	if v, err := GetScramSha256Verifier(user); err != nil || v != nil {
+		t.Fatalf("expected no verifier, got %v (%v)", v, err)
+	}
+
+	v, err := NewScramSha256Verifier("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetScramSha256Verifier(user, v); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetScramSha256Verifier(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.String() != v.String() {
+		t.Errorf("expected %v, got %v", v, got)
+	}
+
+	if err := SetScramSha256Verifier(user, nil); err != nil {
+		t.Fatal(err)
+	}
+	if user.Attributes == nil || *user.Attributes != `{"metadata":{"comment":"analyst"}}` {
+		t.Errorf("the other attributes were not kept: %v", user.Attributes)
+	}
+}
+
+type noopPersister struct{}
+
+func (noopPersister) Persist(*sql.Context, []byte) error { return nil }
+
+func TestDerivePasswordVerifiers(t *testing.T) {
+	db := mysql_db.CreateEmptyMySQLDb()
+	db.SetPlugins(AuthPlugins)
+	db.SetPersister(noopPersister{})
+	ed := db.Editor()
+	db.AddSuperUser(ed, "existing", "%", "old")
+	ed.Close()
+	ctx := sql.NewContext(context.Background())
+
+	createUser := &plan.CreateUser{
+		IfNotExists: true,
+		Users: []plan.AuthenticatedUser{
+			{UserName: plan.UserName{Name: "alice"}, Auth1: plan.NewDefaultAuthentication("native")},
+			{UserName: plan.UserName{Name: "bob", Host: "localhost"}, Auth1: plan.NewOtherAuthentication("sha2", mysql.CachingSha2Password)},
+			{UserName: plan.UserName{Name: "carol"}},
+			{UserName: plan.UserName{Name: "existing"}, Auth1: plan.NewDefaultAuthentication("new")},
+		},
+		MySQLDb: db,
+	}
+	verifiers, err := DerivePasswordVerifiers(createUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed = db.Editor()
+	for _, u := range []struct{ user, host string }{{"alice", "%"}, {"bob", "localhost"}, {"carol", "%"}} {
+		ed.PutUser(&mysql_db.User{User: u.user, Host: u.host, PrivilegeSet: mysql_db.NewPrivilegeSet()})
+	}
+	ed.Close()
+	if err := verifiers.Store(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, host, password string
+	}{
+		{"alice", "%", "native"},
+		{"bob", "localhost", "sha2"},
+		{"carol", "%", ""},
+		{"existing", "%", ""},
+	}
+	rd := db.Reader()
+	defer rd.Close()
+	for _, tt := range tests {
+		user, ok := rd.GetUser(mysql_db.UserPrimaryKey{User: tt.user, Host: tt.host})
+		if !ok {
+			t.Fatalf("user %s@%s not found", tt.user, tt.host)
+		}
+		v, err := GetScramSha256Verifier(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.password == "" {
+			if v != nil {
+				t.Errorf("%s: expected no verifier, got %v", tt.user, v)
+			}
+			continue
+		}
+		if v == nil {
+			t.Errorf("%s: expected a verifier", tt.user)
+			continue
+		}
+		saltedPassword, err := rfc5802.SaltedPassword(tt.password, v.Salt, v.Iterations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rfc5802.StoredKey(rfc5802.ClientKey(saltedPassword)).Equals(v.StoredKey) {
+			t.Errorf("%s: the verifier does not match the password", tt.user)
+		}
+	}
+}
@@ -36,7 +36,7 @@ func RegisterReplicaController(provider *catalog.DatabaseProvider, engine *sqle.
 	replica := binlogreplication.MyBinlogReplicaController
 	replica.SetEngine(engine)
 
-	session := backend.NewSession(memory.NewSession(sql.NewBaseSession(), provider), provider, pool)
+	session := backend.NewReplicaSession(memory.NewSession(sql.NewBaseSession(), provider), provider, pool)
 	ctx := sql.NewContext(context.Background(), sql.WithSession(session))
 	ctx.SetCurrentDatabase("mysql")
 	replica.SetExecutionContext(ctx)
@@ -45,7 +45,13 @@ func RegisterReplicaController(provider *catalog.DatabaseProvider, engine *sqle.
 	twp.controller = delta.NewController(pool)
 
 	replica.SetTableWriterProvider(twp)
-	builder.FlushDeltaBuffer = nil // TODO: implement this
+	builder.FlushDeltaBuffer = func(ctx *sql.Context, tables []sql.TableNode) error {
+		ids := make([]delta.TableIdentifier, 0, len(tables))
+		for _, table := range tables {
+			ids = append(ids, delta.TableIdentifier{DBName: table.Database().Name(), TableName: table.UnderlyingTable().Name()})
+		}
+		return replica.FlushDeltaBuffer(ctx, ids)
+	}
 
 	engine.Analyzer.Catalog.BinlogReplicaController = binlogreplication.MyBinlogReplicaController
 
@@ -88,6 +94,15 @@ func (twp *tableWriterProvider) FlushDeltaBuffer(ctx *sql.Context, tx *stdsql.Tx
 	return err
 }
 
+func (twp *tableWriterProvider) FlushTableDeltas(ctx *sql.Context, tx *stdsql.Tx, tables []delta.TableIdentifier) ([]delta.TableIdentifier, error) {
+	flushed, _, err := twp.controller.FlushTables(ctx, tx, tables)
+	return flushed, err
+}
+
+func (twp *tableWriterProvider) DeltaBuffered(tables []delta.TableIdentifier) bool {
+	return twp.controller.Buffered(tables)
+}
+
 func (twp *tableWriterProvider) DiscardDeltaBuffer(ctx *sql.Context) {
 	twp.controller.Close()
 }
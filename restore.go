@@ -0,0 +1,57 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apecloud/myduckserver/backup"
+	"github.com/sirupsen/logrus"
+)
+
+const restoreCommand = "restore"
+
+// runRestore implements "myduckserver restore [flags] <backup-dir>", which copies a backup taken
+// with BACKUP TO into the data directory given by -datadir or the config file.
+// The server started on that directory afterwards resumes replication from the backup's position.
+func runRestore(args []string) {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s %s [-datadir dir] [-config file] <backup-dir>\n", os.Args[0], restoreCommand)
+		flag.PrintDefaults()
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(2)
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := loadConfig(); err != nil {
+		logrus.Fatalln("Failed to load the configuration:", err)
+	}
+
+	manifest, err := backup.Restore(flag.Arg(0), cfg.Server.DataDir)
+	if err != nil {
+		logrus.Fatalln("Failed to restore the backup:", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"datadir":           cfg.Server.DataDir,
+		"created_at":        manifest.CreatedAt,
+		"executed_gtid_set": strings.TrimPrefix(manifest.BinlogPosition, "MySQL56/"),
+	}).Infoln("Backup restored")
+}
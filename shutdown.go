@@ -0,0 +1,148 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/apecloud/myduckserver/audit"
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/binlogreplication"
+	"github.com/apecloud/myduckserver/catalog"
+	"github.com/apecloud/myduckserver/pgserver"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	"github.com/sirupsen/logrus"
+)
+
+// httpServer is an auxiliary HTTP or gRPC listener, such as the metrics exporter, the admin API,
+// the HTTP query interface or the Flight SQL server.
+type httpServer interface {
+	Close(ctx context.Context) error
+}
+
+// waitForTermination blocks until SIGTERM or SIGINT is received.
+// A second signal during the graceful shutdown terminates the process immediately.
+func waitForTermination() {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-ch
+	logrus.Infof("Received %s, shutting down gracefully (timeout %s)", sig, cfg.Server.ShutdownTimeout)
+	go func() {
+		sig := <-ch
+		logrus.Warnf("Received %s again, exiting immediately", sig)
+		os.Exit(1)
+	}()
+}
+
+// shutdown stops the server in an order that leaves the data directory consistent:
+// the replication applier is stopped first so that it can flush its delta buffer and commit,
+// then the listeners are closed and the running queries are drained, the connections of the sessions
+// are closed, and finally DuckDB is checkpointed.
+func shutdown(srv *server.Server, pgServer *pgserver.Server, httpServers []httpServer, provider *catalog.DatabaseProvider, pool *backend.ConnectionPool) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := binlogreplication.MyBinlogReplicaController.Shutdown(ctx); err != nil {
+		logrus.Errorln("Failed to stop the replication applier:", err)
+	}
+
+	if pgServer != nil {
+		pgServer.Close()
+	}
+	srv.Close()
+	for _, s := range httpServers {
+		if err := s.Close(ctx); err != nil {
+			logrus.Warnf("Failed to close the %T listener: %v", s, err)
+		}
+	}
+
+	// The sessions that are still open can no longer run anything once their connections are closed.
+	drainQueries(ctx, srv.Engine.ProcessList)
+	if err := pool.CloseConns(); err != nil {
+		logrus.Warnln("Failed to close the connections of the sessions:", err)
+	}
+
+	// The pending records are written to the audit log table before the checkpoint.
+	if err := audit.Close(); err != nil {
+		logrus.Warnln("Failed to close the audit log:", err)
+	}
+
+	if _, err := provider.Storage().ExecContext(ctx, "CHECKPOINT"); err != nil {
+		// DuckDB still checkpoints the WAL when the database is closed.
+		logrus.Warnln("Failed to checkpoint the database:", err)
+	}
+
+	if err := pool.Close(); err != nil {
+		logrus.Warnln("Failed to close the connection pool:", err)
+	}
+	if err := provider.Close(); err != nil {
+		logrus.Warnln("Failed to close the database:", err)
+	}
+	logrus.Infoln("Shutdown complete")
+}
+
+const (
+	// drainPollInterval is how often the shutdown checks whether the running queries have finished.
+	drainPollInterval = 50 * time.Millisecond
+	// cancelTimeout bounds the wait for the canceled queries, which end once DuckDB notices the interruption.
+	cancelTimeout = 5 * time.Second
+)
+
+// drainQueries waits for the queries running on any listener to finish until ctx is done,
+// and then cancels the remaining ones and waits for them to end.
+func drainQueries(ctx context.Context, processList sql.ProcessList) {
+	if waitForQueries(ctx, processList) {
+		return
+	}
+	running := runningQueries(processList)
+	logrus.Warnf("Canceling %d queries that are still running", len(running))
+	for _, conn := range running {
+		processList.Kill(conn)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	if !waitForQueries(ctx, processList) {
+		logrus.Warnln("Some canceled queries are still running")
+	}
+}
+
+// waitForQueries reports whether the running queries have finished before ctx is done.
+func waitForQueries(ctx context.Context, processList sql.ProcessList) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for len(runningQueries(processList)) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// runningQueries returns the connections that are running a query.
+func runningQueries(processList sql.ProcessList) []uint32 {
+	var conns []uint32
+	for _, p := range processList.Processes() {
+		if p.Command == sql.ProcessCommandQuery {
+			conns = append(conns, p.Connection)
+		}
+	}
+	return conns
+}
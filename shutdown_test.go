@@ -0,0 +1,109 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	stdsql "database/sql"
+	"testing"
+	"time"
+
+	"github.com/apecloud/myduckserver/testutil"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// TestDrainQueries checks that the queries running on shutdown either finish or are canceled
+// before the connections are closed and the database is checkpointed.
+func TestDrainQueries(t *testing.T) {
+	srv := testutil.NewServer(t)
+	for _, stmt := range []string{
+		"CREATE TABLE db1.small AS SELECT range AS n FROM range(20000)",
+		"CREATE TABLE db1.big AS SELECT range AS n FROM range(200000)",
+	} {
+		if _, err := srv.Provider.Storage().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go srv.Start()
+
+	db, err := stdsql.Open("mysql", "root@tcp("+srv.Listener.Addr().String()+")/db1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	processList := srv.Engine.ProcessList
+
+	// run starts |query| and waits until it shows up in the process list.
+	run := func(query string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			var n stdsql.NullInt64
+			done <- db.QueryRow(query).Scan(&n)
+		}()
+		deadline := time.Now().Add(10 * time.Second)
+		for len(runningQueries(processList)) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("the query did not start")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return done
+	}
+
+	// A query that finishes within the shutdown timeout is waited for.
+	done := run("SELECT COUNT(*) FROM db1.small AS a, db1.small AS b WHERE a.n < b.n")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	drainQueries(ctx, processList)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the query to finish, got %v", err)
+		}
+	default:
+		t.Error("the query is still running after the drain")
+	}
+
+	// A query that outlasts the shutdown timeout is canceled.
+	done = run("SELECT COUNT(*) FROM db1.big AS a, db1.big AS b WHERE a.n < b.n")
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	drainQueries(ctx, processList)
+	if n := len(runningQueries(processList)); n != 0 {
+		t.Errorf("%d queries are still running after the drain", n)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("the drain took %v", elapsed)
+	}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the query to be canceled")
+		}
+	case <-time.After(10 * time.Second):
+		t.Error("the canceled query did not return")
+	}
+
+	// The sessions cannot run anything once their connections are closed, and the checkpoint succeeds.
+	if err := srv.Pool.CloseConns(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM db1.t"); err == nil {
+		t.Error("expected the sessions to fail after their connections are closed")
+	}
+	if _, err := srv.Provider.Storage().Exec("CHECKPOINT"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil provides the fixtures shared by the tests of the listeners
+// that are started next to the MySQL server.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/apecloud/myduckserver/backend"
+	"github.com/apecloud/myduckserver/catalog"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql/mysql_db"
+)
+
+// Server is a MySQL server on an in-memory database, along with its provider and connection pool.
+type Server struct {
+	*server.Server
+	Provider *catalog.DatabaseProvider
+	Pool     *backend.ConnectionPool
+}
+
+// NewServer creates a MySQL server, which is not started, on an in-memory database with a table db1.t
+// holding (1, 'a'), (2, 'b') and (3, NULL), the super user root and the user nobody without privileges,
+// both without a password. The server is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	provider := catalog.NewInMemoryDBProvider()
+	t.Cleanup(func() { provider.Close() })
+	pool := backend.NewConnectionPool(provider.CatalogName(), provider.Connector(), provider.Storage())
+	engine := sqle.NewDefault(provider)
+	engine.Analyzer.ExecBuilder = backend.NewDuckBuilder(engine.Analyzer.ExecBuilder, pool, provider)
+
+	db := engine.Analyzer.Catalog.MySQLDb
+	ed := db.Editor()
+	db.AddSuperUser(ed, "root", "%", "")
+	ed.PutUser(&mysql_db.User{User: "nobody", Host: "%", PrivilegeSet: mysql_db.NewPrivilegeSet(), Plugin: "mysql_native_password"})
+	ed.Close()
+
+	for _, stmt := range []string{
+		"CREATE SCHEMA db1",
+		"CREATE TABLE db1.t (id INTEGER PRIMARY KEY, name VARCHAR)",
+		"INSERT INTO db1.t VALUES (1, 'a'), (2, 'b'), (3, NULL)",
+	} {
+		if _, err := provider.Storage().Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := server.Config{Protocol: "tcp", Address: "127.0.0.1:0"}
+	srv, err := server.NewServerWithHandler(cfg, engine, backend.NewSessionBuilder(provider, pool), nil, backend.WrapHandler(pool, engine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return &Server{Server: srv, Provider: provider, Pool: pool}
+}
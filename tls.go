@@ -0,0 +1,69 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// loadTLSConfig builds the TLS configuration shared by the MySQL and PostgreSQL listeners.
+// It returns nil if no certificate is configured, in which case TLS is disabled.
+func loadTLSConfig() (*tls.Config, error) {
+	c := cfg.TLS
+	if c.Cert == "" && c.Key == "" {
+		switch {
+		case c.Require:
+			return nil, errors.New("secure transport is required, but no certificate is configured")
+		case c.CA != "" || c.VerifyClientCert:
+			return nil, errors.New("client certificate verification requires a server certificate")
+		}
+		return nil, nil
+	}
+	if c.Cert == "" || c.Key == "" {
+		return nil, errors.New("both the certificate and the private key are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.CA != "" {
+		pem, err := os.ReadFile(c.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if c.VerifyClientCert {
+		if tlsConfig.ClientCAs == nil {
+			return nil, errors.New("client certificate verification requires a CA")
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpiler
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/apecloud/myduckserver/metrics"
+)
+
+// maxCachedQueryLength bounds the queries that are cached, so that bulk INSERTs do not fill the cache.
+const maxCachedQueryLength = 16 << 10
+
+// CacheOptions configures the translation cache.
+type CacheOptions struct {
+	// Size is the maximum number of cached translations. The cache is disabled if it is not positive.
+	Size int
+	// Normalize caches a translation for all the queries that differ only in their literals.
+	Normalize bool
+}
+
+// CacheStats describes the translation cache.
+type CacheStats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Normalize bool   `json:"normalize"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+}
+
+// translationCache is an LRU cache of translations, keyed either by the query text or,
+// with normalization, by the query text with its literals replaced by placeholders.
+type translationCache struct {
+	mu        sync.Mutex
+	capacity  int
+	normalize bool
+	lru       *list.List // of *cacheEntry, the most recently used first
+	entries   map[string]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	key string
+	// translated is the translated query, or, for a normalized query, the translation with placeholders.
+	translated string
+	// unsafe marks a normalized query whose translation depends on its literals,
+	// whose queries are then cached by their text.
+	unsafe bool
+}
+
+func newTranslationCache(opts CacheOptions) *translationCache {
+	return &translationCache{
+		capacity:  opts.Size,
+		normalize: opts.Normalize,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+}
+
+var cache = newTranslationCache(CacheOptions{Size: 10000})
+
+// SetCacheOptions resizes the translation cache. Changing the normalization flushes it.
+func SetCacheOptions(opts CacheOptions) {
+	cache.setOptions(opts)
+}
+
+// FlushCache removes all the cached translations and returns their number.
+func FlushCache() int {
+	return cache.flush()
+}
+
+// GetCacheStats returns the size and the hit and miss counts of the translation cache.
+func GetCacheStats() CacheStats {
+	return cache.stats()
+}
+
+func (c *translationCache) setOptions(opts CacheOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if opts.Normalize != c.normalize {
+		c.lru.Init()
+		clear(c.entries)
+	}
+	c.capacity = opts.Size
+	c.normalize = opts.Normalize
+	c.evict()
+}
+
+func (c *translationCache) flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.lru.Len()
+	c.lru.Init()
+	clear(c.entries)
+	return n
+}
+
+func (c *translationCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Size:      c.lru.Len(),
+		Capacity:  c.capacity,
+		Normalize: c.normalize,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+	}
+}
+
+// translate returns the cached translation of |sql|, or translates it with |translateFn| and caches the result.
+// Failed translations are not cached.
+func (c *translationCache) translate(sql string, translateFn func(string) (string, error)) (string, error) {
+	c.mu.Lock()
+	enabled, normalize := c.capacity > 0 && len(sql) <= maxCachedQueryLength, c.normalize
+	c.mu.Unlock()
+	if !enabled {
+		return translateFn(sql)
+	}
+
+	var (
+		n           normalizedQuery
+		normalized  bool
+		knownUnsafe bool
+	)
+	if normalize {
+		n, normalized = normalizeQuery(sql)
+	}
+	if normalized {
+		if e := c.get(n.key); e != nil {
+			if !e.unsafe {
+				if translated, ok := substitute(e.translated, n.literals); ok {
+					c.observe(true)
+					return translated, nil
+				}
+			}
+			knownUnsafe = true
+		}
+	}
+	if e := c.get(sql); e != nil {
+		c.observe(true)
+		return e.translated, nil
+	}
+
+	c.observe(false)
+	translated, err := translateFn(sql)
+	if err != nil {
+		return "", err
+	}
+	if normalized && !knownUnsafe && c.learnTemplate(n, translated, translateFn) {
+		return translated, nil
+	}
+	c.put(&cacheEntry{key: sql, translated: translated})
+	return translated, nil
+}
+
+// learnTemplate translates the normalized query and caches the result if substituting the literals
+// of the query into it gives |translated|. Otherwise, the normalized query is marked as unsafe.
+// It returns whether the translation was cached.
+func (c *translationCache) learnTemplate(n normalizedQuery, translated string, translateFn func(string) (string, error)) bool {
+	// With repeated literals, a template that swaps them would not be noticed.
+	seen := make(map[string]bool, len(n.literals))
+	for _, lit := range n.literals {
+		if seen[lit] {
+			return false
+		}
+		seen[lit] = true
+	}
+
+	template, err := translateFn(n.text)
+	if err == nil {
+		if substituted, ok := substitute(template, n.literals); ok && substituted == translated {
+			c.put(&cacheEntry{key: n.key, translated: template})
+			return true
+		}
+	}
+	c.put(&cacheEntry{key: n.key, unsafe: true})
+	return false
+}
+
+func (c *translationCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry)
+}
+
+func (c *translationCache) put(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[e.key]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.evict()
+}
+
+// evict removes the least recently used entries beyond the capacity. c.mu must be held.
+func (c *translationCache) evict() {
+	for c.lru.Len() > max(c.capacity, 0) {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.entries, elem.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *translationCache) observe(hit bool) {
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	metrics.ObserveTranslationCache(hit)
+}
@@ -0,0 +1,153 @@
+package transpiler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeTranslator stands in for sqlglot: it quotes identifiers as DuckDB does and counts its calls.
+type fakeTranslator struct {
+	calls int
+}
+
+func (f *fakeTranslator) translate(sql string) (string, error) {
+	f.calls++
+	if strings.Contains(sql, "syntax error") {
+		return "", errors.New("invalid expression")
+	}
+	return strings.ReplaceAll(sql, "`", `"`), nil
+}
+
+func TestCacheExact(t *testing.T) {
+	c := newTranslationCache(CacheOptions{Size: 2})
+	f := &fakeTranslator{}
+
+	for _, q := range []string{"SELECT `a` FROM t", "SELECT `a` FROM t", "SELECT 1", "SELECT 2", "SELECT `a` FROM t"} {
+		got, err := c.translate(q, f.translate)
+		if err != nil || got != strings.ReplaceAll(q, "`", `"`) {
+			t.Fatalf("translate(%q) = %q, %v", q, got, err)
+		}
+	}
+	// The first query is evicted by the third and fourth ones.
+	if stats := c.stats(); f.calls != 4 || stats.Hits != 1 || stats.Misses != 4 || stats.Size != 2 {
+		t.Errorf("unexpected calls %d and stats %+v", f.calls, stats)
+	}
+
+	if _, err := c.translate("syntax error", f.translate); err == nil {
+		t.Error("expected the translation error")
+	}
+	if _, ok := c.entries["syntax error"]; ok {
+		t.Error("failed translations must not be cached")
+	}
+
+	if n := c.flush(); n != 2 || c.stats().Size != 0 {
+		t.Errorf("flushed %d entries, %d left", n, c.stats().Size)
+	}
+
+	c.setOptions(CacheOptions{Size: 0})
+	c.translate("SELECT 1", f.translate)
+	if c.stats().Size != 0 {
+		t.Error("a cache of size 0 must be disabled")
+	}
+}
+
+func TestCacheNormalized(t *testing.T) {
+	c := newTranslationCache(CacheOptions{Size: 10, Normalize: true})
+	f := &fakeTranslator{}
+
+	// A miss translates both the query and its normalized text; later queries of the same shape hit.
+	for i, q := range []string{
+		"SELECT * FROM `t` WHERE id = 1 AND name = 'a'",
+		"SELECT * FROM `t` WHERE id = 2 AND name = 'b'",
+		"SELECT * FROM `t` WHERE id = 3.5 AND name = ''",
+	} {
+		got, err := c.translate(q, f.translate)
+		if want := strings.ReplaceAll(q, "`", `"`); err != nil || got != want {
+			t.Fatalf("translate(%q) = %q, %v; want %q", q, got, err, want)
+		}
+		if i == 0 && f.calls != 2 {
+			t.Fatalf("expected the query and the template to be translated, got %d calls", f.calls)
+		}
+	}
+	if f.calls != 2 || c.stats().Hits != 2 {
+		t.Errorf("unexpected calls %d and stats %+v", f.calls, c.stats())
+	}
+
+	// A string where a number was makes another shape.
+	c.translate("SELECT * FROM `t` WHERE id = '4' AND name = 'c'", f.translate)
+	if f.calls != 4 {
+		t.Errorf("expected a new template, got %d calls", f.calls)
+	}
+}
+
+func TestCacheNormalizedUnsafe(t *testing.T) {
+	c := newTranslationCache(CacheOptions{Size: 10, Normalize: true})
+	// A translation that depends on the value of a literal.
+	calls := 0
+	upper := func(sql string) (string, error) {
+		calls++
+		return strings.ToUpper(sql), nil
+	}
+
+	q := "select 'a', 1"
+	if got, _ := c.translate(q, upper); got != "SELECT 'A', 1" {
+		t.Fatalf("unexpected translation %q", got)
+	}
+	if e := c.get(normalizedKey(t, q)); e == nil || !e.unsafe {
+		t.Fatalf("expected the normalized query to be marked unsafe, got %+v", e)
+	}
+	// Queries of an unsafe shape are cached by their text.
+	if got, _ := c.translate("select 'b', 1", upper); got != "SELECT 'B', 1" {
+		t.Errorf("unexpected translation %q", got)
+	}
+	if got, _ := c.translate("select 'b', 1", upper); got != "SELECT 'B', 1" || calls != 3 {
+		t.Errorf("unexpected translation %q after %d calls", got, calls)
+	}
+}
+
+func normalizedKey(t *testing.T, sql string) string {
+	n, ok := normalizeQuery(sql)
+	if !ok {
+		t.Fatalf("%q cannot be normalized", sql)
+	}
+	return n.key
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		sql      string
+		text     string // empty if the query cannot be normalized
+		literals []string
+	}{
+		{"SELECT * FROM t1 WHERE a = 10 AND b = 'x''y' AND c = 'z'", "SELECT * FROM t1 WHERE a = ? AND b = 'x''y' AND c = ?", []string{"10", "'z'"}},
+		{"SELECT 1.5, -2, 1e3, 0x1F, x'AB', _utf8mb4'abc', t.1c", "SELECT ?, -?, 1e3, 0x1F, x'AB', _utf8mb4'abc', t.1c", []string{"1.5", "2"}},
+		{"SELECT DATE_FORMAT(d, '%Y'), 'a\\'b' FROM `t 1` LIMIT 5", "SELECT DATE_FORMAT(d, '%Y'), 'a\\'b' FROM `t 1` LIMIT ?", []string{"5"}},
+		{"SELECT /* 1 */ \"2\", a -- 3\nFROM t # 4\nWHERE b = 5", "SELECT /* 1 */ \"2\", a -- 3\nFROM t # 4\nWHERE b = ?", []string{"5"}},
+		{"SELECT * FROM t WHERE a = ?", "", nil},
+		{"SELECT a FROM t", "", nil},
+		{"SELECT 'unterminated", "", nil},
+	}
+	for _, tt := range tests {
+		n, ok := normalizeQuery(tt.sql)
+		if tt.text == "" {
+			if ok {
+				t.Errorf("normalizeQuery(%q) = %q, want no normalization", tt.sql, n.text)
+			}
+			continue
+		}
+		if !ok || n.text != tt.text || strings.Join(n.literals, "|") != strings.Join(tt.literals, "|") {
+			t.Errorf("normalizeQuery(%q) = %q %q, want %q %q", tt.sql, n.text, n.literals, tt.text, tt.literals)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	got, ok := substitute(`SELECT '?', "?" FROM t WHERE a = ? AND b = ?`, []string{"1", "'x'"})
+	if !ok || got != `SELECT '?', "?" FROM t WHERE a = 1 AND b = 'x'` {
+		t.Errorf("unexpected substitution %q", got)
+	}
+	if _, ok := substitute("SELECT ?", []string{"1", "2"}); ok {
+		t.Error("expected a mismatch of the number of placeholders")
+	}
+}
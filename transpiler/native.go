@@ -0,0 +1,1066 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpiler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var errNotSupported = errors.NewKind("the native translator does not support %s")
+
+// typedLiteral matches the DATE, TIME and TIMESTAMP literals, whose type the parser drops.
+var typedLiteral = regexp.MustCompile(`(?i)\b(date|time|timestamp)\s*'`)
+
+// translateNative translates the common shapes of SELECT, INSERT, UPDATE and DELETE statements from
+// their vitess AST. Anything else, including any expression or function whose DuckDB equivalent is
+// not known to behave as in MySQL, fails with errNotSupported, so that sqlglot translates it.
+func translateNative(sql string) (string, error) {
+	switch {
+	case typedLiteral.MatchString(sql):
+		return "", errNotSupported.New("typed literals")
+	case strings.Contains(sql, `\%`) || strings.Contains(sql, `\_`):
+		// MySQL keeps the backslash of these escapes in strings, for LIKE patterns, but the parser drops it.
+		return "", errNotSupported.New(`\% and \_ escapes`)
+	}
+
+	stmt, next, err := sqlparser.ParseOne(context.Background(), sql)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the query: %w", err)
+	}
+	// Like sqlglot, translate the first statement only, but leave multiple statements to it.
+	if strings.TrimLeft(sql[next:], "; \t\r\n") != "" {
+		return "", errNotSupported.New("multiple statements")
+	}
+
+	g := &generator{}
+	g.statement(stmt)
+	if g.err != nil {
+		return "", g.err
+	}
+	return g.buf.String(), nil
+}
+
+// generator writes the DuckDB SQL of a statement. The first unsupported construct is kept in err,
+// after which the output is meaningless.
+type generator struct {
+	buf strings.Builder
+	err error
+	// args is the number of positional parameters written, which must keep their order.
+	args int
+}
+
+func (g *generator) write(s ...string) {
+	for _, s := range s {
+		g.buf.WriteString(s)
+	}
+}
+
+func (g *generator) unsupported(what string) {
+	if g.err == nil {
+		g.err = errNotSupported.New(what)
+	}
+}
+
+func nodeName(node sqlparser.SQLNode) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*sqlparser.")
+}
+
+func (g *generator) statement(stmt sqlparser.Statement) {
+	switch stmt := stmt.(type) {
+	case sqlparser.SelectStatement:
+		g.selectStatement(stmt)
+	case *sqlparser.Insert:
+		g.insert(stmt)
+	case *sqlparser.Update:
+		g.update(stmt)
+	case *sqlparser.Delete:
+		g.delete(stmt)
+	default:
+		g.unsupported(nodeName(stmt) + " statements")
+	}
+}
+
+func (g *generator) selectStatement(stmt sqlparser.SelectStatement) {
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		g.selectQuery(stmt)
+	case *sqlparser.SetOp:
+		g.setOp(stmt)
+	case *sqlparser.ParenSelect:
+		g.write("(")
+		g.selectStatement(stmt.Select)
+		g.write(")")
+	default:
+		g.unsupported(nodeName(stmt) + " statements")
+	}
+}
+
+func (g *generator) selectQuery(sel *sqlparser.Select) {
+	switch {
+	case sel.Into != nil:
+		g.unsupported("SELECT ... INTO")
+	case sel.Lock != "":
+		g.unsupported("locking reads")
+	case len(sel.Window) > 0:
+		g.unsupported("named windows")
+	case sel.QueryOpts.StraightJoinHint || sel.QueryOpts.SQLCalcFoundRows:
+		g.unsupported("STRAIGHT_JOIN and SQL_CALC_FOUND_ROWS")
+	}
+
+	g.with(sel.With)
+	g.write("SELECT ")
+	if sel.QueryOpts.Distinct {
+		g.write("DISTINCT ")
+	}
+	for i, e := range sel.SelectExprs {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.selectExpr(e)
+	}
+	if len(sel.From) > 0 {
+		g.write(" FROM ")
+		g.tableExprs(sel.From)
+	}
+	g.where(" WHERE ", sel.Where)
+	if len(sel.GroupBy) > 0 {
+		g.write(" GROUP BY ")
+		g.exprs(sqlparser.Exprs(sel.GroupBy))
+	}
+	g.where(" HAVING ", sel.Having)
+	g.orderBy(sel.OrderBy)
+	g.limit(sel.Limit)
+}
+
+var setOps = map[string]string{
+	sqlparser.UnionStr:             "UNION",
+	sqlparser.UnionAllStr:          "UNION ALL",
+	sqlparser.UnionDistinctStr:     "UNION",
+	sqlparser.IntersectStr:         "INTERSECT",
+	sqlparser.IntersectAllStr:      "INTERSECT ALL",
+	sqlparser.IntersectDistinctStr: "INTERSECT",
+	sqlparser.ExceptStr:            "EXCEPT",
+	sqlparser.ExceptAllStr:         "EXCEPT ALL",
+	sqlparser.ExceptDistinctStr:    "EXCEPT",
+}
+
+func (g *generator) setOp(op *sqlparser.SetOp) {
+	switch {
+	case op.Into != nil:
+		g.unsupported("SELECT ... INTO")
+	case op.Lock != "":
+		g.unsupported("locking reads")
+	}
+	keyword, ok := setOps[op.Type]
+	if !ok {
+		g.unsupported(strings.ToUpper(op.Type))
+	}
+
+	g.with(op.With)
+	g.selectStatement(op.Left)
+	g.write(" ", keyword, " ")
+	g.selectStatement(op.Right)
+	g.orderBy(op.OrderBy)
+	g.limit(op.Limit)
+}
+
+func (g *generator) with(with *sqlparser.With) {
+	if with == nil {
+		return
+	}
+	g.write("WITH ")
+	if with.Recursive {
+		g.write("RECURSIVE ")
+	}
+	for i, te := range with.Ctes {
+		if i > 0 {
+			g.write(", ")
+		}
+		cte, ok := te.(*sqlparser.CommonTableExpr)
+		if !ok {
+			g.unsupported(nodeName(te) + " in WITH")
+			return
+		}
+		subquery, ok := cte.Expr.(*sqlparser.Subquery)
+		if !ok {
+			g.unsupported(nodeName(cte.Expr) + " in WITH")
+			return
+		}
+		g.ident(cte.As.String())
+		if len(cte.Columns) > 0 {
+			g.write("(")
+			g.columns(cte.Columns)
+			g.write(")")
+		}
+		g.write(" AS (")
+		g.selectStatement(subquery.Select)
+		g.write(")")
+	}
+	g.write(" ")
+}
+
+func (g *generator) selectExpr(e sqlparser.SelectExpr) {
+	switch e := e.(type) {
+	case *sqlparser.StarExpr:
+		if !e.TableName.IsEmpty() {
+			g.tableName(e.TableName)
+			g.write(".")
+		}
+		g.write("*")
+	case *sqlparser.AliasedExpr:
+		g.expr(e.Expr)
+		if !e.As.IsEmpty() {
+			g.write(" AS ")
+			g.ident(e.As.String())
+		}
+	default:
+		g.unsupported(nodeName(e))
+	}
+}
+
+func (g *generator) tableExprs(exprs sqlparser.TableExprs) {
+	for i, te := range exprs {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.tableExpr(te)
+	}
+}
+
+var joins = map[string]string{
+	sqlparser.JoinStr:             "JOIN",
+	sqlparser.LeftJoinStr:         "LEFT JOIN",
+	sqlparser.RightJoinStr:        "RIGHT JOIN",
+	sqlparser.FullOuterJoinStr:    "FULL OUTER JOIN",
+	sqlparser.NaturalJoinStr:      "NATURAL JOIN",
+	sqlparser.NaturalLeftJoinStr:  "NATURAL LEFT JOIN",
+	sqlparser.NaturalRightJoinStr: "NATURAL RIGHT JOIN",
+}
+
+func (g *generator) tableExpr(te sqlparser.TableExpr) {
+	switch te := te.(type) {
+	case *sqlparser.AliasedTableExpr:
+		// Index hints only matter to MySQL's optimizer, so they are dropped.
+		if len(te.Partitions) > 0 || te.AsOf != nil || te.Lateral {
+			g.unsupported("partition selection, AS OF and LATERAL")
+		}
+		var columns sqlparser.Columns
+		switch expr := te.Expr.(type) {
+		case sqlparser.TableName:
+			g.tableName(expr)
+		case *sqlparser.Subquery:
+			g.write("(")
+			g.selectStatement(expr.Select)
+			g.write(")")
+			columns = expr.Columns
+		default:
+			g.unsupported(nodeName(expr))
+		}
+		if !te.As.IsEmpty() {
+			g.write(" AS ")
+			g.ident(te.As.String())
+			if len(columns) > 0 {
+				g.write("(")
+				g.columns(columns)
+				g.write(")")
+			}
+		}
+	case *sqlparser.ParenTableExpr:
+		g.write("(")
+		g.tableExprs(te.Exprs)
+		g.write(")")
+	case *sqlparser.JoinTableExpr:
+		join, ok := joins[te.Join]
+		if !ok {
+			g.unsupported(strings.ToUpper(te.Join))
+		}
+		cond := te.Condition
+		if join == "JOIN" && cond.On == nil && len(cond.Using) == 0 {
+			// MySQL allows a JOIN without a condition, DuckDB requires CROSS JOIN.
+			join = "CROSS JOIN"
+		}
+		g.tableExpr(te.LeftExpr)
+		g.write(" ", join, " ")
+		if _, nested := te.RightExpr.(*sqlparser.JoinTableExpr); nested {
+			g.write("(")
+			g.tableExpr(te.RightExpr)
+			g.write(")")
+		} else {
+			g.tableExpr(te.RightExpr)
+		}
+		if cond.On != nil {
+			g.write(" ON ")
+			g.expr(cond.On)
+		}
+		if len(cond.Using) > 0 {
+			g.write(" USING (")
+			g.columns(cond.Using)
+			g.write(")")
+		}
+	default:
+		g.unsupported(nodeName(te))
+	}
+}
+
+// tableName writes a table name. The databases of MySQL are the schemas of DuckDB, so a qualified
+// name keeps its meaning.
+func (g *generator) tableName(name sqlparser.TableName) {
+	if !name.SchemaQualifier.IsEmpty() {
+		g.unsupported("schema-qualified names")
+	}
+	if !name.DbQualifier.IsEmpty() {
+		g.ident(name.DbQualifier.String())
+		g.write(".")
+	}
+	g.ident(name.Name.String())
+}
+
+func (g *generator) columns(columns sqlparser.Columns) {
+	for i, c := range columns {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.ident(c.String())
+	}
+}
+
+func (g *generator) where(keyword string, where *sqlparser.Where) {
+	if where == nil || where.Expr == nil {
+		return
+	}
+	g.write(keyword)
+	g.expr(where.Expr)
+}
+
+func (g *generator) orderBy(orderBy sqlparser.OrderBy) {
+	keyword := " ORDER BY "
+	for _, o := range orderBy {
+		if _, ok := o.Expr.(*sqlparser.NullVal); ok {
+			// ORDER BY NULL only disables the sorting of GROUP BY in old versions of MySQL.
+			continue
+		}
+		g.write(keyword)
+		g.order(o)
+		keyword = ", "
+	}
+}
+
+// order writes a sort key. MySQL sorts NULLs first in ascending order and last in descending order,
+// which is spelled out as DuckDB sorts them last by default.
+func (g *generator) order(o *sqlparser.Order) {
+	g.expr(o.Expr)
+	if o.Direction == sqlparser.DescScr {
+		g.write(" DESC NULLS LAST")
+	} else {
+		g.write(" ASC NULLS FIRST")
+	}
+}
+
+func (g *generator) limit(limit *sqlparser.Limit) {
+	if limit == nil {
+		return
+	}
+	if limit.Rowcount != nil {
+		g.write(" LIMIT ")
+		g.expr(limit.Rowcount)
+	}
+	if limit.Offset != nil {
+		g.write(" OFFSET ")
+		g.expr(limit.Offset)
+	}
+}
+
+func (g *generator) insert(ins *sqlparser.Insert) {
+	switch {
+	case ins.With != nil:
+		g.unsupported("WITH in INSERT")
+	case len(ins.Partitions) > 0:
+		g.unsupported("partition selection")
+	case len(ins.OnDup) > 0:
+		g.unsupported("ON DUPLICATE KEY UPDATE")
+	}
+
+	switch {
+	case ins.Action == sqlparser.ReplaceStr:
+		g.write("INSERT OR REPLACE INTO ")
+	case ins.Ignore != "":
+		g.write("INSERT OR IGNORE INTO ")
+	default:
+		g.write("INSERT INTO ")
+	}
+	g.tableName(ins.Table)
+	if len(ins.Columns) > 0 {
+		g.write(" (")
+		g.columns(ins.Columns)
+		g.write(")")
+	}
+
+	switch rows := ins.Rows.(type) {
+	case *sqlparser.AliasedValues:
+		g.aliasedValues(*rows)
+	case sqlparser.AliasedValues:
+		g.aliasedValues(rows)
+	case sqlparser.Values:
+		g.values(rows)
+	case sqlparser.SelectStatement:
+		g.write(" ")
+		g.selectStatement(rows)
+	default:
+		g.unsupported(nodeName(rows))
+	}
+}
+
+func (g *generator) aliasedValues(values sqlparser.AliasedValues) {
+	if !values.As.IsEmpty() {
+		g.unsupported("row aliases")
+	}
+	g.values(values.Values)
+}
+
+func (g *generator) values(values sqlparser.Values) {
+	g.write(" VALUES ")
+	for i, row := range values {
+		if len(row) == 0 {
+			g.unsupported("empty rows")
+		}
+		if i > 0 {
+			g.write(", ")
+		}
+		g.write("(")
+		g.exprs(sqlparser.Exprs(row))
+		g.write(")")
+	}
+}
+
+func (g *generator) update(upd *sqlparser.Update) {
+	switch {
+	case upd.With != nil:
+		g.unsupported("WITH in UPDATE")
+	case upd.Ignore != "":
+		g.unsupported("UPDATE IGNORE")
+	case len(upd.OrderBy) > 0 || upd.Limit != nil:
+		g.unsupported("ORDER BY and LIMIT in UPDATE")
+	}
+
+	g.write("UPDATE ")
+	g.targetTable(upd.TableExprs)
+	g.write(" SET ")
+	for i, assignment := range upd.Exprs {
+		if i > 0 {
+			g.write(", ")
+		}
+		// DuckDB does not allow a qualified column here, and with a single table, it is that of the table.
+		g.ident(assignment.Name.Name.String())
+		g.write(" = ")
+		g.expr(assignment.Expr)
+	}
+	g.where(" WHERE ", upd.Where)
+}
+
+func (g *generator) delete(del *sqlparser.Delete) {
+	switch {
+	case del.With != nil:
+		g.unsupported("WITH in DELETE")
+	case len(del.Targets) > 0:
+		g.unsupported("multiple-table DELETE")
+	case len(del.Partitions) > 0:
+		g.unsupported("partition selection")
+	case len(del.OrderBy) > 0 || del.Limit != nil:
+		g.unsupported("ORDER BY and LIMIT in DELETE")
+	}
+
+	g.write("DELETE FROM ")
+	g.targetTable(del.TableExprs)
+	g.where(" WHERE ", del.Where)
+}
+
+// targetTable writes the table modified by an UPDATE or a DELETE, which must be a single one.
+func (g *generator) targetTable(exprs sqlparser.TableExprs) {
+	if len(exprs) != 1 {
+		g.unsupported("multiple-table statements")
+		return
+	}
+	te, ok := exprs[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		g.unsupported("multiple-table statements")
+		return
+	}
+	if _, ok := te.Expr.(sqlparser.TableName); !ok {
+		g.unsupported(nodeName(te.Expr) + " as the target")
+		return
+	}
+	g.tableExpr(te)
+}
+
+// Operator precedences of DuckDB, from the loosest. They differ from MySQL's for the bitwise
+// operators, which all bind like the other operators of PostgreSQL, below + and -.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precIs
+	precCompare
+	precPredicate // BETWEEN, IN and LIKE
+	precOther
+	precAdd
+	precMul
+	precUnary
+	precAtom
+)
+
+func precedence(e sqlparser.Expr) int {
+	switch e := e.(type) {
+	case *sqlparser.OrExpr:
+		return precOr
+	case *sqlparser.AndExpr:
+		return precAnd
+	case *sqlparser.NotExpr:
+		return precNot
+	case *sqlparser.IsExpr:
+		return precIs
+	case *sqlparser.ComparisonExpr:
+		switch e.Operator {
+		case sqlparser.NullSafeEqualStr:
+			return precIs
+		case sqlparser.EqualStr, sqlparser.LessThanStr, sqlparser.GreaterThanStr,
+			sqlparser.LessEqualStr, sqlparser.GreaterEqualStr, sqlparser.NotEqualStr:
+			return precCompare
+		}
+		return precPredicate
+	case *sqlparser.RangeCond:
+		return precPredicate
+	case *sqlparser.BinaryExpr:
+		if op, ok := binaryOps[e.Operator]; ok {
+			return op.prec
+		}
+	case *sqlparser.UnaryExpr:
+		if e.Operator == sqlparser.BangStr {
+			return precNot
+		}
+		return precUnary
+	case *sqlparser.SQLVal:
+		if len(e.Val) > 0 && e.Val[0] == '-' {
+			return precUnary
+		}
+	}
+	return precAtom
+}
+
+// operand writes an operand of an operator, in parentheses if it binds looser than |min|.
+func (g *generator) operand(e sqlparser.Expr, min int) {
+	if precedence(e) < min {
+		g.write("(")
+		g.expr(e)
+		g.write(")")
+	} else {
+		g.expr(e)
+	}
+}
+
+// infix writes a left-associative operator, or a non-associative one if |nonAssoc| is set.
+func (g *generator) infix(left sqlparser.Expr, op string, right sqlparser.Expr, prec int, nonAssoc bool) {
+	if nonAssoc {
+		g.operand(left, prec+1)
+	} else {
+		g.operand(left, prec)
+	}
+	g.write(" ", op, " ")
+	g.operand(right, prec+1)
+}
+
+var comparisonOps = map[string]string{
+	sqlparser.EqualStr:         "=",
+	sqlparser.LessThanStr:      "<",
+	sqlparser.GreaterThanStr:   ">",
+	sqlparser.LessEqualStr:     "<=",
+	sqlparser.GreaterEqualStr:  ">=",
+	sqlparser.NotEqualStr:      "<>",
+	sqlparser.NullSafeEqualStr: "IS NOT DISTINCT FROM",
+	sqlparser.InStr:            "IN",
+	sqlparser.NotInStr:         "NOT IN",
+	sqlparser.LikeStr:          "LIKE",
+	sqlparser.NotLikeStr:       "NOT LIKE",
+}
+
+var binaryOps = map[string]struct {
+	op   string
+	prec int
+}{
+	sqlparser.PlusStr:  {"+", precAdd},
+	sqlparser.MinusStr: {"-", precAdd},
+	sqlparser.MultStr:  {"*", precMul},
+	sqlparser.DivStr:   {"/", precMul},
+	sqlparser.ModStr:   {"%", precMul},
+	// DuckDB parses // like the bitwise operators.
+	sqlparser.IntDivStr:     {"//", precOther},
+	sqlparser.BitAndStr:     {"&", precOther},
+	sqlparser.BitOrStr:      {"|", precOther},
+	sqlparser.ShiftLeftStr:  {"<<", precOther},
+	sqlparser.ShiftRightStr: {">>", precOther},
+}
+
+func (g *generator) exprs(exprs sqlparser.Exprs) {
+	for i, e := range exprs {
+		if i > 0 {
+			g.write(", ")
+		}
+		g.expr(e)
+	}
+}
+
+func (g *generator) expr(e sqlparser.Expr) {
+	switch e := e.(type) {
+	case *sqlparser.SQLVal:
+		g.literal(e)
+	case *sqlparser.NullVal:
+		g.write("NULL")
+	case sqlparser.BoolVal:
+		if e {
+			g.write("TRUE")
+		} else {
+			g.write("FALSE")
+		}
+	case *sqlparser.ColName:
+		g.column(e)
+	case *sqlparser.Default:
+		if e.ColName != "" {
+			g.unsupported("DEFAULT(column)")
+		}
+		g.write("DEFAULT")
+	case *sqlparser.ParenExpr:
+		g.write("(")
+		g.expr(e.Expr)
+		g.write(")")
+	case sqlparser.ValTuple:
+		g.write("(")
+		g.exprs(sqlparser.Exprs(e))
+		g.write(")")
+	case *sqlparser.Subquery:
+		g.write("(")
+		g.selectStatement(e.Select)
+		g.write(")")
+	case *sqlparser.ExistsExpr:
+		g.write("EXISTS ")
+		g.expr(e.Subquery)
+	case *sqlparser.OrExpr:
+		g.infix(e.Left, "OR", e.Right, precOr, false)
+	case *sqlparser.AndExpr:
+		g.infix(e.Left, "AND", e.Right, precAnd, false)
+	case *sqlparser.NotExpr:
+		g.write("NOT ")
+		g.operand(e.Expr, precNot)
+	case *sqlparser.IsExpr:
+		g.operand(e.Expr, precIs+1)
+		g.write(" ", strings.ToUpper(e.Operator))
+	case *sqlparser.ComparisonExpr:
+		g.comparison(e)
+	case *sqlparser.RangeCond:
+		g.operand(e.Left, precPredicate+1)
+		g.write(" ", strings.ToUpper(e.Operator), " ")
+		g.operand(e.From, precOther)
+		g.write(" AND ")
+		g.operand(e.To, precOther)
+	case *sqlparser.BinaryExpr:
+		g.binary(e)
+	case *sqlparser.UnaryExpr:
+		g.unary(e)
+	case *sqlparser.CaseExpr:
+		g.write("CASE ")
+		if e.Expr != nil {
+			g.expr(e.Expr)
+			g.write(" ")
+		}
+		for _, when := range e.Whens {
+			g.write("WHEN ")
+			g.expr(when.Cond)
+			g.write(" THEN ")
+			g.expr(when.Val)
+			g.write(" ")
+		}
+		if e.Else != nil {
+			g.write("ELSE ")
+			g.expr(e.Else)
+			g.write(" ")
+		}
+		g.write("END")
+	case *sqlparser.IntervalExpr:
+		g.interval(e)
+	case *sqlparser.ConvertExpr:
+		typ, ok := castType(e.Type)
+		if !ok {
+			g.unsupported("casts to " + sqlparser.String(e.Type))
+		}
+		g.cast(e.Expr, typ)
+	case *sqlparser.FuncExpr:
+		g.function(e)
+	case *sqlparser.GroupConcatExpr:
+		g.groupConcat(e)
+	case *sqlparser.SubstrExpr:
+		g.write("substring(")
+		if e.Name != nil {
+			g.column(e.Name)
+		} else {
+			g.literal(e.StrVal)
+		}
+		g.write(", ")
+		g.expr(e.From)
+		if e.To != nil {
+			g.write(", ")
+			g.expr(e.To)
+		}
+		g.write(")")
+	case *sqlparser.TrimExpr:
+		g.trim(e)
+	case *sqlparser.ExtractFuncExpr:
+		unit, ok := extractUnits[strings.ToLower(e.Unit)]
+		if !ok {
+			g.unsupported("EXTRACT(" + strings.ToUpper(e.Unit) + ")")
+		}
+		g.write("EXTRACT(", unit, " FROM ")
+		g.expr(e.Expr)
+		g.write(")")
+	default:
+		g.unsupported(nodeName(e))
+	}
+}
+
+func (g *generator) literal(v *sqlparser.SQLVal) {
+	switch v.Type {
+	case sqlparser.StrVal:
+		g.str(string(v.Val))
+	case sqlparser.IntVal, sqlparser.FloatVal:
+		g.write(string(v.Val))
+	case sqlparser.ValArg:
+		// The parser names the ? placeholders :v1, :v2, ... in order.
+		n, err := strconv.Atoi(strings.TrimPrefix(string(v.Val), ":v"))
+		if err != nil || n != g.args+1 {
+			g.unsupported("named or reordered parameters")
+		}
+		g.args++
+		g.write("?")
+	default:
+		g.unsupported("hexadecimal and bit literals")
+	}
+}
+
+// str writes a string literal. DuckDB does not interpret backslashes.
+func (g *generator) str(s string) {
+	g.write("'", strings.ReplaceAll(s, "'", "''"), "'")
+}
+
+func (g *generator) column(c *sqlparser.ColName) {
+	name := c.Name.String()
+	if strings.HasPrefix(name, "@") {
+		g.unsupported("variables")
+	}
+	if !c.Qualifier.IsEmpty() {
+		g.tableName(c.Qualifier)
+		g.write(".")
+	}
+	g.ident(name)
+}
+
+func (g *generator) comparison(e *sqlparser.ComparisonExpr) {
+	op, ok := comparisonOps[e.Operator]
+	if !ok {
+		g.unsupported("the " + strings.ToUpper(e.Operator) + " operator")
+		return
+	}
+	switch e.Operator {
+	case sqlparser.InStr, sqlparser.NotInStr:
+		g.operand(e.Left, precPredicate+1)
+		g.write(" ", op, " ")
+		switch right := e.Right.(type) {
+		case sqlparser.ValTuple, *sqlparser.Subquery:
+			g.expr(right)
+		default:
+			g.unsupported(nodeName(right) + " in IN")
+		}
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		g.infix(e.Left, op, e.Right, precPredicate, true)
+		switch {
+		case e.Escape != nil:
+			g.write(" ESCAPE ")
+			g.expr(e.Escape)
+		case mayEscape(e.Right):
+			// The backslash is the default escape character of MySQL, and DuckDB has none.
+			g.write(` ESCAPE '\'`)
+		}
+	default:
+		g.infix(e.Left, op, e.Right, precedence(e), true)
+	}
+}
+
+// mayEscape reports whether a LIKE pattern may contain a backslash.
+func mayEscape(pattern sqlparser.Expr) bool {
+	if v, ok := pattern.(*sqlparser.SQLVal); ok && v.Type == sqlparser.StrVal {
+		return strings.ContainsRune(string(v.Val), '\\')
+	}
+	return true
+}
+
+func (g *generator) binary(e *sqlparser.BinaryExpr) {
+	if e.Operator == sqlparser.BitXorStr {
+		// ^ is the power operator of DuckDB.
+		g.call("xor", e.Left, e.Right)
+		return
+	}
+	op, ok := binaryOps[e.Operator]
+	if !ok {
+		g.unsupported("the " + strings.ToUpper(e.Operator) + " operator")
+		return
+	}
+	if e.Operator == sqlparser.DivStr {
+		// Division by zero is NULL in MySQL, and infinite in DuckDB.
+		if v, ok := number(e.Right); !ok || v == 0 {
+			g.operand(e.Left, precMul)
+			g.write(" / nullif(")
+			g.expr(e.Right)
+			g.write(", 0)")
+			return
+		}
+	}
+	g.infix(e.Left, op.op, e.Right, op.prec, false)
+}
+
+func (g *generator) unary(e *sqlparser.UnaryExpr) {
+	switch e.Operator {
+	case sqlparser.UMinusStr:
+		// An operand that starts with a minus sign is parenthesized, as -- starts a comment.
+		g.write("-")
+		g.operand(e.Expr, precAtom)
+	case sqlparser.UPlusStr:
+		g.operand(e.Expr, precUnary)
+	case sqlparser.BangStr:
+		g.write("NOT ")
+		g.operand(e.Expr, precNot)
+	default:
+		// ~ works on unsigned integers in MySQL, and BINARY and the charset introducers have no equivalent.
+		g.unsupported("the " + strings.ToUpper(strings.TrimSpace(e.Operator)) + " operator")
+	}
+}
+
+var intervalUnits = map[string]string{
+	"microsecond": "MICROSECOND",
+	"second":      "SECOND",
+	"minute":      "MINUTE",
+	"hour":        "HOUR",
+	"day":         "DAY",
+	"week":        "WEEK",
+	"month":       "MONTH",
+	"year":        "YEAR",
+}
+
+func (g *generator) interval(e *sqlparser.IntervalExpr) {
+	unit, ok := intervalUnits[strings.ToLower(e.Unit)]
+	if !ok {
+		g.unsupported("INTERVAL ... " + strings.ToUpper(e.Unit))
+	}
+	g.write("INTERVAL ")
+	if v, ok := e.Expr.(*sqlparser.SQLVal); ok && v.Type == sqlparser.IntVal && precedence(v) == precAtom {
+		g.literal(v)
+	} else {
+		g.write("(")
+		g.expr(e.Expr)
+		g.write(")")
+	}
+	g.write(" ", unit)
+}
+
+var extractUnits = map[string]string{
+	"year":    "YEAR",
+	"quarter": "QUARTER",
+	"month":   "MONTH",
+	"day":     "DAY",
+	"hour":    "HOUR",
+	"minute":  "MINUTE",
+	"second":  "SECOND",
+}
+
+// castType returns the DuckDB type of a CAST or CONVERT.
+func castType(t *sqlparser.ConvertType) (string, bool) {
+	if t.Charset != "" {
+		return "", false
+	}
+	switch strings.ToLower(t.Type) {
+	case "signed", "signed integer":
+		return "BIGINT", true
+	case "unsigned", "unsigned integer":
+		return "UBIGINT", true
+	case "char", "nchar":
+		// CHAR(n) truncates the string, VARCHAR(n) of DuckDB does not.
+		return "VARCHAR", t.Length == nil
+	case "binary":
+		return "BLOB", t.Length == nil
+	case "date":
+		return "DATE", true
+	case "datetime":
+		return "TIMESTAMP", true
+	case "time":
+		return "TIME", true
+	case "double", "real":
+		return "DOUBLE", true
+	case "float":
+		return "FLOAT", t.Length == nil
+	case "json":
+		return "JSON", true
+	case "decimal":
+		precision, scale := "10", "0"
+		if t.Length != nil {
+			precision = string(t.Length.Val)
+			if t.Scale != nil {
+				scale = string(t.Scale.Val)
+			}
+		}
+		if p, err := strconv.Atoi(precision); err != nil || p > 38 {
+			return "", false
+		}
+		return "DECIMAL(" + precision + ", " + scale + ")", true
+	}
+	return "", false
+}
+
+func (g *generator) cast(e sqlparser.Expr, typ string) {
+	g.write("CAST(")
+	g.expr(e)
+	g.write(" AS ", typ, ")")
+}
+
+// call writes a function call with the given arguments.
+func (g *generator) call(name string, args ...sqlparser.Expr) {
+	g.write(name, "(")
+	g.exprs(args)
+	g.write(")")
+}
+
+func (g *generator) trim(e *sqlparser.TrimExpr) {
+	fn := map[string]string{sqlparser.Leading: "ltrim", sqlparser.Trailing: "rtrim", sqlparser.Both: "trim"}[e.Dir]
+	pattern, ok := e.Pattern.(*sqlparser.SQLVal)
+	switch {
+	case !ok || pattern.Type != sqlparser.StrVal || len(pattern.Val) != 1:
+		// MySQL removes a prefix or suffix, but DuckDB removes any of the characters.
+		g.unsupported("TRIM of several characters")
+	case string(pattern.Val) == " ":
+		g.call(fn, e.Str)
+	default:
+		g.call(fn, e.Str, pattern)
+	}
+}
+
+func (g *generator) groupConcat(e *sqlparser.GroupConcatExpr) {
+	if len(e.Exprs) != 1 {
+		g.unsupported("GROUP_CONCAT of several expressions")
+		return
+	}
+	arg, ok := e.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		g.unsupported("GROUP_CONCAT(*)")
+		return
+	}
+	sep := ","
+	if !e.Separator.DefaultSeparator {
+		sep = e.Separator.SeparatorString
+	}
+	g.write("string_agg(")
+	if e.Distinct != "" {
+		g.write("DISTINCT ")
+	}
+	g.stringArg(arg.Expr)
+	g.write(", ")
+	g.str(sep)
+	for i, o := range e.OrderBy {
+		if i == 0 {
+			g.write(" ORDER BY ")
+		} else {
+			g.write(", ")
+		}
+		g.order(o)
+	}
+	g.write(")")
+}
+
+// stringArg writes an argument of a string function, which MySQL converts to a string, but DuckDB does not.
+func (g *generator) stringArg(e sqlparser.Expr) {
+	if v, ok := e.(*sqlparser.SQLVal); ok && v.Type == sqlparser.StrVal {
+		g.literal(v)
+		return
+	}
+	g.cast(e, "VARCHAR")
+}
+
+func (g *generator) over(over *sqlparser.Over) {
+	if !over.NameRef.IsEmpty() || over.Frame != nil {
+		g.unsupported("named windows and window frames")
+	}
+	g.write(" OVER (")
+	if len(over.PartitionBy) > 0 {
+		g.write("PARTITION BY ")
+		g.exprs(over.PartitionBy)
+	}
+	for i, o := range over.OrderBy {
+		switch {
+		case i > 0:
+			g.write(", ")
+		case len(over.PartitionBy) > 0:
+			g.write(" ORDER BY ")
+		default:
+			g.write("ORDER BY ")
+		}
+		g.order(o)
+	}
+	g.write(")")
+}
+
+var plainIdent = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// ident writes an identifier, quoted unless it is lowercase and not a keyword of DuckDB.
+// Quoted identifiers are still case-insensitive in DuckDB.
+func (g *generator) ident(name string) {
+	if plainIdent.MatchString(name) && !duckdbKeywords[name] {
+		g.write(name)
+		return
+	}
+	g.write(`"`, strings.ReplaceAll(name, `"`, `""`), `"`)
+}
+
+// duckdbKeywords are the keywords of DuckDB that cannot be used as column or table names without quotes.
+var duckdbKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		all analyse analyze and any array as asc asymmetric both case cast check collate column constraint
+		create default deferrable desc describe distinct do else end except false fetch for foreign from
+		grant group having in initially intersect into lateral leading limit not null offset on only or
+		order pivot pivot_longer pivot_wider placing primary qualify references returning select show some
+		summarize symmetric table then to trailing true union unique unpivot using variadic when where
+		window with
+		anti asof authorization binary collation concurrently cross freeze full generated glob ilike inner
+		is isnull join left like map natural notnull outer overlaps positional right semi similar struct
+		tablesample try_cast verbose
+		between bigint bit boolean char character coalesce columns dec decimal exists extract float
+		grouping grouping_id inout int integer interval national nchar none nullif numeric out overlay
+		position precision real row setof smallint substring time timestamp treat trim values varchar
+		xmlattributes xmlconcat xmlelement xmlexists xmlforest xmlnamespaces xmlparse xmlpi xmlroot
+		xmlserialize xmltable`) {
+		duckdbKeywords[k] = true
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpiler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dolthub/vitess/go/vt/sqlparser"
+)
+
+type functionKind int
+
+const (
+	scalarFunction functionKind = iota
+	aggregateFunction
+	windowFunction // only valid with OVER
+)
+
+// function describes the DuckDB equivalent of a MySQL function.
+type function struct {
+	kind             functionKind
+	minArgs, maxArgs int // maxArgs < 0 for any number
+	// name is the DuckDB function taking the same arguments, unless write is set.
+	name  string
+	write func(g *generator, args []sqlparser.Expr)
+}
+
+func same(name string, minArgs, maxArgs int) function {
+	return function{minArgs: minArgs, maxArgs: maxArgs, name: name}
+}
+
+func aggregate(name string) function {
+	return function{kind: aggregateFunction, minArgs: 1, maxArgs: 1, name: name}
+}
+
+func window(name string, minArgs, maxArgs int) function {
+	return function{kind: windowFunction, minArgs: minArgs, maxArgs: maxArgs, name: name}
+}
+
+func rewrite(minArgs, maxArgs int, write func(g *generator, args []sqlparser.Expr)) function {
+	return function{minArgs: minArgs, maxArgs: maxArgs, write: write}
+}
+
+// keyword writes a function that takes no argument and is spelled without parentheses. The fractional
+// seconds precision of NOW(fsp) and the like is ignored.
+func keyword(kw string) func(g *generator, args []sqlparser.Expr) {
+	return func(g *generator, args []sqlparser.Expr) { g.write(kw) }
+}
+
+// functions maps the MySQL functions whose semantics DuckDB shares, for the arguments that they
+// commonly take. Functions that treat NULLs or out-of-range arguments differently, such as GREATEST,
+// which ignores NULLs in DuckDB, are left to sqlglot, unless a rewrite keeps the MySQL results.
+var functions map[string]function
+
+// The map is filled in init, as the rewrites refer to it through generator.expr.
+func init() {
+	functions = map[string]function{
+		// Numeric functions
+		"abs":     same("abs", 1, 1),
+		"ceil":    same("ceil", 1, 1),
+		"ceiling": same("ceil", 1, 1),
+		"floor":   same("floor", 1, 1),
+		"round":   same("round", 1, 2),
+		"sign":    same("sign", 1, 1),
+		"sqrt":    rewrite(1, 1, domain("sqrt", ">= 0", func(v float64) bool { return v >= 0 })),
+		"exp":     same("exp", 1, 1),
+		"ln":      rewrite(1, 1, domain("ln", "> 0", func(v float64) bool { return v > 0 })),
+		"log10":   rewrite(1, 1, domain("log10", "> 0", func(v float64) bool { return v > 0 })),
+		"log2":    rewrite(1, 1, domain("log2", "> 0", func(v float64) bool { return v > 0 })),
+		"pow":     same("power", 2, 2),
+		"power":   same("power", 2, 2),
+		"pi":      same("pi", 0, 0),
+		"degrees": same("degrees", 1, 1),
+		"radians": same("radians", 1, 1),
+		"sin":     same("sin", 1, 1),
+		"cos":     same("cos", 1, 1),
+		"tan":     same("tan", 1, 1),
+		"asin":    rewrite(1, 1, domain("asin", "BETWEEN -1 AND 1", func(v float64) bool { return v >= -1 && v <= 1 })),
+		"acos":    rewrite(1, 1, domain("acos", "BETWEEN -1 AND 1", func(v float64) bool { return v >= -1 && v <= 1 })),
+		"atan2":   same("atan2", 2, 2),
+		"rand":    same("random", 0, 0),
+		"mod": rewrite(2, 2, func(g *generator, args []sqlparser.Expr) {
+			g.write("(")
+			g.infix(args[0], "%", args[1], precMul, false)
+			g.write(")")
+		}),
+
+		// String functions
+		"lower":            same("lower", 1, 1),
+		"lcase":            same("lower", 1, 1),
+		"upper":            same("upper", 1, 1),
+		"ucase":            same("upper", 1, 1),
+		"length":           same("strlen", 1, 1), // in bytes
+		"octet_length":     same("strlen", 1, 1),
+		"char_length":      same("length", 1, 1),
+		"character_length": same("length", 1, 1),
+		"substring":        rewrite(2, 3, substring),
+		"substr":           rewrite(2, 3, substring),
+		"mid":              rewrite(3, 3, substring),
+		"left":             rewrite(2, 2, length("left")),
+		"right":            rewrite(2, 2, length("right")),
+		"lpad":             rewrite(3, 3, pad("lpad")),
+		"rpad":             rewrite(3, 3, pad("rpad")),
+		"ltrim":            same("ltrim", 1, 1),
+		"rtrim":            same("rtrim", 1, 1),
+		"repeat":           same("repeat", 2, 2),
+		"replace":          same("replace", 3, 3),
+		"reverse":          same("reverse", 1, 1),
+		"ascii":            same("ascii", 1, 1),
+		"instr":            same("instr", 2, 2),
+		"locate": rewrite(2, 2, func(g *generator, args []sqlparser.Expr) {
+			g.call("instr", args[1], args[0])
+		}),
+		"space": rewrite(1, 1, func(g *generator, args []sqlparser.Expr) {
+			g.write("repeat(' ', ")
+			g.expr(args[0])
+			g.write(")")
+		}),
+		// CONCAT returns NULL if any argument is NULL, like || and unlike concat() in DuckDB.
+		"concat": rewrite(1, -1, func(g *generator, args []sqlparser.Expr) {
+			g.write("(")
+			for i, arg := range args {
+				if i > 0 {
+					g.write(" || ")
+				}
+				g.stringArg(arg)
+			}
+			g.write(")")
+		}),
+		"concat_ws": rewrite(2, -1, func(g *generator, args []sqlparser.Expr) {
+			g.write("concat_ws(")
+			for i, arg := range args {
+				if i > 0 {
+					g.write(", ")
+				}
+				g.stringArg(arg)
+			}
+			g.write(")")
+		}),
+
+		// Control flow functions
+		"if":       same("if", 3, 3),
+		"ifnull":   same("coalesce", 2, 2),
+		"coalesce": same("coalesce", 1, -1),
+		"nullif":   same("nullif", 2, 2),
+
+		// Date and time functions
+		"now":               rewrite(0, 1, keyword("CURRENT_TIMESTAMP")),
+		"current_timestamp": rewrite(0, 1, keyword("CURRENT_TIMESTAMP")),
+		"localtime":         rewrite(0, 1, keyword("CURRENT_TIMESTAMP")),
+		"localtimestamp":    rewrite(0, 1, keyword("CURRENT_TIMESTAMP")),
+		"curdate":           rewrite(0, 0, keyword("CURRENT_DATE")),
+		"current_date":      rewrite(0, 0, keyword("CURRENT_DATE")),
+		"year":              same("year", 1, 1),
+		"quarter":           same("quarter", 1, 1),
+		"month":             same("month", 1, 1),
+		"day":               same("day", 1, 1),
+		"dayofmonth":        same("day", 1, 1),
+		"dayofyear":         same("dayofyear", 1, 1),
+		"hour":              same("hour", 1, 1),
+		"minute":            same("minute", 1, 1),
+		"second":            same("second", 1, 1),
+		"last_day":          same("last_day", 1, 1),
+		"date": rewrite(1, 1, func(g *generator, args []sqlparser.Expr) {
+			g.cast(args[0], "DATE")
+		}),
+		"datediff": rewrite(2, 2, func(g *generator, args []sqlparser.Expr) {
+			g.write("date_diff('day', ")
+			g.cast(args[1], "DATE")
+			g.write(", ")
+			g.cast(args[0], "DATE")
+			g.write(")")
+		}),
+		"date_add": rewrite(2, 2, dateArithmetic("+")),
+		"adddate":  rewrite(2, 2, dateArithmetic("+")),
+		"date_sub": rewrite(2, 2, dateArithmetic("-")),
+		"subdate":  rewrite(2, 2, dateArithmetic("-")),
+
+		// Aggregate functions
+		"count":       aggregate("count"),
+		"sum":         aggregate("sum"),
+		"avg":         aggregate("avg"),
+		"min":         aggregate("min"),
+		"max":         aggregate("max"),
+		"bit_and":     aggregate("bit_and"),
+		"bit_or":      aggregate("bit_or"),
+		"bit_xor":     aggregate("bit_xor"),
+		"std":         aggregate("stddev_pop"),
+		"stddev":      aggregate("stddev_pop"),
+		"stddev_pop":  aggregate("stddev_pop"),
+		"stddev_samp": aggregate("stddev_samp"),
+		"variance":    aggregate("var_pop"),
+		"var_pop":     aggregate("var_pop"),
+		"var_samp":    aggregate("var_samp"),
+
+		// Window functions
+		"row_number":   window("row_number", 0, 0),
+		"rank":         window("rank", 0, 0),
+		"dense_rank":   window("dense_rank", 0, 0),
+		"percent_rank": window("percent_rank", 0, 0),
+		"cume_dist":    window("cume_dist", 0, 0),
+		"ntile":        window("ntile", 1, 1),
+		"lag":          window("lag", 1, 3),
+		"lead":         window("lead", 1, 3),
+		"first_value":  window("first_value", 1, 1),
+		"last_value":   window("last_value", 1, 1),
+	}
+}
+
+// dateArithmetic writes DATE_ADD and DATE_SUB with an INTERVAL, which DuckDB spells with + and -.
+func dateArithmetic(op string) func(g *generator, args []sqlparser.Expr) {
+	return func(g *generator, args []sqlparser.Expr) {
+		if _, ok := args[1].(*sqlparser.IntervalExpr); !ok {
+			g.unsupported("date arithmetic with a number of days")
+			return
+		}
+		g.write("(")
+		g.infix(args[0], op, args[1], precAdd, false)
+		g.write(")")
+	}
+}
+
+// reusable reports whether |e| may be written twice in a rewrite: a column, or a literal other than
+// a parameter, which would then take two arguments.
+func reusable(e sqlparser.Expr) bool {
+	switch e := e.(type) {
+	case *sqlparser.ColName, *sqlparser.NullVal, sqlparser.BoolVal:
+		return true
+	case *sqlparser.SQLVal:
+		return e.Type != sqlparser.ValArg
+	case *sqlparser.UnaryExpr:
+		return e.Operator == sqlparser.UMinusStr && reusable(e.Expr)
+	}
+	return false
+}
+
+// number returns the value of a numeric literal.
+func number(e sqlparser.Expr) (float64, bool) {
+	switch e := e.(type) {
+	case *sqlparser.SQLVal:
+		if e.Type != sqlparser.IntVal && e.Type != sqlparser.FloatVal {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(string(e.Val), 64)
+		return v, err == nil
+	case *sqlparser.UnaryExpr:
+		if e.Operator == sqlparser.UMinusStr {
+			v, ok := number(e.Expr)
+			return -v, ok
+		}
+	}
+	return 0, false
+}
+
+// guard writes |e| if it satisfies |cond|, and NULL otherwise.
+func (g *generator) guard(e sqlparser.Expr, cond string) {
+	g.write("CASE WHEN ")
+	g.operand(e, precPredicate+1)
+	g.write(" ", cond, " THEN ")
+	g.expr(e)
+	g.write(" END")
+}
+
+// domain writes a math function that returns NULL in MySQL, but fails in DuckDB, out of its domain.
+func domain(name, cond string, in func(float64) bool) func(g *generator, args []sqlparser.Expr) {
+	return func(g *generator, args []sqlparser.Expr) {
+		if v, ok := number(args[0]); ok && in(v) {
+			g.call(name, args[0])
+			return
+		}
+		if !reusable(args[0]) {
+			g.unsupported(strings.ToUpper(name) + " of a computed argument")
+			return
+		}
+		g.write(name, "(")
+		g.guard(args[0], cond)
+		g.write(")")
+	}
+}
+
+// length writes LEFT and RIGHT, which return an empty string for a negative length in MySQL,
+// but cut the string from the other end in DuckDB.
+func length(name string) func(g *generator, args []sqlparser.Expr) {
+	return func(g *generator, args []sqlparser.Expr) {
+		n := args[1]
+		if v, ok := number(n); ok && v >= 0 {
+			g.call(name, args...)
+			return
+		}
+		if !reusable(n) {
+			g.unsupported(strings.ToUpper(name) + " with a computed length")
+			return
+		}
+		g.write(name, "(")
+		g.expr(args[0])
+		g.write(", CASE WHEN ")
+		g.operand(n, precCompare+1)
+		g.write(" < 0 THEN 0 ELSE ")
+		g.expr(n)
+		g.write(" END)")
+	}
+}
+
+// pad writes LPAD and RPAD, which return NULL for a negative length in MySQL, and an empty string in DuckDB.
+// DuckDB fails to pad with an empty string.
+func pad(name string) func(g *generator, args []sqlparser.Expr) {
+	return func(g *generator, args []sqlparser.Expr) {
+		if p, ok := args[2].(*sqlparser.SQLVal); !ok || p.Type != sqlparser.StrVal || len(p.Val) == 0 {
+			g.unsupported(strings.ToUpper(name) + " with a computed padding")
+			return
+		}
+		n := args[1]
+		if v, ok := number(n); ok && v >= 0 {
+			g.call(name, args...)
+			return
+		}
+		if !reusable(n) {
+			g.unsupported(strings.ToUpper(name) + " with a computed length")
+			return
+		}
+		g.write(name, "(")
+		g.expr(args[0])
+		g.write(", ")
+		g.guard(n, ">= 0")
+		g.write(", ")
+		g.expr(args[2])
+		g.write(")")
+	}
+}
+
+// substring writes SUBSTRING, which returns an empty string in MySQL if the position is 0 or before
+// the start of the string, or if the length is less than 1. DuckDB counts from the position
+// to the start of the string instead. With a positive position and length, both agree.
+func substring(g *generator, args []sqlparser.Expr) {
+	pos, ok := number(args[1])
+	if ok && pos >= 1 {
+		if len(args) == 2 {
+			g.call("substring", args...)
+			return
+		}
+		if n, ok := number(args[2]); ok && n >= 0 {
+			g.call("substring", args...)
+			return
+		}
+	}
+	for _, arg := range args {
+		if !reusable(arg) {
+			g.unsupported("SUBSTRING with computed arguments")
+			return
+		}
+	}
+
+	// The empty string is substring(s, p, 0), which is NULL if an argument is.
+	g.write("CASE WHEN ")
+	g.operand(args[1], precCompare+1)
+	g.write(" = 0 OR ")
+	g.operand(args[1], precCompare+1)
+	g.write(" < -length(")
+	g.expr(args[0])
+	g.write(")")
+	if len(args) == 3 {
+		g.write(" OR ")
+		g.operand(args[2], precCompare+1)
+		g.write(" < 1")
+	}
+	g.write(" THEN substring(")
+	g.expr(args[0])
+	g.write(", ")
+	g.expr(args[1])
+	if len(args) == 3 {
+		g.write(", ")
+		g.operand(args[2], precMul)
+		g.write(" * 0) ELSE ")
+	} else {
+		g.write(", 0) ELSE ")
+	}
+	g.call("substring", args...)
+	g.write(" END")
+}
+
+func (g *generator) function(f *sqlparser.FuncExpr) {
+	name := f.Name.Lowered()
+	fn, ok := functions[name]
+	if !ok || !f.Qualifier.IsEmpty() {
+		g.unsupported("the function " + strings.ToUpper(name))
+		return
+	}
+
+	var (
+		args []sqlparser.Expr
+		star bool
+	)
+	for _, arg := range f.Exprs {
+		switch arg := arg.(type) {
+		case *sqlparser.AliasedExpr:
+			args = append(args, arg.Expr)
+		case *sqlparser.StarExpr:
+			star = name == "count" && arg.TableName.IsEmpty() && len(f.Exprs) == 1
+			if !star {
+				g.unsupported("* in " + strings.ToUpper(name))
+				return
+			}
+		default:
+			g.unsupported(nodeName(arg) + " in " + strings.ToUpper(name))
+			return
+		}
+	}
+	switch {
+	case !star && (len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs):
+		g.unsupported(strings.ToUpper(name) + " with " + pluralArgs(len(args)))
+	case f.Distinct && fn.kind != aggregateFunction:
+		g.unsupported("DISTINCT in " + strings.ToUpper(name))
+	case f.Over != nil && fn.kind == scalarFunction:
+		g.unsupported(strings.ToUpper(name) + " as a window function")
+	case f.Over == nil && fn.kind == windowFunction:
+		g.unsupported(strings.ToUpper(name) + " without OVER")
+	case f.Distinct && f.Over != nil:
+		g.unsupported("DISTINCT in window functions")
+	}
+
+	if fn.write != nil {
+		fn.write(g, args)
+		return
+	}
+	g.write(fn.name, "(")
+	if f.Distinct {
+		g.write("DISTINCT ")
+	}
+	if star {
+		g.write("*")
+	}
+	g.exprs(args)
+	g.write(")")
+	if f.Over != nil {
+		g.over(f.Over)
+	}
+}
+
+func pluralArgs(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(n) + " arguments"
+}
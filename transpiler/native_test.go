@@ -0,0 +1,207 @@
+package transpiler
+
+import (
+	stdsql "database/sql"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestTranslateNative(t *testing.T) {
+	tests := []struct {
+		mysql  string
+		duckdb string
+	}{
+		// Identifiers, literals and operators
+		{"SELECT `Id`, `order`, `a b` FROM `db`.`t` AS `x`", `SELECT "Id", "order", "a b" FROM db.t AS x`},
+		{`SELECT 'it''s', "say \"hi\"", 'a\nb', 1.50, -2, NULL, TRUE`, "SELECT 'it''s', 'say \"hi\"', 'a\nb', 1.50, -2, NULL, TRUE"},
+		{"SELECT a FROM t WHERE a || b && !c", "SELECT a FROM t WHERE a OR b AND NOT c"},
+		{"SELECT a DIV 2, a ^ b, a | b & c, a - -1, - (a + 1) FROM t", "SELECT a // 2, xor(a, b), a | (b & c), a - -1, -(a + 1) FROM t"},
+		{"SELECT * FROM t WHERE a <=> NULL AND b != 1 AND c NOT BETWEEN 1 AND 2 AND d IS NOT TRUE", "SELECT * FROM t WHERE a IS NOT DISTINCT FROM NULL AND b <> 1 AND c NOT BETWEEN 1 AND 2 AND d IS NOT TRUE"},
+		{"SELECT * FROM t WHERE a LIKE 'x%' AND b NOT LIKE c AND d LIKE 'y!_' ESCAPE '!'", `SELECT * FROM t WHERE a LIKE 'x%' AND b NOT LIKE c ESCAPE '\' AND d LIKE 'y!_' ESCAPE '!'`},
+		{"SELECT * FROM t WHERE a = ? AND b IN (?, ?)", "SELECT * FROM t WHERE a = ? AND b IN (?, ?)"},
+
+		// Clauses
+		{"SELECT DISTINCT a, COUNT(*) FROM t GROUP BY a HAVING COUNT(*) > 1 ORDER BY a, 2 DESC LIMIT 5, 10", "SELECT DISTINCT a, count(*) FROM t GROUP BY a HAVING count(*) > 1 ORDER BY a ASC NULLS FIRST, 2 DESC NULLS LAST LIMIT 10 OFFSET 5"},
+		{"SELECT * FROM a JOIN b ON a.id = b.id LEFT JOIN c USING (id) CROSS JOIN d", "SELECT * FROM a JOIN b ON a.id = b.id LEFT JOIN c USING (id) CROSS JOIN d"},
+		{"SELECT x.* FROM (SELECT 1 AS one) AS x(y) WHERE EXISTS (SELECT 1 FROM t WHERE t.a = x.y)", "SELECT x.* FROM (SELECT 1 AS one) AS x(y) WHERE EXISTS (SELECT 1 FROM t WHERE t.a = x.y)"},
+		{"WITH c (n) AS (SELECT 1) SELECT n FROM c UNION ALL SELECT 2 ORDER BY 1 LIMIT 3", "WITH c(n) AS (SELECT 1) SELECT n FROM c UNION ALL SELECT 2 ORDER BY 1 ASC NULLS FIRST LIMIT 3"},
+		{"SELECT a FROM t USE INDEX (i) WHERE a IN (SELECT b FROM u) ORDER BY NULL", "SELECT a FROM t WHERE a IN (SELECT b FROM u)"},
+
+		// Functions
+		{"SELECT IFNULL(a, 0), IF(a > 0, 'p', 'n'), LCASE(s), LENGTH(s), CHAR_LENGTH(s), LOCATE('x', s) FROM t", "SELECT coalesce(a, 0), if(a > 0, 'p', 'n'), lower(s), strlen(s), length(s), instr(s, 'x') FROM t"},
+		{"SELECT CONCAT('#', id, name), SUBSTRING(s, 2, 3), TRIM(s), TRIM(LEADING 'x' FROM s) FROM t", "SELECT ('#' || CAST(id AS VARCHAR) || CAST(name AS VARCHAR)), substring(s, 2, 3), trim(s), ltrim(s, 'x') FROM t"},
+		{"SELECT NOW(), CURDATE(), DATE(ts), DATE_ADD(d, INTERVAL 1 DAY), d - INTERVAL n HOUR, EXTRACT(YEAR FROM d) FROM t", "SELECT CURRENT_TIMESTAMP, CURRENT_DATE, CAST(ts AS DATE), (d + INTERVAL 1 DAY), d - INTERVAL (n) HOUR, EXTRACT(YEAR FROM d) FROM t"},
+		{"SELECT CAST(a AS SIGNED), CONVERT(b, CHAR), CAST(c AS DECIMAL(12, 2)), CAST(d AS DATETIME) FROM t", "SELECT CAST(a AS BIGINT), CAST(b AS VARCHAR), CAST(c AS DECIMAL(12, 2)), CAST(d AS TIMESTAMP) FROM t"},
+		{"SELECT COUNT(DISTINCT a), STDDEV(b), GROUP_CONCAT(DISTINCT c ORDER BY c DESC SEPARATOR ';') FROM t", "SELECT count(DISTINCT a), stddev_pop(b), string_agg(DISTINCT CAST(c AS VARCHAR), ';' ORDER BY c DESC NULLS LAST) FROM t"},
+		{"SELECT SUBSTRING(s, 0), MID(s, p, 2), LEFT(s, -1), RIGHT(s, n), LPAD(s, n, '0') FROM t", "SELECT CASE WHEN 0 = 0 OR 0 < -length(s) THEN substring(s, 0, 0) ELSE substring(s, 0) END, CASE WHEN p = 0 OR p < -length(s) OR 2 < 1 THEN substring(s, p, 2 * 0) ELSE substring(s, p, 2) END, left(s, CASE WHEN -1 < 0 THEN 0 ELSE -1 END), right(s, CASE WHEN n < 0 THEN 0 ELSE n END), lpad(s, CASE WHEN n >= 0 THEN n END, '0') FROM t"},
+		{"SELECT LN(0), LOG10(x), SQRT(4), ASIN(x), a / b, a / 2, a / 0 FROM t", "SELECT ln(CASE WHEN 0 > 0 THEN 0 END), log10(CASE WHEN x > 0 THEN x END), sqrt(4), asin(CASE WHEN x BETWEEN -1 AND 1 THEN x END), a / nullif(b, 0), a / 2, a / nullif(0, 0) FROM t"},
+		{"SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b), SUM(c) OVER () FROM t", "SELECT row_number() OVER (PARTITION BY a ORDER BY b ASC NULLS FIRST), sum(c) OVER () FROM t"},
+
+		// DML
+		{"INSERT INTO t (a, b) VALUES (1, 'x'), (2, DEFAULT)", "INSERT INTO t (a, b) VALUES (1, 'x'), (2, DEFAULT)"},
+		{"INSERT IGNORE INTO db.t SELECT * FROM u", "INSERT OR IGNORE INTO db.t SELECT * FROM u"},
+		{"REPLACE INTO t VALUES (1)", "INSERT OR REPLACE INTO t VALUES (1)"},
+		{"UPDATE t SET t.a = a + 1, b = NULL WHERE c = 'x'", "UPDATE t SET a = a + 1, b = NULL WHERE c = 'x'"},
+		{"DELETE FROM t WHERE a < 10;", "DELETE FROM t WHERE a < 10"},
+	}
+	for _, tt := range tests {
+		got, err := translateNative(tt.mysql)
+		if err != nil {
+			t.Errorf("translateNative(%q) failed: %v", tt.mysql, err)
+		} else if got != tt.duckdb {
+			t.Errorf("translateNative(%q) =\n%s\nwant\n%s", tt.mysql, got, tt.duckdb)
+		}
+	}
+}
+
+func TestTranslateNativeUnsupported(t *testing.T) {
+	for _, sql := range []string{
+		"CREATE TABLE t (a INT)",
+		"SELECT 1; SELECT 2",
+		"SELECT * FROM t WHERE d > DATE '2024-01-01'",
+		`SELECT * FROM t WHERE a LIKE 'a\_b'`,
+		"SELECT @@version, @v",
+		"SELECT GREATEST(a, b) FROM t",
+		"SELECT x'AB', 0x1F",
+		"SELECT a COLLATE utf8mb4_bin FROM t",
+		"SELECT * FROM t LIMIT ?, ?",
+		"SELECT * FROM t FOR UPDATE",
+		"INSERT INTO t VALUES (1) ON DUPLICATE KEY UPDATE a = 2",
+		"UPDATE t SET a = 1 ORDER BY b LIMIT 1",
+		"DELETE t FROM t JOIN u ON t.a = u.a",
+		"SELECT IF(a, 1) FROM t",
+		"SELECT SUM(a) OVER (ORDER BY b ROWS 1 PRECEDING) FROM t",
+		"SELECT TRIM(LEADING 'ab' FROM s) FROM t",
+		"SELECT CAST(a AS CHAR(3)) FROM t",
+		"SELECT SUBSTRING(CONCAT(a, b), n) FROM t",
+		"SELECT LEFT(s, ?) FROM t",
+		"SELECT LN(a + 1) FROM t",
+		"SELECT LPAD(s, 3, p) FROM t",
+	} {
+		if got, err := translateNative(sql); !errNotSupported.Is(err) {
+			t.Errorf("translateNative(%q) = %q, %v; want errNotSupported", sql, got, err)
+		}
+	}
+}
+
+func TestTranslateNoPython(t *testing.T) {
+	SetOptions(Options{Native: true, NoPython: true})
+	defer SetOptions(Options{Native: true})
+
+	if got, err := Translate("SELECT `a` FROM t"); err != nil || got != "SELECT a FROM t" {
+		t.Errorf("unexpected translation %q, %v", got, err)
+	}
+	if _, err := Translate("SELECT GREATEST(a, b) FROM t"); !errNotSupported.Is(err) {
+		t.Errorf("expected the native translator's error, got %v", err)
+	}
+}
+
+// TestNativeSemantics runs the translations in DuckDB and checks that they give the results of MySQL,
+// notably for NULLs and out-of-range arguments.
+func TestNativeSemantics(t *testing.T) {
+	db, err := stdsql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t AS SELECT 'abcde' AS s, 0 AS z, -1 AS m, 2 AS two, NULL::VARCHAR AS ns, NULL::INT AS ni"); err != nil {
+		t.Fatal(err)
+	}
+
+	null := "NULL"
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"SUBSTRING('abcde', 2, 3)", "bcd"},
+		{"SUBSTRING('abcde', 0)", ""},
+		{"SUBSTRING('abcde', 0, 2)", ""},
+		{"SUBSTRING('abcde', -7)", ""},
+		{"SUBSTRING('abcde', -7, 3)", ""},
+		{"SUBSTRING('abcde', -2)", "de"},
+		{"SUBSTRING('abcde', -2, 1)", "d"},
+		{"SUBSTRING('abcde', 2, -1)", ""},
+		{"SUBSTRING('abcde', 2, 0)", ""},
+		{"SUBSTRING('abcde', NULL)", null},
+		{"SUBSTRING(NULL, 0)", null},
+		{"SUBSTRING('abcde', 0, NULL)", null},
+		{"SUBSTRING('abcde', NULL, 0)", null},
+		{"SUBSTRING(s, z) FROM t", ""},
+		{"SUBSTRING(s, two, m) FROM t", ""},
+		{"SUBSTRING(s, m) FROM t", "e"},
+		{"SUBSTRING(ns, z) FROM t", null},
+		{"SUBSTRING(s, two, ni) FROM t", null},
+		{"MID('abcde', 0, 2)", ""},
+		{"LEFT('abc', -1)", ""},
+		{"RIGHT('abc', -1)", ""},
+		{"LEFT('abc', 2)", "ab"},
+		{"LEFT('abc', NULL)", null},
+		{"LEFT(NULL, 1)", null},
+		{"LEFT(s, m) FROM t", ""},
+		{"RIGHT(s, ni) FROM t", null},
+		{"LPAD('a', 3, 'x')", "xxa"},
+		{"LPAD('a', -1, 'x')", null},
+		{"RPAD(s, m, 'x') FROM t", null},
+		{"LN(1)", "0"},
+		{"LN(0)", null},
+		{"LN(-1)", null},
+		{"LN(NULL)", null},
+		{"LOG10(z) FROM t", null},
+		{"LOG2(-1)", null},
+		{"SQRT(4)", "2"},
+		{"SQRT(-1)", null},
+		{"SQRT(m) FROM t", null},
+		{"ASIN(2)", null},
+		{"ACOS(-2)", null},
+		{"ACOS(1)", "0"},
+		{"5 / 0", null},
+		{"5 / z FROM t", null},
+		{"5 / NULL", null},
+		{"MOD(5, 0)", null},
+		{"5 DIV 0", null},
+		{"CONCAT('a', NULL)", null},
+		{"CONCAT_WS(',', 'a', NULL, 'b')", "a,b"},
+		{"IFNULL(NULL, 1)", "1"},
+		{"IF(NULL, 1, 2)", "2"},
+		{"NULLIF(1, 1)", null},
+		{"REPEAT('a', -1)", ""},
+		{"LOCATE('', 'abc')", "1"},
+	}
+	for _, tt := range tests {
+		sql := "SELECT " + tt.expr
+		translated, err := translateNative(sql)
+		if err != nil {
+			t.Errorf("translateNative(%q) failed: %v", sql, err)
+			continue
+		}
+		var got stdsql.NullString
+		if err := db.QueryRow(translated).Scan(&got); err != nil {
+			t.Errorf("%q, translated to %q, failed: %v", sql, translated, err)
+			continue
+		}
+		if !got.Valid {
+			got.String = null
+		}
+		if got.String != tt.want {
+			t.Errorf("%q, translated to %q, = %q; want %q", sql, translated, got.String, tt.want)
+		}
+	}
+}
+
+func TestSetOptionsFlushesCache(t *testing.T) {
+	defer SetOptions(Options{Native: true})
+
+	FlushCache()
+	if _, err := Translate("SELECT `a` FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	SetOptions(Options{Native: true})
+	if n := GetCacheStats().Size; n != 1 {
+		t.Errorf("the cache holds %d translations after setting the same options; want 1", n)
+	}
+	SetOptions(Options{Native: false})
+	if n := GetCacheStats().Size; n != 0 {
+		t.Errorf("the cache holds %d translations after changing the options; want 0", n)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpiler
+
+import (
+	"strings"
+)
+
+// normalizedQuery is a MySQL query whose literals are replaced by placeholders.
+type normalizedQuery struct {
+	text     string   // the query with placeholders, which sqlglot translates
+	key      string   // the cache key, which tells numbers from strings
+	literals []string // the replaced literals, as written in the query
+}
+
+// normalizeQuery replaces the integer, decimal and single-quoted string literals of |sql| with
+// placeholders. Literals are kept as they are where sqlglot may rewrite them: strings with escapes,
+// which are requoted for DuckDB, and strings with a percent sign, which may be the format of a date
+// function. It returns false if the query has no literal to replace or already has placeholders.
+func normalizeQuery(sql string) (normalizedQuery, bool) {
+	var (
+		text  strings.Builder
+		kinds strings.Builder
+		lits  []string
+	)
+	text.Grow(len(sql))
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '?':
+			return normalizedQuery{}, false
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return normalizedQuery{}, false
+			}
+			end += i + 4
+			text.WriteString(sql[i:end])
+			i = end
+		case c == '#' || c == '-' && strings.HasPrefix(sql[i:], "-- "):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			text.WriteString(sql[i:end])
+			i = end
+		case c == '`' || c == '"':
+			end, _ := quotedEnd(sql, i)
+			if end < 0 {
+				return normalizedQuery{}, false
+			}
+			text.WriteString(sql[i:end])
+			i = end
+		case c == '\'':
+			end, escaped := quotedEnd(sql, i)
+			if end < 0 {
+				return normalizedQuery{}, false
+			}
+			lit := sql[i:end]
+			// A quote after a word is that of an introducer, e.g., _utf8mb4'a', or of a hex or bit literal.
+			if escaped || strings.ContainsRune(lit, '%') || i > 0 && isWordByte(sql[i-1]) {
+				text.WriteString(lit)
+			} else {
+				text.WriteByte('?')
+				kinds.WriteByte('s')
+				lits = append(lits, lit)
+			}
+			i = end
+		case isWordByte(c):
+			end := i
+			for end < len(sql) && isWordByte(sql[end]) {
+				end++
+			}
+			if isDigits(sql[i:end]) {
+				// A decimal, unless it is followed by more, as in 1.5e3.
+				if end+1 < len(sql) && sql[end] == '.' && isDigit(sql[end+1]) {
+					frac := end + 1
+					for frac < len(sql) && isWordByte(sql[frac]) {
+						frac++
+					}
+					if isDigits(sql[end+1 : frac]) {
+						end = frac
+					}
+				}
+				if (i == 0 || sql[i-1] != '.') && (end == len(sql) || sql[end] != '.') && isNumber(sql[i:end]) {
+					text.WriteByte('?')
+					kinds.WriteByte('n')
+					lits = append(lits, sql[i:end])
+					i = end
+					continue
+				}
+			}
+			text.WriteString(sql[i:end])
+			i = end
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	if len(lits) == 0 {
+		return normalizedQuery{}, false
+	}
+	return normalizedQuery{
+		text:     text.String(),
+		key:      text.String() + "\x00" + kinds.String(),
+		literals: lits,
+	}, true
+}
+
+// quotedEnd returns the end of the quoted string or identifier that starts at |start|,
+// or -1 if it is not terminated, and whether it contains a backslash or a doubled quote.
+func quotedEnd(sql string, start int) (int, bool) {
+	quote := sql[start]
+	escaped := false
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if quote != '`' {
+				escaped = true
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				escaped = true
+				i++
+				continue
+			}
+			return i + 1, escaped
+		}
+	}
+	return -1, escaped
+}
+
+// substitute replaces the placeholders of a translated query with |literals|, in order.
+// It returns false if the number of placeholders differs from that of the literals.
+func substitute(template string, literals []string) (string, bool) {
+	var b strings.Builder
+	b.Grow(len(template) + 16*len(literals))
+	n := 0
+	for i := 0; i < len(template); {
+		switch c := template[i]; c {
+		case '\'', '"':
+			end, _ := quotedEnd(template, i)
+			if end < 0 {
+				return "", false
+			}
+			b.WriteString(template[i:end])
+			i = end
+		case '?':
+			if n == len(literals) {
+				return "", false
+			}
+			b.WriteString(literals[n])
+			n++
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), n == len(literals)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// isNumber reports whether |s| is an integer or a decimal with digits on both sides of the point.
+func isNumber(s string) bool {
+	intPart, frac, found := strings.Cut(s, ".")
+	return isDigits(intPart) && (!found || isDigits(frac))
+}
@@ -0,0 +1,291 @@
+// Copyright 2024-2025 ApeCloud, Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transpiler
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apecloud/myduckserver/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// PoolOptions configures the pool of sqlglot processes.
+type PoolOptions struct {
+	// Workers is the maximum number of processes, which are started as concurrent translations need them.
+	// If it is not positive, it is the number of CPUs.
+	Workers int
+	// Timeout bounds a translation, including the wait for an idle process. It is unbounded if not positive.
+	Timeout time.Duration
+}
+
+// Restarts after consecutive failures are delayed from minRestartDelay, doubling up to maxRestartDelay.
+const (
+	minRestartDelay = 100 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+)
+
+// workerPool dispatches the translations to idle sqlglot processes, starting new ones up to
+// the maximum while all are busy. Once the maximum is reached, translations wait in turn.
+//
+// The pool supervises the processes: one that exits or hangs fails only the query it was
+// translating, and is restarted in the background. Consecutive failures to start or keep a
+// process running delay the restarts with an exponential backoff, during which translations
+// wait for a running process or for the restart.
+type workerPool struct {
+	mu      sync.Mutex
+	opts    PoolOptions
+	idle    []*translateService
+	started int                               // including the busy ones and those being started
+	waiters []chan *translateService          // in arrival order
+	newFn   func() (*translateService, error) // replaced in tests
+
+	failures   int       // consecutive failures, reset by a successful translation
+	retryAt    time.Time // no process is started before, after a failure
+	restarting bool      // whether the restart loop is running
+	lastErr    error     // the last failure to start a process
+}
+
+var workers = &workerPool{
+	opts:  PoolOptions{Timeout: 10 * time.Second},
+	newFn: newTranslateService,
+}
+
+// SetPoolOptions configures the pool of sqlglot processes. Processes beyond a reduced maximum exit once idle.
+func SetPoolOptions(opts PoolOptions) {
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	workers.opts = opts
+	for len(workers.idle) > 0 && workers.started > workers.maxWorkers() {
+		svc := workers.idle[len(workers.idle)-1]
+		workers.idle = workers.idle[:len(workers.idle)-1]
+		workers.started--
+		go svc.cleanup()
+	}
+	workers.updateMetrics()
+}
+
+// maxWorkers returns the maximum number of processes. p.mu must be held.
+func (p *workerPool) maxWorkers() int {
+	if p.opts.Workers > 0 {
+		return p.opts.Workers
+	}
+	return runtime.NumCPU()
+}
+
+func (p *workerPool) translate(sql string) (string, error) {
+	p.mu.Lock()
+	limit := p.opts.Timeout
+	p.mu.Unlock()
+
+	start := time.Now()
+	svc, err := p.acquire(limit)
+	if errTranslationTimeout.Is(err) {
+		metrics.ObserveTranslationTimeout()
+	}
+	if err != nil {
+		return "", err
+	}
+	timeout := limit
+	if limit > 0 {
+		if timeout -= time.Since(start); timeout <= 0 {
+			p.release(svc, false)
+			metrics.ObserveTranslationTimeout()
+			return "", errTranslationTimeout.New(limit)
+		}
+	}
+	translated, err := svc.translate(sql, timeout)
+	if errTranslationTimeout.Is(err) {
+		metrics.ObserveTranslationTimeout()
+		err = errTranslationTimeout.New(limit)
+	}
+	p.release(svc, svc.exit == "")
+	return translated, err
+}
+
+// acquire returns an idle process, starting one if the maximum has not been reached,
+// or waits up to |timeout| for one to be released.
+func (p *workerPool) acquire(timeout time.Duration) (*translateService, error) {
+	start := time.Now()
+	defer func() { metrics.ObserveTranslationWait(start) }()
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		svc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.updateMetrics()
+		p.mu.Unlock()
+		return svc, nil
+	}
+	if p.started < p.maxWorkers() {
+		if time.Now().Before(p.retryAt) {
+			if p.started == 0 && (timeout <= 0 || time.Until(p.retryAt) > timeout) {
+				// No process could be handed over in time.
+				err := p.lastErr
+				p.mu.Unlock()
+				return nil, errTranslatorUnavailable.New(err)
+			}
+			p.startRestartLoop()
+		} else {
+			p.started++
+			p.updateMetrics()
+			p.mu.Unlock()
+			svc, err := p.newFn()
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err != nil {
+				p.started--
+				p.fail(err)
+				return nil, errTranslatorUnavailable.New(err)
+			}
+			logrus.Debugf("Started a sqlglot process (pid %d)", svc.pyCmd.Process.Pid)
+			p.lastErr = nil
+			return svc, nil
+		}
+	}
+	ch := make(chan *translateService, 1)
+	p.waiters = append(p.waiters, ch)
+	p.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case svc := <-ch:
+		return svc, nil
+	case <-expired:
+		p.mu.Lock()
+		if i := slices.Index(p.waiters, ch); i >= 0 {
+			p.waiters = slices.Delete(p.waiters, i, i+1)
+			var err error = errTranslationTimeout.New(timeout)
+			if p.started == 0 && p.lastErr != nil {
+				err = errTranslatorUnavailable.New(p.lastErr)
+			}
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.mu.Unlock()
+		// A process was handed over in the meantime.
+		return <-ch, nil
+	}
+}
+
+// release returns a process to the pool, handing it over to the first waiting translation if any.
+// A process that has exited is replaced in the background.
+func (p *workerPool) release(svc *translateService, translated bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if svc.exit != "" {
+		p.started--
+		metrics.ObserveTranslationWorkerExit(svc.exit)
+		p.fail(fmt.Errorf("the process %s", map[string]string{"crash": "exited", "timeout": "hung"}[svc.exit]))
+		return
+	}
+	if translated {
+		p.failures = 0
+	}
+	if p.started > p.maxWorkers() {
+		p.started--
+		p.updateMetrics()
+		go svc.cleanup()
+		return
+	}
+	p.handOver(svc)
+}
+
+// handOver gives an idle process to the first waiting translation, or else keeps it idle. p.mu must be held.
+func (p *workerPool) handOver(svc *translateService) {
+	if len(p.waiters) > 0 {
+		ch := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		ch <- svc
+	} else {
+		p.idle = append(p.idle, svc)
+	}
+	p.updateMetrics()
+}
+
+// fail records a process that exited or failed to start, delays the next start and
+// starts the restart loop. p.mu must be held.
+func (p *workerPool) fail(err error) {
+	p.failures++
+	p.lastErr = err
+	delay := min(minRestartDelay<<min(p.failures-1, 16), maxRestartDelay)
+	p.retryAt = time.Now().Add(delay)
+	p.updateMetrics()
+	logrus.Warnf("sqlglot failed (%v); restarting it in %s", err, delay)
+	p.startRestartLoop()
+}
+
+// startRestartLoop starts the restart loop unless it is running. p.mu must be held.
+func (p *workerPool) startRestartLoop() {
+	if !p.restarting {
+		p.restarting = true
+		go p.restartLoop()
+	}
+}
+
+// restartLoop starts a process once the restart delay has passed, retrying until it succeeds,
+// so that a failed process is replaced even if no translation is waiting.
+func (p *workerPool) restartLoop() {
+	for {
+		p.mu.Lock()
+		delay := time.Until(p.retryAt)
+		p.mu.Unlock()
+		time.Sleep(delay)
+
+		p.mu.Lock()
+		if time.Now().Before(p.retryAt) {
+			p.mu.Unlock()
+			continue // another failure postponed the restart
+		}
+		if p.started >= p.maxWorkers() {
+			p.restarting = false
+			p.mu.Unlock()
+			return
+		}
+		p.started++
+		p.updateMetrics()
+		p.mu.Unlock()
+
+		svc, err := p.newFn()
+		metrics.ObserveTranslationWorkerRestart(err)
+		p.mu.Lock()
+		if err != nil {
+			p.started--
+			p.restarting = false // fail starts it again
+			p.fail(err)
+			p.mu.Unlock()
+			return
+		}
+		logrus.Infof("Restarted sqlglot (pid %d)", svc.pyCmd.Process.Pid)
+		p.lastErr = nil
+		p.restarting = false
+		p.handOver(svc)
+		p.mu.Unlock()
+		return
+	}
+}
+
+// updateMetrics reports the number of processes. p.mu must be held.
+func (p *workerPool) updateMetrics() {
+	metrics.SetTranslationWorkers(p.started, p.started-len(p.idle))
+}
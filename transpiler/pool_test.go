@@ -0,0 +1,186 @@
+package transpiler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const fakeSQLGlotEnv = "MYDUCK_FAKE_SQLGLOT"
+
+// TestHelperProcess is the fake sqlglot process started by newFakeService. It echoes the queries,
+// except "SLEEP <duration>", after which it answers, "HANG", to which it never does, and "CRASH".
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(fakeSQLGlotEnv) {
+	case "1":
+	case "missing":
+		// Fail on startup as Python does without the sqlglot module.
+		fmt.Fprintln(os.Stderr, "Traceback (most recent call last):")
+		fmt.Fprintln(os.Stderr, `  File "<string>", line 3, in <module>`)
+		fmt.Fprintln(os.Stderr, "ModuleNotFoundError: No module named 'sqlglot'")
+		os.Exit(1)
+	default:
+		return
+	}
+	stdin := bufio.NewReader(os.Stdin)
+	for {
+		cmd, err := recvString(stdin)
+		if err != nil || cmd == cmdExit {
+			os.Exit(0)
+		}
+		sql := strings.TrimPrefix(cmd, cmdRun)
+		if arg, ok := strings.CutPrefix(sql, "SLEEP "); ok {
+			d, _ := time.ParseDuration(arg)
+			time.Sleep(d)
+		}
+		switch sql {
+		case "HANG":
+			select {}
+		case "CRASH":
+			os.Exit(1)
+		}
+		sendString(os.Stdout, resultOK+sql)
+	}
+}
+
+func newFakeService() (*translateService, error) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), fakeSQLGlotEnv+"=1")
+	return startTranslateService(cmd)
+}
+
+func TestStartupError(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), fakeSQLGlotEnv+"=missing")
+	_, err := startTranslateService(cmd)
+	if err == nil {
+		t.Fatal("expected the process to fail to start")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "ModuleNotFoundError: No module named 'sqlglot'") || strings.Contains(msg, "restarted") {
+		t.Errorf("expected the cause from stderr, got %q", msg)
+	}
+}
+
+func newTestPool(t *testing.T, opts PoolOptions) *workerPool {
+	p := &workerPool{opts: opts, newFn: newFakeService}
+	t.Cleanup(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, svc := range p.idle {
+			svc.cleanup()
+		}
+	})
+	return p
+}
+
+func TestPoolConcurrency(t *testing.T) {
+	p := newTestPool(t, PoolOptions{Workers: 3, Timeout: 10 * time.Second})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sql := fmt.Sprintf("SLEEP %dms", 50+i)
+			if got, err := p.translate(sql); err != nil || got != sql {
+				t.Errorf("translate(%q) = %q, %v", sql, got, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p.started != 3 || len(p.idle) != 3 || len(p.waiters) != 0 {
+		t.Errorf("expected 3 idle processes, got %d started, %d idle, %d waiting", p.started, len(p.idle), len(p.waiters))
+	}
+}
+
+func TestPoolTimeout(t *testing.T) {
+	p := newTestPool(t, PoolOptions{Workers: 1, Timeout: 500 * time.Millisecond})
+
+	if _, err := p.translate("HANG"); !errTranslationTimeout.Is(err) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if p.started != 0 || len(p.idle) != 0 {
+		t.Fatalf("expected the hung process to be discarded, got %d started, %d idle", p.started, len(p.idle))
+	}
+	// The next translation starts a new process.
+	if got, err := p.translate("SELECT 2"); err != nil || got != "SELECT 2" {
+		t.Errorf("unexpected translation %q, %v", got, err)
+	}
+
+	// A translation that cannot get a process in time fails too.
+	svc, err := p.acquire(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.translate("SELECT 3"); !errTranslationTimeout.Is(err) {
+		t.Errorf("expected a timeout while waiting for a process, got %v", err)
+	}
+	p.release(svc, true)
+	if len(p.waiters) != 0 || len(p.idle) != 1 {
+		t.Errorf("expected the process to be idle, got %d waiting, %d idle", len(p.waiters), len(p.idle))
+	}
+}
+
+func TestPoolRestart(t *testing.T) {
+	p := newTestPool(t, PoolOptions{Workers: 1, Timeout: 5 * time.Second})
+
+	if _, err := p.translate("CRASH"); !errTranslatorExited.Is(err) {
+		t.Fatalf("expected the crash to fail the query, got %v", err)
+	}
+	// The next translation waits for the restart.
+	if got, err := p.translate("SELECT 2"); err != nil || got != "SELECT 2" {
+		t.Errorf("unexpected translation %q, %v", got, err)
+	}
+	if p.failures != 0 || p.started != 1 {
+		t.Errorf("expected the pool to recover, got %d failures, %d started", p.failures, p.started)
+	}
+}
+
+func TestPoolUnavailable(t *testing.T) {
+	var broken atomic.Bool
+	var starts atomic.Int32
+	broken.Store(true)
+	p := newTestPool(t, PoolOptions{Workers: 1, Timeout: 10 * time.Millisecond})
+	p.newFn = func() (*translateService, error) {
+		starts.Add(1)
+		if broken.Load() {
+			return nil, errors.New("no module named 'sqlglot'")
+		}
+		return newFakeService()
+	}
+
+	if _, err := p.translate("SELECT 1"); !errTranslatorUnavailable.Is(err) {
+		t.Fatalf("expected sqlglot to be unavailable, got %v", err)
+	}
+	// During the backoff, translations fail without starting a process.
+	if _, err := p.translate("SELECT 1"); !errTranslatorUnavailable.Is(err) || starts.Load() != 1 {
+		t.Fatalf("expected a failure without a start, got %v after %d starts", err, starts.Load())
+	}
+
+	// The restart loop keeps trying and recovers.
+	broken.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		idle := len(p.idle)
+		p.mu.Unlock()
+		if idle == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sqlglot was not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, err := p.translate("SELECT 2"); err != nil || got != "SELECT 2" {
+		t.Errorf("unexpected translation %q, %v", got, err)
+	}
+}
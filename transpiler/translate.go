@@ -23,7 +23,10 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/apecloud/myduckserver/metrics"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/src-d/go-errors.v1"
 )
 
@@ -37,20 +40,23 @@ const (
 
 var (
 	errPythonProcessUnhealthy = errors.NewKind("sqlglot python process is unhealthy: %s")
+	errTranslationTimeout     = errors.NewKind("sqlglot did not translate the query within %s")
+	errTranslatorExited       = errors.NewKind("sqlglot exited while translating the query; it is being restarted")
+	errTranslatorUnavailable  = errors.NewKind("sqlglot is unavailable: %v")
+	errSQLGlotDisabled        = errors.NewKind("both the native translator and sqlglot are disabled")
 )
 
+// translateService is a sqlglot worker process, which translates one query at a time.
 type translateService struct {
-	mu       *sync.Mutex
 	pyCmd    *exec.Cmd
 	pyStdin  io.Writer
 	pyStdout io.Reader
 	pyStderr *bytes.Buffer
-}
 
-var (
-	translationSvcOnce sync.Once
-	translationSvc     *translateService
-)
+	// exit is set when the process has exited or has been killed after a timeout, to "crash"
+	// or "timeout". It must then be replaced.
+	exit string
+}
 
 func newTranslateService() (*translateService, error) {
 	pythonPath, err := getPythonPath()
@@ -105,8 +111,11 @@ while True:
             write_string(RESULT_ERR + str(e))
 `, cmdExit, cmdRun, resultOK, resultErr)
 
-	pyCmd := exec.Command(pythonPath, "-u", "-c", pythonScript)
+	return startTranslateService(exec.Command(pythonPath, "-u", "-c", pythonScript))
+}
 
+// startTranslateService starts a process that speaks the protocol of the sqlglot script and checks that it answers.
+func startTranslateService(pyCmd *exec.Cmd) (*translateService, error) {
 	pyStdin, err := pyCmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stdin pipe: %v", err)
@@ -126,7 +135,6 @@ while True:
 	}
 
 	svc := &translateService{
-		mu:       &sync.Mutex{},
 		pyCmd:    pyCmd,
 		pyStdin:  pyStdin,
 		pyStdout: bufio.NewReader(pyStdout),
@@ -135,7 +143,15 @@ while True:
 
 	// Test the translation service with a simple query
 	testSQL := "SELECT 1"
-	translatedSQL, err := svc.translate(testSQL)
+	translatedSQL, err := translateInternalImpl(svc.pyStdin, svc.pyStdout, testSQL)
+	if errPythonProcessUnhealthy.Is(err) {
+		// The process has exited, e.g., because sqlglot is not installed. Its stderr tells why.
+		svc.exit = "crash"
+		svc.kill()
+		stderr := strings.TrimSpace(svc.pyStderr.String())
+		logrus.WithField("pid", pyCmd.Process.Pid).Errorf("sqlglot failed to start (%s)\nstderr:\n%s", pyCmd.ProcessState, stderr)
+		return nil, fmt.Errorf("the process failed to start: %s", lastLine(stderr, pyCmd.ProcessState.String()))
+	}
 	if err != nil {
 		svc.cleanup()
 		return nil, fmt.Errorf("failed to test translation service: %v", err)
@@ -148,18 +164,58 @@ while True:
 	return svc, nil
 }
 
-func (svc *translateService) translate(sql string) (string, error) {
-	svc.mu.Lock()
-	defer svc.mu.Unlock()
+// lastLine returns the last line of |s|, which is the message of a Python traceback, or |fallback| if |s| is empty.
+func lastLine(s string, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s[strings.LastIndexByte(s, '\n')+1:]
+}
 
-	translatedSQL, err := translateInternalImpl(svc.pyStdin, svc.pyStdout, sql)
-	if err != nil {
-		if errors.Is(err, errPythonProcessUnhealthy) {
-			panic(fmt.Errorf("%v\ncmd:\n%s\nstderr:\n%s", err, svc.pyCmd.String(), svc.pyStderr.String()))
-		}
-		return "", err
+// translate translates a query. If |timeout| is positive and the process does not answer in time,
+// it is killed, as its answer could no longer be told from that of the next query.
+func (svc *translateService) translate(sql string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		translated, err := translateInternalImpl(svc.pyStdin, svc.pyStdout, sql)
+		return translated, svc.checkHealth(err)
+	}
+
+	type result struct {
+		translated string
+		err        error
+	}
+	done := make(chan result, 1)
+	go func() {
+		translated, err := translateInternalImpl(svc.pyStdin, svc.pyStdout, sql)
+		done <- result{translated, err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.translated, svc.checkHealth(r.err)
+	case <-timer.C:
+		svc.exit = "timeout"
+		svc.kill()
+		<-done
+		return "", errTranslationTimeout.New(timeout)
+	}
+}
+
+// checkHealth marks the process as exited if it failed to answer, which fails the query with a clear error.
+func (svc *translateService) checkHealth(err error) error {
+	if err == nil || !errPythonProcessUnhealthy.Is(err) {
+		return err
 	}
-	return translatedSQL, nil
+	svc.exit = "crash"
+	svc.kill()
+	logrus.WithField("pid", svc.pyCmd.Process.Pid).Errorf("sqlglot exited (%s): %v\nstderr:\n%s", svc.pyCmd.ProcessState, err, svc.pyStderr.String())
+	return errTranslatorExited.New()
+}
+
+func (svc *translateService) kill() {
+	svc.pyCmd.Process.Kill()
+	svc.pyCmd.Wait()
 }
 
 func translateInternalImpl(pyStdin io.Writer, pyStdout io.Reader, sql string) (string, error) {
@@ -214,22 +270,73 @@ func recvString(reader io.Reader) (string, error) {
 }
 
 func (svc *translateService) cleanup() {
-	svc.mu.Lock()
-	defer svc.mu.Unlock()
+	if svc.exit != "" {
+		return
+	}
 	sendString(svc.pyStdin, cmdExit)
 	svc.pyCmd.Wait()
 }
 
-func TranslateWithSQLGlot(sql string) (string, error) {
-	translationSvcOnce.Do(func() {
-		svc, err := newTranslateService()
-		if err != nil {
-			panic(fmt.Errorf("failed to initialize translation service: %v", err))
+// Options selects the translators of MySQL queries.
+type Options struct {
+	// Native translates the common queries in Go, leaving the others to sqlglot.
+	Native bool
+	// NoPython disables sqlglot, so that the queries that the native translator does not support fail.
+	NoPython bool
+}
+
+var (
+	optionsMu sync.Mutex
+	options   = Options{Native: true}
+)
+
+// SetOptions selects the translators of MySQL queries. Changing them flushes the translation cache,
+// whose translations were made by the previous translators.
+func SetOptions(opts Options) {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	if opts != options {
+		cache.flush()
+	}
+	options = opts
+}
+
+// Translate translates a MySQL query to DuckDB SQL, with the native translator if it supports the query,
+// or else with sqlglot, unless the translation is cached.
+func Translate(sql string) (string, error) {
+	optionsMu.Lock()
+	opts := options
+	optionsMu.Unlock()
+
+	return cache.translate(sql, func(sql string) (string, error) {
+		var err error = errSQLGlotDisabled.New()
+		if opts.Native {
+			var translated string
+			translated, err = translateNative(sql)
+			metrics.ObserveNativeTranslation(err)
+			if err == nil {
+				return translated, nil
+			}
+			logrus.WithError(err).Debugln("Falling back to sqlglot")
+		}
+		if opts.NoPython {
+			return "", err
 		}
-		translationSvc = svc
+		return translateWithSQLGlot(sql)
 	})
+}
+
+// TranslateWithSQLGlot translates a MySQL query to DuckDB SQL with sqlglot. The translation cache is
+// left alone, as it holds the translations of Translate.
+func TranslateWithSQLGlot(sql string) (string, error) {
+	return translateWithSQLGlot(sql)
+}
 
-	return translationSvc.translate(sql)
+func translateWithSQLGlot(sql string) (string, error) {
+	start := time.Now()
+	translated, err := workers.translate(sql)
+	metrics.ObserveTranslation(start, err)
+	return translated, err
 }
 
 func getPythonPath() (string, error) {